@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"network-monitor/internal/collector"
+)
+
+// Config holds the optional settings read from the file passed with -config.
+// Every section is optional; an empty config runs the built-in defaults.
+type Config struct {
+	// Targets replaces the built-in ping targets when set. Each target
+	// declares its probe type (icmp, tcp, udp, http, tls or dns).
+	Targets    []collector.Target    `json:"targets"`
+	HTTPChecks []collector.HTTPCheck `json:"http_checks"`
+	TLSChecks  []collector.TLSCheck  `json:"tls_checks"`
+	DNSChecks  []collector.DNSCheck  `json:"dns_checks"`
+	Traceroute TraceConfig           `json:"traceroute"`
+	MTR        TraceConfig           `json:"mtr"`
+	PMTU       PMTUConfig            `json:"pmtu"`
+	// Interfaces filters and groups the interfaces in the traffic stats
+	Interfaces collector.InterfaceFilter `json:"interfaces"`
+	Capture    CaptureConfig             `json:"capture"`
+	NetFlow    NetFlowConfig             `json:"netflow"`
+	SFlow      SFlowConfig               `json:"sflow"`
+	SNMP       SNMPConfig                `json:"snmp"`
+	Traps      TrapConfig                `json:"traps"`
+	Syslog     collector.SyslogOptions   `json:"syslog"`
+	Events     EventsConfig              `json:"events"`
+}
+
+// TraceConfig selects the targets traced on a schedule, either as full
+// traceroutes or as continuous MTR-style per-hop probing
+type TraceConfig struct {
+	collector.TraceOptions
+	Targets         []string `json:"targets"`
+	IntervalSeconds int      `json:"interval_seconds"`
+}
+
+// PMTUConfig selects the targets whose path MTU is tracked. MaxMTU is the
+// largest size probed and the PMTU the paths are expected to carry; a
+// lower result raises a drop alert until the path recovers.
+type PMTUConfig struct {
+	Targets         []string `json:"targets"`
+	MaxMTU          int      `json:"max_mtu"`
+	IntervalSeconds int      `json:"interval_seconds"`
+}
+
+// CaptureConfig enables live packet capture on an interface. Filter is a
+// BPF program as printed by "tcpdump -ddd <expression>".
+type CaptureConfig struct {
+	Interface    string `json:"interface"`
+	Filter       string `json:"filter"`
+	FlushSeconds int    `json:"flush_seconds"`
+}
+
+// NetFlowConfig enables the NetFlow v5/v9 and IPFIX listener, e.g. ":2055".
+// Exporters lists the router addresses accepted; without it the first 256
+// exporters heard are.
+type NetFlowConfig struct {
+	Listen    string   `json:"listen"`
+	Exporters []string `json:"exporters"`
+}
+
+// SFlowConfig enables the sFlow v5 listener, e.g. ":6343". Sampled flows
+// are flushed to the flow store every FlushSeconds. Agents lists the
+// switch addresses accepted, as agent or sender; without it the first 256
+// agents heard are, from their own address only.
+type SFlowConfig struct {
+	Listen       string   `json:"listen"`
+	FlushSeconds int      `json:"flush_seconds"`
+	Agents       []string `json:"agents"`
+}
+
+// SNMPConfig lists the routers and switches whose interfaces are polled
+type SNMPConfig struct {
+	Devices         []collector.SNMPDevice `json:"devices"`
+	IntervalSeconds int                    `json:"interval_seconds"`
+}
+
+// TrapConfig enables the SNMP trap and inform receiver, e.g. ":162"
+type TrapConfig struct {
+	Listen string `json:"listen"`
+	collector.TrapOptions
+}
+
+// EventsConfig persists the event log as JSON lines in File, so events
+// survive restarts and older ones stay queryable
+type EventsConfig struct {
+	File string `json:"file"`
+}
+
+// loadConfig reads the JSON config at path. An empty path yields the
+// default configuration.
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{
+		Traceroute: TraceConfig{IntervalSeconds: 300},
+		MTR:        TraceConfig{IntervalSeconds: 5},
+		PMTU:       PMTUConfig{MaxMTU: 1500, IntervalSeconds: 300},
+		Capture:    CaptureConfig{FlushSeconds: 10},
+		SFlow:      SFlowConfig{FlushSeconds: 10},
+		SNMP:       SNMPConfig{IntervalSeconds: 30},
+	}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// validate rejects periods that would stop the collectors from starting
+func (c *Config) validate() error {
+	periods := []struct {
+		name    string
+		seconds int
+	}{
+		{"traceroute.interval_seconds", c.Traceroute.IntervalSeconds},
+		{"mtr.interval_seconds", c.MTR.IntervalSeconds},
+		{"pmtu.interval_seconds", c.PMTU.IntervalSeconds},
+		{"capture.flush_seconds", c.Capture.FlushSeconds},
+		{"sflow.flush_seconds", c.SFlow.FlushSeconds},
+		{"snmp.interval_seconds", c.SNMP.IntervalSeconds},
+	}
+	for _, p := range periods {
+		if p.seconds <= 0 {
+			return fmt.Errorf("%s must be positive, got %d", p.name, p.seconds)
+		}
+	}
+	return nil
+}
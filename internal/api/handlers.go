@@ -1,15 +1,23 @@
 package api
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"network-monitor/internal/collector"
 	"network-monitor/internal/storage"
+	"network-monitor/internal/syslog"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -18,6 +26,9 @@ import (
 type Handler struct {
 	store    *storage.Store
 	upgrader websocket.Upgrader
+	offline  bool // serving a capture file rather than live data
+
+	traceSlots chan struct{} // bounds the on-demand traceroutes in flight
 }
 
 type APIResponse struct {
@@ -29,7 +40,8 @@ type APIResponse struct {
 
 func NewHandler(store *storage.Store) *Handler {
 	return &Handler{
-		store: store,
+		store:      store,
+		traceSlots: make(chan struct{}, maxConcurrentTraces),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins for simplicity
@@ -57,6 +69,13 @@ func (h *Handler) GetTraffic(w http.ResponseWriter, r *http.Request) {
 	interfaces := h.store.GetInterfaces()
 	h.sendResponse(w, "success", map[string]interface{}{
 		"interfaces": interfaces,
+		"groups":     h.store.GetInterfaceGroups(),
+	}, "", http.StatusOK)
+}
+
+func (h *Handler) GetInterfaceGroups(w http.ResponseWriter, r *http.Request) {
+	h.sendResponse(w, "success", map[string]interface{}{
+		"groups": h.store.GetInterfaceGroups(),
 	}, "", http.StatusOK)
 }
 
@@ -153,7 +172,9 @@ func (h *Handler) exportTrafficCSV(writer *csv.Writer) {
 	interfaces := h.store.GetInterfaces()
 	
 	// Write header
-	writer.Write([]string{"Timestamp", "Interface", "Bytes_RX", "Bytes_TX", "Speed_RX", "Speed_TX"})
+	writer.Write([]string{"Timestamp", "Interface", "Bytes_RX", "Bytes_TX", "Speed_RX", "Speed_TX",
+		"Errors_RX", "Errors_TX", "Drops_RX", "Drops_TX", "Fifo_RX", "Fifo_TX",
+		"Error_Rate_RX", "Error_Rate_TX", "Drop_Rate_RX", "Drop_Rate_TX", "Fifo_Rate_RX", "Fifo_Rate_TX"})
 	
 	// Write data
 	for _, iface := range interfaces {
@@ -165,6 +186,18 @@ func (h *Handler) exportTrafficCSV(writer *csv.Writer) {
 				strconv.FormatUint(point.BytesTx, 10),
 				fmt.Sprintf("%.2f", point.SpeedRx),
 				fmt.Sprintf("%.2f", point.SpeedTx),
+				strconv.FormatUint(point.ErrorsRx, 10),
+				strconv.FormatUint(point.ErrorsTx, 10),
+				strconv.FormatUint(point.DropsRx, 10),
+				strconv.FormatUint(point.DropsTx, 10),
+				strconv.FormatUint(point.FifoRx, 10),
+				strconv.FormatUint(point.FifoTx, 10),
+				fmt.Sprintf("%.2f", point.ErrorRateRx),
+				fmt.Sprintf("%.2f", point.ErrorRateTx),
+				fmt.Sprintf("%.2f", point.DropRateRx),
+				fmt.Sprintf("%.2f", point.DropRateTx),
+				fmt.Sprintf("%.2f", point.FifoRateRx),
+				fmt.Sprintf("%.2f", point.FifoRateTx),
 			})
 		}
 	}
@@ -174,7 +207,7 @@ func (h *Handler) exportDevicesCSV(writer *csv.Writer) {
 	devices := h.store.GetDevices()
 	
 	// Write header
-	writer.Write([]string{"IP", "MAC", "Hostname", "Last_Seen", "Active"})
+	writer.Write([]string{"IP", "MAC", "Hostname", "Last_Seen", "Active", "Bytes_Up", "Bytes_Down", "Rate_Up", "Rate_Down"})
 	
 	// Write data
 	for _, device := range devices {
@@ -184,6 +217,10 @@ func (h *Handler) exportDevicesCSV(writer *csv.Writer) {
 			device.Hostname,
 			device.LastSeen.Format(time.RFC3339),
 			strconv.FormatBool(device.IsActive),
+			strconv.FormatUint(device.BytesUp, 10),
+			strconv.FormatUint(device.BytesDown, 10),
+			fmt.Sprintf("%.2f", device.RateUp),
+			fmt.Sprintf("%.2f", device.RateDown),
 		})
 	}
 }
@@ -192,20 +229,60 @@ func (h *Handler) exportPingCSV(writer *csv.Writer) {
 	pings := h.store.GetPings()
 	
 	// Write header
-	writer.Write([]string{"Timestamp", "Host", "Latency_MS", "Success"})
+	writer.Write([]string{"Timestamp", "Host", "Latency_MS", "Success", "Method", "DNS_MS", "Connect_MS", "TLS_MS", "TTFB_MS", "Hop", "Hop_Address"})
 	
 	// Write data
 	for host, ping := range pings {
 		for _, point := range ping.History {
-			latencyMs := float64(point.Latency.Nanoseconds()) / 1000000.0
-			writer.Write([]string{
+			row := []string{
 				point.Timestamp.Format(time.RFC3339),
 				host,
-				fmt.Sprintf("%.2f", latencyMs),
+				formatMs(point.Latency),
 				strconv.FormatBool(point.Success),
-			})
+				point.Method,
+				"", "", "", "", "", "",
+			}
+			if t := point.Timings; t != nil {
+				row[5] = formatMs(t.DNS)
+				row[6] = formatMs(t.Connect)
+				row[7] = formatMs(t.TLS)
+				row[8] = formatMs(t.FirstByte)
+			}
+			writer.Write(row)
 		}
 	}
+
+	// Per-hop series of MTR targets
+	for host, mtr := range h.store.GetMTR() {
+		for _, hop := range mtr.Hops {
+			for _, point := range hop.History {
+				writer.Write([]string{
+					point.Timestamp.Format(time.RFC3339),
+					host,
+					formatMs(point.Latency),
+					strconv.FormatBool(point.Success),
+					point.Method,
+					"", "", "", "",
+					strconv.Itoa(hop.TTL),
+					hop.Address,
+				})
+			}
+		}
+	}
+}
+
+// formatMs renders a duration as fractional milliseconds for CSV output
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.2f", float64(d.Nanoseconds())/1000000.0)
+}
+
+func (h *Handler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	activeOnly := r.URL.Query().Get("active") == "true"
+	alerts := h.store.GetAlerts(activeOnly)
+	h.sendResponse(w, "success", map[string]interface{}{
+		"alerts": alerts,
+		"total":  len(alerts),
+	}, "", http.StatusOK)
 }
 
 func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -234,6 +311,7 @@ func (h *Handler) getLiveData() map[string]interface{} {
 	interfaces := h.store.GetInterfaces()
 	devices := h.store.GetDevices()
 	pings := h.store.GetPings()
+	alerts := h.store.GetAlerts(true)
 
 	// Count active devices
 	activeCount := 0
@@ -243,22 +321,656 @@ func (h *Handler) getLiveData() map[string]interface{} {
 		}
 	}
 
-	// Calculate total bandwidth
-	totalRx := 0.0
-	totalTx := 0.0
-	for _, iface := range interfaces {
-		totalRx += iface.SpeedRx
-		totalTx += iface.SpeedTx
-	}
+	// Calculate total bandwidth over the interfaces selected for totals
+	totalRx, totalTx, totalInterfaces := h.store.GetTrafficTotals()
 
 	return map[string]interface{}{
-		"timestamp":      time.Now(),
-		"interfaces":     interfaces,
-		"devices":        devices,
-		"pings":          pings,
-		"active_devices": activeCount,
-		"total_devices":  len(devices),
-		"total_rx":       totalRx,
-		"total_tx":       totalTx,
-	}
-}
\ No newline at end of file
+		"timestamp":        time.Now(),
+		"interfaces":       interfaces,
+		"devices":          devices,
+		"pings":            pings,
+		"alerts":           alerts,
+		"active_devices":   activeCount,
+		"total_devices":    len(devices),
+		"total_rx":         totalRx,
+		"total_tx":         totalTx,
+		"total_interfaces": totalInterfaces,
+		"groups":           h.store.GetInterfaceGroups(),
+	}
+}
+func (h *Handler) GetTraces(w http.ResponseWriter, r *http.Request) {
+	traces := h.store.GetTraces()
+	h.sendResponse(w, "success", map[string]interface{}{
+		"traces": traces,
+	}, "", http.StatusOK)
+}
+
+// On-demand traceroutes hold raw sockets for their whole run, so only a
+// few run at once and each is cut off after traceRequestTimeout
+const (
+	maxConcurrentTraces = 2
+	traceRequestTimeout = 30 * time.Second
+)
+
+// RunTrace performs an on-demand traceroute. Options can be given as query
+// parameters: mode (udp or icmp), max_hops and probes.
+func (h *Handler) RunTrace(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+	query := r.URL.Query()
+
+	opts := collector.TraceOptions{Mode: query.Get("mode")}
+	if value := query.Get("max_hops"); value != "" {
+		maxHops, err := strconv.Atoi(value)
+		if err != nil || maxHops < 1 || maxHops > 64 {
+			h.sendResponse(w, "error", nil, "max_hops must be between 1 and 64", http.StatusBadRequest)
+			return
+		}
+		opts.MaxHops = maxHops
+	}
+	if value := query.Get("probes"); value != "" {
+		probes, err := strconv.Atoi(value)
+		if err != nil || probes < 1 || probes > 10 {
+			h.sendResponse(w, "error", nil, "probes must be between 1 and 10", http.StatusBadRequest)
+			return
+		}
+		opts.ProbesPerHop = probes
+	}
+
+	select {
+	case h.traceSlots <- struct{}{}:
+		defer func() { <-h.traceSlots }()
+	default:
+		h.sendResponse(w, "error", nil, "Too many traceroutes in progress", http.StatusTooManyRequests)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), traceRequestTimeout)
+	defer cancel()
+	trace, err := collector.Traceroute(ctx, host, opts)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.store.StoreTrace(*trace)
+	h.sendResponse(w, "success", trace, "", http.StatusOK)
+}
+
+func (h *Handler) GetAllMTR(w http.ResponseWriter, r *http.Request) {
+	mtr := h.store.GetMTR()
+	h.sendResponse(w, "success", map[string]interface{}{
+		"mtr": mtr,
+	}, "", http.StatusOK)
+}
+
+func (h *Handler) GetMTR(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+
+	mtr := h.store.GetMTR()
+	if stats, exists := mtr[host]; exists {
+		h.sendResponse(w, "success", stats, "", http.StatusOK)
+	} else {
+		h.sendResponse(w, "error", nil, "Host not found", http.StatusNotFound)
+	}
+}
+
+func (h *Handler) GetPMTU(w http.ResponseWriter, r *http.Request) {
+	pmtu := h.store.GetPMTU()
+	h.sendResponse(w, "success", map[string]interface{}{
+		"pmtu": pmtu,
+	}, "", http.StatusOK)
+}
+
+func (h *Handler) GetSystemNetwork(w http.ResponseWriter, r *http.Request) {
+	snapshot, changes := h.store.GetNetworkSnapshot()
+	if snapshot == nil {
+		h.sendResponse(w, "error", nil, "Network snapshot not collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	h.sendResponse(w, "success", map[string]interface{}{
+		"interfaces": snapshot.Interfaces,
+		"routes":     snapshot.Routes,
+		"collected":  snapshot.Timestamp,
+		"changes":    changes,
+	}, "", http.StatusOK)
+}
+
+// GetProcesses lists processes with open sockets, busiest first. The
+// optional limit query parameter returns only the top processes.
+func (h *Handler) GetProcesses(w http.ResponseWriter, r *http.Request) {
+	snapshot := h.store.GetProcesses()
+	if snapshot == nil {
+		h.sendResponse(w, "error", nil, "Processes not collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	processes := snapshot.Processes
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			h.sendResponse(w, "error", nil, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if limit < len(processes) {
+			processes = processes[:limit]
+		}
+	}
+
+	h.sendResponse(w, "success", map[string]interface{}{
+		"processes":     processes,
+		"byte_counters": snapshot.ByteCounters,
+		"collected":     snapshot.Timestamp,
+	}, "", http.StatusOK)
+}
+
+func (h *Handler) GetProcess(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(mux.Vars(r)["pid"])
+	if err != nil {
+		h.sendResponse(w, "error", nil, "Invalid PID", http.StatusBadRequest)
+		return
+	}
+
+	if snapshot := h.store.GetProcesses(); snapshot != nil {
+		for _, proc := range snapshot.Processes {
+			if proc.PID == pid {
+				h.sendResponse(w, "success", proc, "", http.StatusOK)
+				return
+			}
+		}
+	}
+	h.sendResponse(w, "error", nil, "Process not found", http.StatusNotFound)
+}
+
+// GetConnections serves the socket table. Optional filters: state (comma
+// separated, e.g. ESTABLISHED,TIME_WAIT), port (local or remote), proto
+// (tcp, udp, tcp6 or udp6) and remote (a CIDR subnet or address).
+func (h *Handler) GetConnections(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	states := make(map[string]bool)
+	if value := query.Get("state"); value != "" {
+		for _, state := range strings.Split(value, ",") {
+			states[strings.ToUpper(strings.TrimSpace(state))] = true
+		}
+	}
+
+	port := 0
+	if value := query.Get("port"); value != "" {
+		p, err := strconv.Atoi(value)
+		if err != nil || p < 1 || p > 65535 {
+			h.sendResponse(w, "error", nil, "port must be between 1 and 65535", http.StatusBadRequest)
+			return
+		}
+		port = p
+	}
+
+	var remote *net.IPNet
+	if value := query.Get("remote"); value != "" {
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, subnet, err := net.ParseCIDR(value)
+		if err != nil {
+			h.sendResponse(w, "error", nil, "remote must be an IP address or CIDR subnet", http.StatusBadRequest)
+			return
+		}
+		remote = subnet
+	}
+
+	proto := query.Get("proto")
+
+	connections, collected := h.store.GetConnections()
+	filtered := []storage.Connection{}
+	counts := make(map[string]int)
+	for _, conn := range connections {
+		if len(states) > 0 && !states[conn.State] {
+			continue
+		}
+		if port != 0 && conn.LocalPort != port && conn.RemotePort != port {
+			continue
+		}
+		if proto != "" && conn.Proto != proto {
+			continue
+		}
+		if remote != nil && !remote.Contains(net.ParseIP(conn.RemoteAddress)) {
+			continue
+		}
+		filtered = append(filtered, conn)
+		counts[conn.State]++
+	}
+
+	h.sendResponse(w, "success", map[string]interface{}{
+		"connections": filtered,
+		"total":       len(filtered),
+		"states":      counts,
+		"collected":   collected,
+	}, "", http.StatusOK)
+}
+
+// GetConnectionHistory serves the per-state connection counts over time
+func (h *Handler) GetConnectionHistory(w http.ResponseWriter, r *http.Request) {
+	h.sendResponse(w, "success", map[string]interface{}{
+		"history": h.store.GetConnectionHistory(),
+	}, "", http.StatusOK)
+}
+
+// GetNetstat serves the kernel TCP/IP protocol metrics. Histories are
+// included for the metrics named in the comma separated metrics parameter,
+// which also limits the response to those metrics.
+func (h *Handler) GetNetstat(w http.ResponseWriter, r *http.Request) {
+	selected := make(map[string]bool)
+	if value := r.URL.Query().Get("metrics"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			selected[strings.TrimSpace(name)] = true
+		}
+	}
+
+	summary, metrics := h.store.GetStackStats(selected)
+	if summary == nil {
+		h.sendResponse(w, "error", nil, "Protocol counters not collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	if len(selected) > 0 {
+		filtered := []storage.StackMetric{}
+		for _, metric := range metrics {
+			if selected[metric.Name] {
+				filtered = append(filtered, metric)
+			}
+		}
+		metrics = filtered
+	}
+
+	h.sendResponse(w, "success", map[string]interface{}{
+		"retransmit_percent": summary.RetransmitPercent,
+		"metrics":            metrics,
+		"collected":          summary.LastUpdated,
+	}, "", http.StatusOK)
+}
+
+// SetOffline marks the store as holding only capture files, so uploaded
+// captures may fill in the devices
+func (h *Handler) SetOffline(offline bool) {
+	h.offline = offline
+}
+
+// maxPcapUpload caps the size of an uploaded capture file
+const maxPcapUpload = 512 << 20
+
+// UploadPcap ingests a capture posted either as the "file" field of a
+// multipart form or as the raw request body. While collecting live data
+// only its flows are loaded.
+func (h *Handler) UploadPcap(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPcapUpload)
+
+	body, name := io.Reader(r.Body), "upload"
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			h.sendResponse(w, "error", nil, "Expected the capture in a \"file\" form field", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body, name = file, header.Filename
+	} else if value := r.URL.Query().Get("name"); value != "" {
+		name = value
+	}
+
+	summary, err := collector.IngestPcap(h.store, body, name, h.offline)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.sendResponse(w, "success", summary, "", http.StatusOK)
+}
+
+// parseFlowQuery reads the flow filters shared by the flow endpoints:
+// source, ip, port, proto, since/until (RFC 3339) or window (a duration
+// back from now, e.g. 5m), and limit
+func parseFlowQuery(r *http.Request) (storage.FlowQuery, int, error) {
+	query := r.URL.Query()
+	q := storage.FlowQuery{
+		Source: query.Get("source"),
+		IP:     query.Get("ip"),
+		Proto:  query.Get("proto"),
+	}
+
+	if value := query.Get("port"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 0 || port > 65535 {
+			return q, 0, fmt.Errorf("port must be between 0 and 65535")
+		}
+		q.Port = port
+	}
+	var err error
+	if q.Since, q.Until, err = parseTimeRange(query); err != nil {
+		return q, 0, err
+	}
+	limit, err := parseLimit(query)
+	return q, limit, err
+}
+
+// parseTimeRange reads the window (a duration back from now) or the
+// since/until (RFC 3339) parameters; window and since exclude each other
+func parseTimeRange(query url.Values) (time.Time, time.Time, error) {
+	var since, until time.Time
+	if query.Get("window") != "" && query.Get("since") != "" {
+		return since, until, fmt.Errorf("give either window or since, not both")
+	}
+	if value := query.Get("window"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			return since, until, fmt.Errorf("window must be a positive duration such as 5m")
+		}
+		since = time.Now().Add(-window)
+	}
+	for param, field := range map[string]*time.Time{"since": &since, "until": &until} {
+		if value := query.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return since, until, fmt.Errorf("%s must be an RFC 3339 timestamp", param)
+			}
+			*field = t
+		}
+	}
+	return since, until, nil
+}
+
+// parseLimit reads the limit parameter; 0 means none was given
+func parseLimit(query url.Values) (int, error) {
+	value := query.Get("limit")
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("limit must be a positive number")
+	}
+	return limit, nil
+}
+
+func (h *Handler) GetFlows(w http.ResponseWriter, r *http.Request) {
+	q, limit, err := parseFlowQuery(r)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = 100
+	}
+	h.sendResponse(w, "success", map[string]interface{}{
+		"flows": h.store.GetFlows(q, limit),
+	}, "", http.StatusOK)
+}
+
+func (h *Handler) GetTopTalkers(w http.ResponseWriter, r *http.Request) {
+	q, limit, err := parseFlowQuery(r)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	// direction=src or dst ranks by bytes sent or received only
+	talkers := h.store.GetTopTalkers(q, 0)
+	switch direction := r.URL.Query().Get("direction"); direction {
+	case "src":
+		sort.SliceStable(talkers, func(i, j int) bool { return talkers[i].BytesSent > talkers[j].BytesSent })
+	case "dst":
+		sort.SliceStable(talkers, func(i, j int) bool { return talkers[i].BytesReceived > talkers[j].BytesReceived })
+	case "":
+	default:
+		h.sendResponse(w, "error", nil, "direction must be src or dst", http.StatusBadRequest)
+		return
+	}
+	if len(talkers) > limit {
+		talkers = talkers[:limit]
+	}
+
+	h.sendResponse(w, "success", map[string]interface{}{
+		"talkers": talkers,
+		"ports":   h.store.GetTopPorts(q, limit),
+	}, "", http.StatusOK)
+}
+
+// GetDeviceThroughput serves per-device traffic over a window, by default
+// the last minute
+func (h *Handler) GetDeviceThroughput(w http.ResponseWriter, r *http.Request) {
+	q, _, err := parseFlowQuery(r)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.Since.IsZero() {
+		q.Since = time.Now().Add(-time.Minute)
+	}
+	h.sendResponse(w, "success", map[string]interface{}{
+		"devices": h.store.GetDeviceThroughput(q),
+		"since":   q.Since,
+	}, "", http.StatusOK)
+}
+
+func (h *Handler) GetProtocolBreakdown(w http.ResponseWriter, r *http.Request) {
+	q, _, err := parseFlowQuery(r)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.sendResponse(w, "success", map[string]interface{}{
+		"protocols": h.store.GetProtocolBreakdown(q),
+	}, "", http.StatusOK)
+}
+
+// GetIncidents lists the outages derived from the ping targets. active=true
+// leaves out the resolved ones; window, since and until select incidents
+// overlapping that time range.
+func (h *Handler) GetIncidents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := storage.IncidentQuery{ActiveOnly: query.Get("active") == "true"}
+	var err error
+	if q.Since, q.Until, err = parseTimeRange(query); err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	incidents := h.store.GetIncidents(q)
+	var downtime time.Duration
+	for _, incident := range incidents {
+		downtime += incident.Duration
+	}
+	h.sendResponse(w, "success", map[string]interface{}{
+		"incidents": incidents,
+		"total":     len(incidents),
+		"downtime":  downtime,
+	}, "", http.StatusOK)
+}
+
+// parseEventQuery reads the event filter parameters
+func parseEventQuery(r *http.Request) (storage.EventQuery, int, error) {
+	query := r.URL.Query()
+	q := storage.EventQuery{
+		Type:   query.Get("type"),
+		Source: query.Get("source"),
+		Target: query.Get("target"),
+		Text:   query.Get("q"),
+	}
+	var err error
+	if q.Since, q.Until, err = parseTimeRange(query); err != nil {
+		return q, 0, err
+	}
+	limit, err := parseLimit(query)
+	return q, limit, err
+}
+
+func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	q, limit, err := parseEventQuery(r)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = 100
+	}
+
+	events := h.store.GetEvents(q, limit)
+	h.sendResponse(w, "success", map[string]interface{}{
+		"events": events,
+		"total":  len(events),
+	}, "", http.StatusOK)
+}
+
+// parseLogQuery reads the syslog search parameters. severity selects
+// messages at least that severe, e.g. "warning" includes err and crit.
+func parseLogQuery(r *http.Request) (storage.LogQuery, int, error) {
+	query := r.URL.Query()
+	q := storage.LogQuery{
+		Host: query.Get("host"),
+		App:  query.Get("app"),
+		Text: query.Get("q"),
+	}
+	if value := query.Get("severity"); value != "" {
+		severity, err := syslog.ParseSeverity(value)
+		if err != nil {
+			return q, 0, err
+		}
+		for level := syslog.Emergency; level <= severity; level++ {
+			q.Levels = append(q.Levels, syslog.SeverityName(level))
+		}
+	}
+
+	var err error
+	if q.Since, q.Until, err = parseTimeRange(query); err != nil {
+		return q, 0, err
+	}
+	limit, err := parseLimit(query)
+	return q, limit, err
+}
+
+func (h *Handler) GetLogs(w http.ResponseWriter, r *http.Request) {
+	q, limit, err := parseLogQuery(r)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = 100
+	}
+
+	logs := h.store.GetLogs(q, limit)
+	h.sendResponse(w, "success", map[string]interface{}{
+		"logs":  logs,
+		"total": len(logs),
+	}, "", http.StatusOK)
+}
+
+func (h *Handler) GetLogHosts(w http.ResponseWriter, r *http.Request) {
+	h.sendResponse(w, "success", map[string]interface{}{
+		"hosts": h.store.GetLogHosts(),
+	}, "", http.StatusOK)
+}
+
+// TailLogs streams the syslog messages matching the search parameters
+// over a WebSocket, starting with the last limit (default 20) of them
+func (h *Handler) TailLogs(w http.ResponseWriter, r *http.Request) {
+	q, limit, err := parseLogQuery(r)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	h.tail(w, r, func() []interface{} {
+		logs, newest := h.store.NewLogs(q, limit)
+		limit = 0
+		// Oldest first, as a tail prints them
+		items := make([]interface{}, 0, len(logs))
+		for i := len(logs) - 1; i >= 0; i-- {
+			items = append(items, logs[i])
+		}
+		q.AfterID = newest
+		return items
+	})
+}
+
+// StreamEvents streams the events matching the query parameters of
+// GetEvents over a WebSocket, starting with the last limit (default 20)
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	q, limit, err := parseEventQuery(r)
+	if err != nil {
+		h.sendResponse(w, "error", nil, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	// Only the backfill may read the event log file; after it the stream
+	// follows the events in memory
+	backfill := true
+	h.tail(w, r, func() []interface{} {
+		var events []storage.Event
+		var newest int
+		if backfill {
+			newest = h.store.LastEventID()
+			events = h.store.GetEvents(q, limit)
+			backfill = false
+		} else {
+			events, newest = h.store.NewEvents(q)
+		}
+		items := make([]interface{}, 0, len(events))
+		for i := len(events) - 1; i >= 0; i-- {
+			items = append(items, events[i])
+			newest = max(newest, events[i].ID)
+		}
+		q.AfterID = newest
+		return items
+	})
+}
+
+// tail upgrades to a WebSocket and sends the items returned by next every
+// second, one message each, until the client goes away
+func (h *Handler) tail(w http.ResponseWriter, r *http.Request, next func() []interface{}) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// The client only sends close frames; reading notices them
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		for _, item := range next() {
+			if err := conn.WriteJSON(item); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// metricPrefix namespaces every exported Prometheus metric
+const metricPrefix = "netmon_"
+
+// Metrics serves the collected statistics in the Prometheus text
+// exposition format
+func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+
+	summary, metrics := h.store.GetStackStats(nil)
+	if summary != nil {
+		writeMetric(&b, "tcp_retransmit_percent", "gauge",
+			"Share of TCP segments sent in the last interval that were retransmitted", summary.RetransmitPercent)
+
+		// Named after the /proc fields, like node_exporter's netstat metrics
+		for _, metric := range metrics {
+			proto, field, _ := strings.Cut(metric.Name, ".")
+			kind := "gauge"
+			if metric.Counter {
+				kind = "counter"
+			}
+			writeMetric(&b, "netstat_"+proto+"_"+field, kind,
+				fmt.Sprintf("Kernel protocol statistic %s.%s", proto, field), float64(metric.Value))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func writeMetric(b *strings.Builder, name, kind, help string, value float64) {
+	name = metricPrefix + name
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+	fmt.Fprintf(b, "%s %g\n", name, value)
+}
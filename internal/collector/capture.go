@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"network-monitor/internal/pcap"
+	"network-monitor/internal/storage"
+)
+
+// bpfInstruction is one classic BPF instruction, as printed by tcpdump -ddd
+type bpfInstruction struct {
+	Op uint16
+	Jt uint8
+	Jf uint8
+	K  uint32
+}
+
+// packetSource delivers captured frames of one link type
+type packetSource interface {
+	LinkType() int
+	// ReadPacket returns the next frame and its length on the wire
+	ReadPacket(buf []byte) (int, int, error)
+	Close() error
+}
+
+// CaptureCollector captures packets on an interface and aggregates them
+// into flows that are flushed to the store at a fixed interval
+type CaptureCollector struct {
+	store  *storage.Store
+	iface  string
+	filter []bpfInstruction
+	mu     sync.Mutex
+	table  *flowTable
+}
+
+// NewCaptureCollector prepares a capture on iface. filter is a compiled BPF
+// program in "tcpdump -ddd" format, instructions separated by newlines or
+// commas; empty captures everything.
+func NewCaptureCollector(store *storage.Store, iface, filter string) (*CaptureCollector, error) {
+	program, err := parseBPF(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &CaptureCollector{
+		store:  store,
+		iface:  iface,
+		filter: program,
+		table:  newFlowTable("capture:" + iface),
+	}, nil
+}
+
+// Start captures until the socket fails, flushing flows every interval
+func (cc *CaptureCollector) Start(interval time.Duration) {
+	src, err := openCapture(cc.iface, cc.filter)
+	if err != nil {
+		log.Printf("Packet capture on %s unavailable: %v", cc.iface, err)
+		return
+	}
+	defer src.Close()
+
+	log.Printf("Packet capture started on %s", cc.iface)
+
+	// Flushing stops with the capture
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				cc.flush()
+			}
+		}
+	}()
+
+	buf := make([]byte, 65536)
+	linkType := src.LinkType()
+	for {
+		n, length, err := src.ReadPacket(buf)
+		if err != nil {
+			log.Printf("Packet capture on %s stopped: %v", cc.iface, err)
+			cc.flush()
+			return
+		}
+		now := time.Now()
+
+		decoded, err := pcap.Decode(linkType, buf[:n])
+		if err != nil {
+			continue
+		}
+		if decoded.Length > 0 {
+			length = decoded.Length
+		}
+		var idle []storage.FlowRecord
+		cc.mu.Lock()
+		cc.table.add(decoded, now, length)
+		if cc.table.full() {
+			idle = cc.table.flushOldest()
+		}
+		cc.mu.Unlock()
+		if idle != nil {
+			cc.store.AddFlows(idle)
+		}
+	}
+}
+
+func (cc *CaptureCollector) flush() {
+	cc.mu.Lock()
+	records := cc.table.flush()
+	cc.mu.Unlock()
+
+	cc.store.AddFlows(records)
+}
+
+// parseBPF reads the output of "tcpdump -ddd": an instruction count
+// followed by one "code jt jf k" line per instruction
+func parseBPF(text string) ([]bpfInstruction, error) {
+	text = strings.TrimSpace(strings.ReplaceAll(text, ",", "\n"))
+	if text == "" {
+		return nil, nil
+	}
+
+	lines := strings.Split(text, "\n")
+	count, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil || count != len(lines)-1 || count == 0 {
+		return nil, fmt.Errorf("BPF filter must start with its instruction count, as printed by tcpdump -ddd")
+	}
+
+	program := make([]bpfInstruction, 0, count)
+	for i, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("BPF instruction %d: expected \"code jt jf k\"", i+1)
+		}
+		var values [4]uint64
+		for j, field := range fields {
+			values[j], err = strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("BPF instruction %d: %w", i+1, err)
+			}
+		}
+		if values[0] > 0xffff || values[1] > 0xff || values[2] > 0xff {
+			return nil, fmt.Errorf("BPF instruction %d out of range", i+1)
+		}
+		program = append(program, bpfInstruction{
+			Op: uint16(values[0]),
+			Jt: uint8(values[1]),
+			Jf: uint8(values[2]),
+			K:  uint32(values[3]),
+		})
+	}
+	return program, nil
+}
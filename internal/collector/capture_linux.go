@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"fmt"
+	"net"
+
+	"network-monitor/internal/pcap"
+
+	"golang.org/x/sys/unix"
+)
+
+// afPacketSource reads frames from an AF_PACKET socket bound to one
+// interface
+type afPacketSource struct {
+	fd       int
+	linkType int
+}
+
+// openCapture opens an AF_PACKET socket on iface (needs CAP_NET_RAW) and
+// attaches the BPF filter in the kernel, so unwanted packets are never
+// copied to user space
+func openCapture(iface string, filter []bpfInstruction) (packetSource, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ethernet-like interfaces are captured with their link header so
+	// MAC addresses are known; others (tun, ppp) deliver bare IP packets
+	sockType, linkType := unix.SOCK_RAW, pcap.LinkTypeEthernet
+	if len(ifi.HardwareAddr) != 6 {
+		sockType, linkType = unix.SOCK_DGRAM, pcap.LinkTypeRaw
+	}
+
+	protocol := htons(unix.ETH_P_ALL)
+	fd, err := unix.Socket(unix.AF_PACKET, sockType|unix.SOCK_CLOEXEC, int(protocol))
+	if err != nil {
+		return nil, fmt.Errorf("open AF_PACKET socket (needs root or CAP_NET_RAW): %w", err)
+	}
+
+	if len(filter) > 0 {
+		program := make([]unix.SockFilter, len(filter))
+		for i, ins := range filter {
+			program[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+		}
+		fprog := unix.SockFprog{Len: uint16(len(program)), Filter: &program[0]}
+		if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &fprog); err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("attach BPF filter: %w", err)
+		}
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: protocol, Ifindex: ifi.Index}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind to %s: %w", iface, err)
+	}
+	return &afPacketSource{fd: fd, linkType: linkType}, nil
+}
+
+func (s *afPacketSource) LinkType() int {
+	return s.linkType
+}
+
+func (s *afPacketSource) ReadPacket(buf []byte) (int, int, error) {
+	for {
+		// MSG_TRUNC reports the full length of frames larger than buf
+		n, _, err := unix.Recvfrom(s.fd, buf, unix.MSG_TRUNC)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if n > len(buf) {
+			return len(buf), n, nil
+		}
+		return n, n, nil
+	}
+}
+
+func (s *afPacketSource) Close() error {
+	return unix.Close(s.fd)
+}
+
+// htons converts a protocol number to network byte order, as AF_PACKET
+// expects it
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package collector
+
+import "errors"
+
+// openCapture is only implemented on Linux
+func openCapture(iface string, filter []bpfInstruction) (packetSource, error) {
+	return nil, errors.New("live packet capture is only supported on Linux")
+}
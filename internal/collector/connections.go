@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"log"
+	"time"
+
+	"network-monitor/internal/storage"
+)
+
+// ConnectionCollector snapshots the TCP and UDP socket tables together with
+// the process owning each socket
+type ConnectionCollector struct {
+	store *storage.Store
+}
+
+func NewConnectionCollector(store *storage.Store) *ConnectionCollector {
+	return &ConnectionCollector{store: store}
+}
+
+func (cc *ConnectionCollector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Connection collector started")
+
+	for {
+		cc.collectConnections()
+		<-ticker.C
+	}
+}
+
+func (cc *ConnectionCollector) collectConnections() {
+	sockets, err := readSockets()
+	if err != nil {
+		log.Printf("Error reading socket tables: %v", err)
+		return
+	}
+	owners := socketOwners()
+
+	connections := make([]storage.Connection, 0, len(sockets))
+	for _, sock := range sockets {
+		conn := storage.Connection{
+			Proto:         sock.Proto,
+			LocalAddress:  sock.LocalIP.String(),
+			LocalPort:     sock.LocalPort,
+			RemoteAddress: sock.RemoteIP.String(),
+			RemotePort:    sock.RemotePort,
+			State:         sock.State,
+			TxQueue:       sock.TxQueue,
+			RxQueue:       sock.RxQueue,
+			UID:           sock.UID,
+		}
+		if owner, ok := owners[sock.Inode]; ok && sock.Inode != 0 {
+			conn.PID = owner.PID
+			conn.Process = owner.Name
+		}
+		connections = append(connections, conn)
+	}
+
+	cc.store.SetConnections(connections, time.Now())
+}
@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"network-monitor/internal/storage"
+)
+
+// conntrackPaths are tried in order; ip_conntrack is the pre-3.x name
+var conntrackPaths = []string{"/proc/net/nf_conntrack", "/proc/net/ip_conntrack"}
+
+// conntrackFlow is one conntrack entry. The original tuple is the direction
+// the connection was opened in, the reply tuple the way back (after NAT).
+type conntrackFlow struct {
+	Key         string
+	OrigSrc     string
+	ReplySrc    string
+	OrigBytes   uint64
+	ReplyBytes  uint64
+	HasAccounts bool
+}
+
+// ConntrackCollector attributes the traffic netfilter tracks to the LAN
+// devices that sent and received it. Byte counts need conntrack accounting
+// (sysctl net.netfilter.nf_conntrack_acct=1).
+type ConntrackCollector struct {
+	store *storage.Store
+
+	prev     map[string]conntrackFlow
+	prevTime time.Time
+	warned   bool
+}
+
+func NewConntrackCollector(store *storage.Store) *ConntrackCollector {
+	return &ConntrackCollector{store: store}
+}
+
+func (cc *ConntrackCollector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Conntrack collector started")
+
+	for {
+		cc.collectConntrack()
+		<-ticker.C
+	}
+}
+
+func (cc *ConntrackCollector) collectConntrack() {
+	flows, err := readConntrack()
+	if err != nil {
+		if !cc.warned {
+			log.Printf("Conntrack unavailable, per-device bandwidth disabled: %v", err)
+			cc.warned = true
+		}
+		return
+	}
+
+	now := time.Now()
+	usage, accounted := conntrackUsage(flows, cc.prev, cc.store.GetDevices())
+
+	if !accounted && len(flows) > 0 && !cc.warned {
+		log.Println("Conntrack entries carry no byte counters; enable net.netfilter.nf_conntrack_acct")
+		cc.warned = true
+	}
+
+	if cc.prev != nil {
+		cc.store.UpdateDeviceBandwidth(usage, now.Sub(cc.prevTime).Seconds())
+	}
+	cc.prev = flows
+	cc.prevTime = now
+}
+
+// conntrackUsage sums the bytes each device sent and received since the
+// previous reading, nil on the first one. An entry new since then counts
+// with its full totals, so a connection that opened and closed between two
+// readings is included as long as its entry lingers until the next one
+// (TIME_WAIT, UDP timeouts). Still missed are entries the kernel drops
+// sooner, e.g. early drops when the table is full, the bytes that entries
+// present at the first reading carried before it, and bytes an entry
+// carried after its last reading.
+func conntrackUsage(flows, prev map[string]conntrackFlow, devices map[string]*storage.Device) (map[string]*storage.DeviceBytes, bool) {
+	usage := make(map[string]*storage.DeviceBytes)
+	accounted := false
+
+	for key, flow := range flows {
+		if !flow.HasAccounts {
+			continue
+		}
+		accounted = true
+
+		// The history of entries present on the first pass is unknown
+		if prev == nil {
+			continue
+		}
+		origDelta, replyDelta := flow.OrigBytes, flow.ReplyBytes
+		// Counters below the last reading belong to a new connection
+		// that reuses the tuple, which counts in full like any new entry
+		if last, seen := prev[key]; seen && flow.OrigBytes >= last.OrigBytes && flow.ReplyBytes >= last.ReplyBytes {
+			origDelta -= last.OrigBytes
+			replyDelta -= last.ReplyBytes
+		}
+
+		// Outbound: a LAN device opened the connection. Inbound (port
+		// forwards): the reply comes from the LAN device after DNAT.
+		var ip string
+		var up, down uint64
+		if _, ok := devices[flow.OrigSrc]; ok {
+			ip, up, down = flow.OrigSrc, origDelta, replyDelta
+		} else if _, ok := devices[flow.ReplySrc]; ok {
+			ip, up, down = flow.ReplySrc, replyDelta, origDelta
+		} else {
+			continue
+		}
+
+		entry, exists := usage[ip]
+		if !exists {
+			entry = &storage.DeviceBytes{}
+			usage[ip] = entry
+		}
+		entry.Up += up
+		entry.Down += down
+		entry.Flows++
+	}
+	return usage, accounted
+}
+
+// readConntrack parses the netfilter connection tracking table, keyed by
+// the connection's original tuple
+func readConntrack() (map[string]conntrackFlow, error) {
+	var file *os.File
+	var err error
+	for _, path := range conntrackPaths {
+		if file, err = os.Open(path); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read conntrack table: %w", err)
+	}
+	defer file.Close()
+
+	flows := make(map[string]conntrackFlow)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if flow, ok := parseConntrackLine(scanner.Text()); ok {
+			flows[flow.Key] = flow
+		}
+	}
+	return flows, scanner.Err()
+}
+
+// parseConntrackLine reads an entry such as
+//
+//	ipv4 2 tcp 6 431999 ESTABLISHED src=10.0.0.5 dst=1.1.1.1 sport=51000 dport=443
+//	packets=10 bytes=1200 src=1.1.1.1 dst=203.0.113.7 sport=443 dport=51000
+//	packets=12 bytes=9000 [ASSURED] mark=0 use=1
+//
+// where the first src/dst group is the original direction and the second
+// the reply direction
+func parseConntrackLine(line string) (conntrackFlow, bool) {
+	var flow conntrackFlow
+	var orig []string
+	tuple := 0 // 1 while in the original tuple, 2 in the reply tuple
+
+	fields := strings.Fields(line)
+	proto := ""
+	if len(fields) > 2 {
+		proto = fields[2]
+	}
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "src":
+			tuple++
+			if tuple == 1 {
+				flow.OrigSrc = value
+			} else if tuple == 2 {
+				flow.ReplySrc = value
+			}
+		case "bytes":
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			flow.HasAccounts = true
+			if tuple == 1 {
+				flow.OrigBytes = n
+			} else if tuple == 2 {
+				flow.ReplyBytes = n
+			}
+		}
+		if tuple == 1 && key != "packets" && key != "bytes" {
+			orig = append(orig, field)
+		}
+	}
+	if tuple < 2 {
+		return flow, false
+	}
+
+	flow.Key = proto + " " + strings.Join(orig, " ")
+	return flow, true
+}
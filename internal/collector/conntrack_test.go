@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"testing"
+
+	"network-monitor/internal/storage"
+)
+
+func TestConntrackUsage(t *testing.T) {
+	devices := map[string]*storage.Device{"10.0.0.5": {IP: "10.0.0.5"}}
+	flow := func(key string, up, down uint64) conntrackFlow {
+		return conntrackFlow{Key: key, OrigSrc: "10.0.0.5", ReplySrc: "1.1.1.1", OrigBytes: up, ReplyBytes: down, HasAccounts: true}
+	}
+	prev := map[string]conntrackFlow{
+		"tcp a": flow("tcp a", 1000, 5000),
+		"tcp b": flow("tcp b", 9000, 9000),
+	}
+	flows := map[string]conntrackFlow{
+		// Seen before: only the growth counts
+		"tcp a": flow("tcp a", 1500, 8000),
+		// The tuple was reused by a new connection
+		"tcp b": flow("tcp b", 100, 200),
+		// Opened and closed since the last reading
+		"udp c": flow("udp c", 60, 120),
+	}
+
+	usage, accounted := conntrackUsage(flows, prev, devices)
+	if !accounted {
+		t.Fatal("flows with byte counters not reported as accounted")
+	}
+	got := usage["10.0.0.5"]
+	if got == nil || got.Up != 500+100+60 || got.Down != 3000+200+120 || got.Flows != 3 {
+		t.Errorf("usage = %+v, want 660 up, 3320 down over 3 flows", got)
+	}
+
+	// Entries already present on the first reading have an unknown history
+	if usage, _ := conntrackUsage(flows, nil, devices); len(usage) != 0 {
+		t.Errorf("first reading counted %+v", usage["10.0.0.5"])
+	}
+}
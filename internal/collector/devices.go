@@ -55,6 +55,7 @@ func (dc *DeviceCollector) discoverDevices() {
 		}
 		dc.store.UpdateDevice(device.IP, device.MAC, device.Hostname)
 	}
+	dc.store.ExpireDevices()
 }
 
 // parseARPTable fetches IP-MAC pairs using the system arp command (cross-platform)
@@ -0,0 +1,305 @@
+package collector
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"network-monitor/internal/storage"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNSCheck describes a query sent to a specific resolver
+type DNSCheck struct {
+	Name      string   `json:"name"`
+	Server    string   `json:"server"`              // host or host:port
+	Query     string   `json:"query"`               // name to resolve
+	Type      string   `json:"type,omitempty"`      // A, AAAA, CNAME, MX, NS, PTR, SOA, SRV, TXT
+	Transport string   `json:"transport,omitempty"` // udp, tcp or tls (DNS over TLS)
+	Expect    []string `json:"expect,omitempty"`    // answers that must all be present
+	TimeoutMs int      `json:"timeout_ms,omitempty"`
+}
+
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"PTR":   dnsmessage.TypePTR,
+	"SOA":   dnsmessage.TypeSOA,
+	"SRV":   dnsmessage.TypeSRV,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+var dnsRcodes = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+// key returns the name the check's results are stored under
+func (c DNSCheck) key() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s %s @%s", c.Query, c.recordType(), c.Server)
+}
+
+func (c DNSCheck) recordType() string {
+	if c.Type == "" {
+		return "A"
+	}
+	return strings.ToUpper(c.Type)
+}
+
+func (c DNSCheck) transport() string {
+	if c.Transport == "" {
+		return "udp"
+	}
+	return strings.ToLower(c.Transport)
+}
+
+// serverAddr returns the resolver address with the transport's default port
+func (c DNSCheck) serverAddr() string {
+	if _, _, err := net.SplitHostPort(c.Server); err == nil {
+		return c.Server
+	}
+	if c.transport() == "tls" {
+		return net.JoinHostPort(c.Server, "853")
+	}
+	return net.JoinHostPort(c.Server, "53")
+}
+
+// dnsProbe sends the check's query to its resolver and validates the reply
+func dnsProbe(check DNSCheck) PingResult {
+	result := PingResult{Host: check.key(), Method: "DNS"}
+
+	qtype, ok := dnsTypes[check.recordType()]
+	if !ok {
+		result.Error = fmt.Errorf("unsupported record type %q", check.Type)
+		return result
+	}
+
+	name, err := dnsmessage.NewName(fqdn(check.Query))
+	if err != nil {
+		result.Error = fmt.Errorf("invalid query name %q: %w", check.Query, err)
+		return result
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		result.Error = fmt.Errorf("pack query: %w", err)
+		return result
+	}
+
+	timeout := 5 * time.Second
+	if check.TimeoutMs > 0 {
+		timeout = time.Duration(check.TimeoutMs) * time.Millisecond
+	}
+
+	transport := check.transport()
+	start := time.Now()
+	raw, err := dnsExchange(transport, check.serverAddr(), packed, timeout)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	var reply dnsmessage.Message
+	if err := reply.Unpack(raw); err != nil {
+		result.Error = fmt.Errorf("unpack reply: %w", err)
+		return result
+	}
+
+	// A truncated UDP answer is retried over TCP, as a stub resolver would
+	if reply.Truncated && transport == "udp" {
+		transport = "tcp"
+		raw, err = dnsExchange(transport, check.serverAddr(), packed, timeout)
+		if err != nil {
+			result.Error = fmt.Errorf("retry truncated reply: %w", err)
+			return result
+		}
+		if err := reply.Unpack(raw); err != nil {
+			result.Error = fmt.Errorf("unpack reply: %w", err)
+			return result
+		}
+	}
+	result.RTT = time.Since(start)
+	result.Timings = &storage.ProbeTimings{Total: result.RTT}
+
+	if reply.ID != id {
+		result.Error = fmt.Errorf("reply ID %d does not match query ID %d", reply.ID, id)
+		return result
+	}
+
+	rcode, ok := dnsRcodes[reply.RCode]
+	if !ok {
+		rcode = fmt.Sprintf("RCODE%d", reply.RCode)
+	}
+
+	detail := &storage.DNSDetail{
+		Server:    check.serverAddr(),
+		Query:     check.Query,
+		Type:      check.recordType(),
+		Transport: transport,
+		Rcode:     rcode,
+		Answers:   []string{},
+	}
+	for _, answer := range reply.Answers {
+		if text := formatDNSAnswer(answer.Body); text != "" {
+			detail.Answers = append(detail.Answers, text)
+		}
+	}
+	result.DNS = detail
+
+	if reply.RCode != dnsmessage.RCodeSuccess {
+		result.Error = fmt.Errorf("%s for %s %s", rcode, check.Query, check.recordType())
+		return result
+	}
+	if missing := missingAnswers(check.Expect, detail.Answers); len(missing) > 0 {
+		result.Error = fmt.Errorf("expected answers %v missing from %v", missing, detail.Answers)
+		return result
+	}
+
+	result.Success = true
+	return result
+}
+
+// dnsExchange sends a packed query over the given transport and returns
+// the packed reply
+func dnsExchange(transport, server string, query []byte, timeout time.Duration) ([]byte, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	switch transport {
+	case "udp":
+		conn, err = net.DialTimeout("udp", server, timeout)
+	case "tcp":
+		conn, err = net.DialTimeout("tcp", server, timeout)
+	case "tls":
+		host, _, _ := net.SplitHostPort(server)
+		dialer := &net.Dialer{Timeout: timeout}
+		conn, err = tls.DialWithDialer(dialer, "tcp", server, &tls.Config{ServerName: host})
+	default:
+		return nil, fmt.Errorf("unsupported DNS transport %q", transport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s over %s: %w", server, transport, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("set deadline: %w", err)
+	}
+
+	if transport == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, fmt.Errorf("send query: %w", err)
+		}
+		// Late answers to earlier queries are discarded until the reply
+		// to this one arrives or the deadline passes
+		reply := make([]byte, 65535)
+		for {
+			n, err := conn.Read(reply)
+			if err != nil {
+				return nil, fmt.Errorf("read reply: %w", err)
+			}
+			if n >= 2 && reply[0] == query[0] && reply[1] == query[1] {
+				return reply[:n], nil
+			}
+		}
+	}
+
+	// Stream transports prefix each message with a two byte length
+	framed := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(framed, uint16(len(query)))
+	copy(framed[2:], query)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, fmt.Errorf("send query: %w", err)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, fmt.Errorf("read reply length: %w", err)
+	}
+	reply := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, fmt.Errorf("read reply: %w", err)
+	}
+	return reply, nil
+}
+
+// formatDNSAnswer renders a resource record body in zone file style
+func formatDNSAnswer(body dnsmessage.ResourceBody) string {
+	switch rr := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(rr.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(rr.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return trimDot(rr.CNAME.String())
+	case *dnsmessage.NSResource:
+		return trimDot(rr.NS.String())
+	case *dnsmessage.PTRResource:
+		return trimDot(rr.PTR.String())
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", rr.Pref, trimDot(rr.MX.String()))
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", rr.Priority, rr.Weight, rr.Port, trimDot(rr.Target.String()))
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d", trimDot(rr.NS.String()), trimDot(rr.MBox.String()), rr.Serial)
+	case *dnsmessage.TXTResource:
+		return strings.Join(rr.TXT, "")
+	default:
+		return ""
+	}
+}
+
+// missingAnswers returns the expected answers not present in answers,
+// compared case-insensitively and ignoring trailing dots
+func missingAnswers(expected, answers []string) []string {
+	have := make(map[string]bool, len(answers))
+	for _, answer := range answers {
+		have[strings.ToLower(trimDot(answer))] = true
+	}
+
+	var missing []string
+	for _, want := range expected {
+		if !have[strings.ToLower(trimDot(want))] {
+			missing = append(missing, want)
+		}
+	}
+	return missing
+}
+
+// fqdn returns name as a fully qualified domain name
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func trimDot(name string) string {
+	return strings.TrimSuffix(name, ".")
+}
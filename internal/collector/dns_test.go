@@ -0,0 +1,278 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsServer answers queries over UDP and TCP on the same local port
+type dnsServer struct {
+	udp  net.PacketConn
+	tcp  net.Listener
+	port string
+}
+
+func startDNSServer(t *testing.T) *dnsServer {
+	t.Helper()
+	for attempt := 0; attempt < 10; attempt++ {
+		udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		port := strconv.Itoa(udp.LocalAddr().(*net.UDPAddr).Port)
+		tcp, err := net.Listen("tcp", "127.0.0.1:"+port)
+		if err != nil {
+			udp.Close()
+			continue
+		}
+		s := &dnsServer{udp: udp, tcp: tcp, port: port}
+		go s.serveUDP()
+		go s.serveTCP()
+		t.Cleanup(func() {
+			udp.Close()
+			tcp.Close()
+		})
+		return s
+	}
+	t.Fatal("no port free for both UDP and TCP")
+	return nil
+}
+
+func (s *dnsServer) serveUDP() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		reply := answerDNS(buf[:n], false)
+		if reply == nil {
+			continue
+		}
+		// "late.example." is preceded by the answer to an earlier query
+		if bytes.Contains(buf[:n], []byte("\x04late\x07example\x00")) {
+			stale := append([]byte(nil), reply...)
+			stale[1]++
+			s.udp.WriteTo(stale, addr)
+		}
+		s.udp.WriteTo(reply, addr)
+	}
+}
+
+func (s *dnsServer) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, query); err != nil {
+				return
+			}
+			reply := answerDNS(query, true)
+			framed := binary.BigEndian.AppendUint16(nil, uint16(len(reply)))
+			conn.Write(append(framed, reply...))
+		}()
+	}
+}
+
+// answerDNS serves a small zone. "big.example." only fits over TCP and
+// "wrongid.example." is only ever answered with a different ID.
+func answerDNS(data []byte, tcp bool) []byte {
+	var query dnsmessage.Message
+	if err := query.Unpack(data); err != nil || len(query.Questions) != 1 {
+		return nil
+	}
+	q := query.Questions[0]
+	reply := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionDesired: query.RecursionDesired, RecursionAvailable: true},
+		Questions: query.Questions,
+	}
+	header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 60}
+	a := func(ip string) dnsmessage.Resource {
+		var addr [4]byte
+		copy(addr[:], net.ParseIP(ip).To4())
+		return dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: addr}}
+	}
+
+	switch name := strings.ToLower(q.Name.String()); {
+	case (name == "host.example." || name == "late.example.") && q.Type == dnsmessage.TypeA:
+		reply.Answers = []dnsmessage.Resource{a("192.0.2.10"), a("192.0.2.11")}
+	case name == "example." && q.Type == dnsmessage.TypeMX:
+		reply.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mail.example.")}}}
+	case name == "example." && q.Type == dnsmessage.TypeTXT:
+		reply.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}}}}
+	case name == "big.example.":
+		if !tcp {
+			reply.Truncated = true
+			break
+		}
+		for i := 1; i <= 40; i++ {
+			reply.Answers = append(reply.Answers, a("198.51.100."+strconv.Itoa(i)))
+		}
+	case name == "wrongid.example.":
+		reply.ID++
+	default:
+		reply.RCode = dnsmessage.RCodeNameError
+	}
+	packed, _ := reply.Pack()
+	return packed
+}
+
+func TestDNSProbe(t *testing.T) {
+	server := startDNSServer(t)
+	addr := "127.0.0.1:" + server.port
+
+	tests := []struct {
+		name          string
+		check         DNSCheck
+		wantOK        bool
+		wantTransport string
+		wantRcode     string
+		wantAnswers   []string
+		wantErr       string
+	}{
+		{
+			name:          "A over UDP",
+			check:         DNSCheck{Server: addr, Query: "host.example", Expect: []string{"192.0.2.11"}},
+			wantOK:        true,
+			wantTransport: "udp",
+			wantRcode:     "NOERROR",
+			wantAnswers:   []string{"192.0.2.10", "192.0.2.11"},
+		},
+		{
+			name:          "A over TCP",
+			check:         DNSCheck{Server: addr, Query: "host.example.", Transport: "TCP"},
+			wantOK:        true,
+			wantTransport: "tcp",
+			wantRcode:     "NOERROR",
+			wantAnswers:   []string{"192.0.2.10", "192.0.2.11"},
+		},
+		{
+			name:          "MX",
+			check:         DNSCheck{Server: addr, Query: "example", Type: "mx", Expect: []string{"10 MAIL.example."}},
+			wantOK:        true,
+			wantTransport: "udp",
+			wantRcode:     "NOERROR",
+			wantAnswers:   []string{"10 mail.example"},
+		},
+		{
+			name:          "TXT",
+			check:         DNSCheck{Server: addr, Query: "example", Type: "TXT"},
+			wantOK:        true,
+			wantTransport: "udp",
+			wantRcode:     "NOERROR",
+			wantAnswers:   []string{"v=spf1 -all"},
+		},
+		{
+			name:          "expected answer missing",
+			check:         DNSCheck{Server: addr, Query: "host.example", Expect: []string{"192.0.2.99"}},
+			wantTransport: "udp",
+			wantRcode:     "NOERROR",
+			wantAnswers:   []string{"192.0.2.10", "192.0.2.11"},
+			wantErr:       "missing",
+		},
+		{
+			name:          "NXDOMAIN",
+			check:         DNSCheck{Server: addr, Query: "nope.example"},
+			wantTransport: "udp",
+			wantRcode:     "NXDOMAIN",
+			wantAnswers:   []string{},
+			wantErr:       "NXDOMAIN",
+		},
+		{
+			name:          "truncated reply retried over TCP",
+			check:         DNSCheck{Server: addr, Query: "big.example"},
+			wantOK:        true,
+			wantTransport: "tcp",
+			wantRcode:     "NOERROR",
+		},
+		{
+			name:          "late reply to an earlier query skipped",
+			check:         DNSCheck{Server: addr, Query: "late.example"},
+			wantOK:        true,
+			wantTransport: "udp",
+			wantRcode:     "NOERROR",
+			wantAnswers:   []string{"192.0.2.10", "192.0.2.11"},
+		},
+		{
+			name:    "no reply with the query ID",
+			check:   DNSCheck{Server: addr, Query: "wrongid.example", TimeoutMs: 200},
+			wantErr: "read reply",
+		},
+		{
+			name:    "reply ID mismatch over TCP",
+			check:   DNSCheck{Server: addr, Query: "wrongid.example", Transport: "tcp"},
+			wantErr: "does not match",
+		},
+		{
+			name:    "unsupported type",
+			check:   DNSCheck{Server: addr, Query: "host.example", Type: "HINFO"},
+			wantErr: "unsupported record type",
+		},
+		{
+			name:    "unsupported transport",
+			check:   DNSCheck{Server: addr, Query: "host.example", Transport: "quic"},
+			wantErr: "unsupported DNS transport",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := dnsProbe(tt.check)
+			if result.Success != tt.wantOK {
+				t.Fatalf("Success = %v (error %v), want %v", result.Success, result.Error, tt.wantOK)
+			}
+			if tt.wantErr != "" && (result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want it to contain %q", result.Error, tt.wantErr)
+			}
+			if tt.wantRcode == "" {
+				return
+			}
+			detail := result.DNS
+			if detail == nil {
+				t.Fatal("no DNS detail")
+			}
+			if detail.Transport != tt.wantTransport || detail.Rcode != tt.wantRcode {
+				t.Errorf("transport %s rcode %s, want %s %s", detail.Transport, detail.Rcode, tt.wantTransport, tt.wantRcode)
+			}
+			if tt.wantAnswers != nil && !slices.Equal(detail.Answers, tt.wantAnswers) {
+				t.Errorf("answers = %v, want %v", detail.Answers, tt.wantAnswers)
+			}
+			if tt.name == "truncated reply retried over TCP" && len(detail.Answers) != 40 {
+				t.Errorf("got %d answers over TCP, want 40", len(detail.Answers))
+			}
+		})
+	}
+}
+
+func TestDNSServerAddr(t *testing.T) {
+	tests := []struct {
+		check DNSCheck
+		want  string
+	}{
+		{DNSCheck{Server: "1.1.1.1"}, "1.1.1.1:53"},
+		{DNSCheck{Server: "1.1.1.1", Transport: "tls"}, "1.1.1.1:853"},
+		{DNSCheck{Server: "1.1.1.1:5353", Transport: "tls"}, "1.1.1.1:5353"},
+		{DNSCheck{Server: "2606:4700::1111"}, "[2606:4700::1111]:53"},
+	}
+	for _, tt := range tests {
+		if got := tt.check.serverAddr(); got != tt.want {
+			t.Errorf("serverAddr(%+v) = %s, want %s", tt.check, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package collector
+
+import "syscall"
+
+// setDontFragment makes the socket send every packet with the DF bit set,
+// ignoring the kernel's cached path MTU so larger probes still go out
+func setDontFragment(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package collector
+
+import "errors"
+
+// setDontFragment is only implemented on Linux
+func setDontFragment(fd uintptr) error {
+	return errors.New("path MTU discovery is only supported on Linux")
+}
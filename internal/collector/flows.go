@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"slices"
+	"time"
+
+	"network-monitor/internal/pcap"
+	"network-monitor/internal/storage"
+)
+
+// flowKey identifies a unidirectional flow
+type flowKey struct {
+	proto   uint8
+	src     string
+	dst     string
+	srcPort uint16
+	dstPort uint16
+}
+
+type flowState struct {
+	record storage.FlowRecord
+	flags  uint8
+}
+
+// maxTableFlows caps the flows a table holds, so that traffic with many
+// distinct 5-tuples cannot grow it without bound between flushes
+const maxTableFlows = storage.MaxFlowRecords
+
+// flowTable aggregates decoded packets into flow records
+type flowTable struct {
+	source string
+	flows  map[flowKey]*flowState
+}
+
+func newFlowTable(source string) *flowTable {
+	return &flowTable{source: source, flows: make(map[flowKey]*flowState)}
+}
+
+// add accounts one packet of the given length to its flow
+func (ft *flowTable) add(d pcap.Decoded, ts time.Time, length int) {
+	ft.addSampled(d, ts, length, 1)
+}
+
+// addSampled accounts a sampled packet that stands for rate packets of the
+// same length
+func (ft *flowTable) addSampled(d pcap.Decoded, ts time.Time, length int, rate uint64) {
+	key := flowKey{
+		proto:   d.Proto,
+		src:     d.SrcIP.String(),
+		dst:     d.DstIP.String(),
+		srcPort: d.SrcPort,
+		dstPort: d.DstPort,
+	}
+
+	flow, exists := ft.flows[key]
+	if !exists {
+		flow = &flowState{record: storage.FlowRecord{
+			Source:  ft.source,
+			Proto:   pcap.ProtoName(d.Proto),
+			SrcIP:   key.src,
+			DstIP:   key.dst,
+			SrcPort: int(d.SrcPort),
+			DstPort: int(d.DstPort),
+			SrcMAC:  d.SrcMAC,
+			DstMAC:  d.DstMAC,
+			Start:   ts,
+			End:     ts,
+		}}
+		ft.flows[key] = flow
+	}
+
+	flow.record.Bytes += uint64(length) * rate
+	flow.record.Packets += rate
+	flow.flags |= d.TCPFlags
+	if ts.Before(flow.record.Start) {
+		flow.record.Start = ts
+	}
+	if ts.After(flow.record.End) {
+		flow.record.End = ts
+	}
+}
+
+// full reports whether the table reached maxTableFlows and the oldest
+// flows should be flushed
+func (ft *flowTable) full() bool {
+	return len(ft.flows) >= maxTableFlows
+}
+
+// flush returns the accumulated flows and empties the table
+func (ft *flowTable) flush() []storage.FlowRecord {
+	records := make([]storage.FlowRecord, 0, len(ft.flows))
+	for _, flow := range ft.flows {
+		records = append(records, flow.finish())
+	}
+	ft.flows = make(map[flowKey]*flowState)
+	return records
+}
+
+// flushOldest removes and returns the half of the flows that have been
+// idle the longest
+func (ft *flowTable) flushOldest() []storage.FlowRecord {
+	keys := make([]flowKey, 0, len(ft.flows))
+	for key := range ft.flows {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b flowKey) int {
+		return ft.flows[a].record.End.Compare(ft.flows[b].record.End)
+	})
+
+	records := make([]storage.FlowRecord, 0, len(keys)/2+1)
+	for _, key := range keys[:len(keys)/2+1] {
+		records = append(records, ft.flows[key].finish())
+		delete(ft.flows, key)
+	}
+	return records
+}
+
+func (flow *flowState) finish() storage.FlowRecord {
+	if flow.flags != 0 {
+		flow.record.TCPFlags = pcap.FlagString(flow.flags)
+	}
+	return flow.record
+}
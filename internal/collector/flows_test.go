@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"network-monitor/internal/pcap"
+)
+
+func TestFlowTableFlushOldest(t *testing.T) {
+	table := newFlowTable("test")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	packet := func(port uint16) pcap.Decoded {
+		return pcap.Decoded{SrcIP: net.IPv4(10, 0, 0, 5), DstIP: net.IPv4(192, 0, 2, 1), Proto: 17, SrcPort: port, DstPort: 53}
+	}
+	for i := 0; i < maxTableFlows; i++ {
+		table.add(packet(uint16(i)), start.Add(time.Duration(i)*time.Millisecond), 100)
+	}
+	// Flow 0 is the oldest but still active
+	table.add(packet(0), start.Add(time.Hour), 100)
+	if !table.full() {
+		t.Fatalf("table with %d flows not full", len(table.flows))
+	}
+
+	flushed := table.flushOldest()
+	if len(flushed)+len(table.flows) != maxTableFlows || table.full() {
+		t.Fatalf("flushed %d flows, %d left", len(flushed), len(table.flows))
+	}
+	for _, record := range flushed {
+		if record.SrcPort == 0 {
+			t.Fatal("flushed the most recently active flow")
+		}
+		if record.SrcPort > maxTableFlows/2+1 {
+			t.Fatalf("flushed flow from port %d before older ones", record.SrcPort)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"network-monitor/internal/storage"
+)
+
+// maxHTTPBody caps how much of a response body is read for assertions
+const maxHTTPBody = 1 << 20
+
+// HTTPCheck describes a synthetic HTTP(S) request and the assertions its
+// response has to satisfy for the check to count as successful.
+type HTTPCheck struct {
+	Name            string            `json:"name"`
+	URL             string            `json:"url"`
+	Method          string            `json:"method,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Body            string            `json:"body,omitempty"`
+	TimeoutMs       int               `json:"timeout_ms,omitempty"`
+	FollowRedirects bool              `json:"follow_redirects,omitempty"`
+	SkipVerify      bool              `json:"insecure_skip_verify,omitempty"`
+
+	// Assertions. An empty ExpectStatus accepts any 2xx or 3xx status; an
+	// empty JSONValue only asserts that JSONPath exists.
+	ExpectStatus []int  `json:"expect_status,omitempty"`
+	BodyContains string `json:"body_contains,omitempty"`
+	BodyRegex    string `json:"body_regex,omitempty"`
+	JSONPath     string `json:"json_path,omitempty"`
+	JSONValue    string `json:"json_value,omitempty"`
+
+	bodyRegex *regexp.Regexp
+}
+
+// compile prepares the body regex, so a bad pattern is rejected when the
+// check is added instead of failing every probe
+func (c *HTTPCheck) compile() error {
+	if c.BodyRegex == "" {
+		return nil
+	}
+	re, err := regexp.Compile(c.BodyRegex)
+	if err != nil {
+		return fmt.Errorf("invalid body_regex: %w", err)
+	}
+	c.bodyRegex = re
+	return nil
+}
+
+// key returns the name the check's results are stored under
+func (c HTTPCheck) key() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.URL
+}
+
+// httpProbe performs the check's request, timing each phase with httptrace
+func httpProbe(check HTTPCheck) PingResult {
+	result := PingResult{Host: check.key(), Method: "HTTP"}
+
+	timeout := 10 * time.Second
+	if check.TimeoutMs > 0 {
+		timeout = time.Duration(check.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if check.Body != "" {
+		body = strings.NewReader(check.Body)
+	}
+
+	var (
+		start                            time.Time
+		dnsStart, connectStart, tlsStart time.Time
+		timings                          storage.ProbeTimings
+	)
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			if !dnsStart.IsZero() {
+				timings.DNS = time.Since(dnsStart)
+			}
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			if !connectStart.IsZero() {
+				timings.Connect = time.Since(connectStart)
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			if !tlsStart.IsZero() {
+				timings.TLS = time.Since(tlsStart)
+			}
+		},
+		GotFirstResponseByte: func() { timings.FirstByte = time.Since(start) },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), method, check.URL, body)
+	if err != nil {
+		result.Error = fmt.Errorf("build request: %w", err)
+		return result
+	}
+	for name, value := range check.Headers {
+		req.Header.Set(name, value)
+	}
+
+	// A fresh transport per probe so every run measures a full
+	// DNS + connect + handshake instead of reusing a pooled connection
+	transport := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		DisableKeepAlives: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: check.SkipVerify},
+	}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{Transport: transport}
+	if !check.FollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	start = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		timings.Total = time.Since(start)
+		result.Timings = &timings
+		result.Error = fmt.Errorf("request %s: %w", check.URL, err)
+		return result
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	timings.Total = time.Since(start)
+
+	result.RTT = timings.Total
+	result.Timings = &timings
+	result.HTTP = &storage.HTTPDetail{
+		URL:        check.URL,
+		StatusCode: resp.StatusCode,
+		BodyBytes:  len(respBody),
+	}
+
+	if err != nil {
+		result.Error = fmt.Errorf("read body: %w", err)
+		return result
+	}
+
+	if err := check.assert(resp.StatusCode, respBody); err != nil {
+		result.Error = err
+		return result
+	}
+
+	result.Success = true
+	return result
+}
+
+// assert checks the response against the configured assertions
+func (c HTTPCheck) assert(status int, body []byte) error {
+	if len(c.ExpectStatus) > 0 {
+		matched := false
+		for _, code := range c.ExpectStatus {
+			if code == status {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("status %d, expected one of %v", status, c.ExpectStatus)
+		}
+	} else if status < 200 || status >= 400 {
+		return fmt.Errorf("status %d", status)
+	}
+
+	if c.BodyContains != "" && !strings.Contains(string(body), c.BodyContains) {
+		return fmt.Errorf("body does not contain %q", c.BodyContains)
+	}
+
+	if c.bodyRegex != nil && !c.bodyRegex.Match(body) {
+		return fmt.Errorf("body does not match /%s/", c.BodyRegex)
+	}
+
+	if c.JSONPath != "" {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return fmt.Errorf("body is not JSON: %w", err)
+		}
+		value, err := lookupJSONPath(doc, c.JSONPath)
+		if err != nil {
+			return err
+		}
+		if got := jsonString(value); c.JSONValue != "" && got != c.JSONValue {
+			return fmt.Errorf("%s = %q, expected %q", c.JSONPath, got, c.JSONValue)
+		}
+	}
+
+	return nil
+}
+
+// lookupJSONPath resolves a dotted path such as "$.data.items[0].name"
+// (or "data.items.0.name") against a decoded JSON document
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	current := doc
+	for _, part := range strings.Split(path, ".") {
+		if part == "" {
+			continue
+		}
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[part]
+			if !ok {
+				return nil, fmt.Errorf("JSON path %s: key %q not found", path, part)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("JSON path %s: bad index %q", path, part)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("JSON path %s: cannot descend into %q", path, part)
+		}
+	}
+	return current, nil
+}
+
+// jsonString renders a decoded JSON value the way it would be written in
+// a check definition: strings bare, everything else as JSON
+func jsonString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,201 @@
+package collector
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"status":"ok","data":{"items":[{"name":"a","up":true},{"name":"b","count":3}]}}`), &doc)
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "$.status", want: "ok"},
+		{path: "status", want: "ok"},
+		{path: "$.data.items[1].name", want: "b"},
+		{path: "data.items.0.up", want: "true"},
+		{path: "$.data.items[1].count", want: "3"},
+		{path: "$.data.items[0]", want: `{"name":"a","up":true}`},
+		{path: "$", want: jsonString(doc)},
+		{path: "$.missing", wantErr: true},
+		{path: "$.data.items[2]", wantErr: true},
+		{path: "$.data.items[x]", wantErr: true},
+		{path: "$.status.deeper", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, err := lookupJSONPath(doc, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupJSONPath(%q) error = %v, want error %v", tt.path, err, tt.wantErr)
+			}
+			if err == nil && jsonString(value) != tt.want {
+				t.Errorf("lookupJSONPath(%q) = %s, want %s", tt.path, jsonString(value), tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status":"healthy","version":"1.2.3","checks":[{"db":"up"}]}`))
+		case "/moved":
+			http.Redirect(w, r, "/health", http.StatusFound)
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Write([]byte(r.Method + " " + string(body)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	auth := map[string]string{"Authorization": "Bearer token"}
+	tests := []struct {
+		name       string
+		check      HTTPCheck
+		wantOK     bool
+		wantStatus int
+		wantErr    string
+	}{
+		{
+			name:       "plain 200",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth},
+			wantOK:     true,
+			wantStatus: 200,
+		},
+		{
+			name:       "missing header",
+			check:      HTTPCheck{URL: server.URL + "/health"},
+			wantStatus: 401,
+			wantErr:    "status 401",
+		},
+		{
+			name:       "expected status",
+			check:      HTTPCheck{URL: server.URL + "/nope", ExpectStatus: []int{404}},
+			wantOK:     true,
+			wantStatus: 404,
+		},
+		{
+			name:       "unexpected status",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth, ExpectStatus: []int{201, 204}},
+			wantStatus: 200,
+			wantErr:    "expected one of",
+		},
+		{
+			name:       "body contains",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth, BodyContains: "healthy"},
+			wantOK:     true,
+			wantStatus: 200,
+		},
+		{
+			name:       "body does not contain",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth, BodyContains: "degraded"},
+			wantStatus: 200,
+			wantErr:    "does not contain",
+		},
+		{
+			name:       "body regex",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth, BodyRegex: `"version":"1\.\d+\.\d+"`},
+			wantOK:     true,
+			wantStatus: 200,
+		},
+		{
+			name:       "body does not match",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth, BodyRegex: `"version":"2\.`},
+			wantStatus: 200,
+			wantErr:    "does not match",
+		},
+		{
+			name:       "JSON path",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth, JSONPath: "$.checks[0].db", JSONValue: "up"},
+			wantOK:     true,
+			wantStatus: 200,
+		},
+		{
+			name:       "JSON value differs",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth, JSONPath: "$.status", JSONValue: "down"},
+			wantStatus: 200,
+			wantErr:    `expected "down"`,
+		},
+		{
+			name:       "JSON path present",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth, JSONPath: "$.version"},
+			wantOK:     true,
+			wantStatus: 200,
+		},
+		{
+			name:       "JSON path absent",
+			check:      HTTPCheck{URL: server.URL + "/health", Headers: auth, JSONPath: "$.uptime"},
+			wantStatus: 200,
+			wantErr:    "uptime",
+		},
+		{
+			name:       "redirect not followed",
+			check:      HTTPCheck{URL: server.URL + "/moved", ExpectStatus: []int{302}},
+			wantOK:     true,
+			wantStatus: 302,
+		},
+		{
+			name:       "redirect followed",
+			check:      HTTPCheck{URL: server.URL + "/moved", Headers: auth, FollowRedirects: true},
+			wantOK:     true,
+			wantStatus: 200,
+		},
+		{
+			name:       "method and body",
+			check:      HTTPCheck{URL: server.URL + "/echo", Method: "POST", Body: "ping", BodyContains: "POST ping"},
+			wantOK:     true,
+			wantStatus: 200,
+		},
+		{
+			name:    "connection refused",
+			check:   HTTPCheck{URL: "http://127.0.0.1:1/", TimeoutMs: 500},
+			wantErr: "request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check.compile(); err != nil {
+				t.Fatal(err)
+			}
+			result := httpProbe(tt.check)
+			if result.Success != tt.wantOK {
+				t.Fatalf("Success = %v (error %v), want %v", result.Success, result.Error, tt.wantOK)
+			}
+			if tt.wantErr != "" && (result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want it to contain %q", result.Error, tt.wantErr)
+			}
+			if tt.wantStatus != 0 {
+				if result.HTTP == nil || result.HTTP.StatusCode != tt.wantStatus {
+					t.Fatalf("HTTP detail = %+v, want status %d", result.HTTP, tt.wantStatus)
+				}
+				if result.Timings == nil || result.Timings.Total <= 0 || result.Timings.FirstByte <= 0 {
+					t.Errorf("timings = %+v, want total and first byte", result.Timings)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPCheckRejectsBadRegex(t *testing.T) {
+	check := HTTPCheck{URL: "http://example.com/", BodyRegex: `(`}
+	if err := check.compile(); err == nil || !strings.Contains(err.Error(), "body_regex") {
+		t.Errorf("compile() = %v, want a body_regex error", err)
+	}
+}
@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultExclude keeps the loopback interfaces (Linux and Windows) out of
+// the traffic stats when no exclude rules are configured
+var defaultExclude = []string{"lo", "Loopback Pseudo-Interface 1"}
+
+// InterfaceFilter selects the interfaces the traffic collector records.
+// Patterns are shell globs ("veth*", "br-*"), or regular expressions when
+// wrapped in slashes ("/^(eth|en)[0-9]+$/").
+type InterfaceFilter struct {
+	// Include limits collection to matching interfaces; empty means all
+	Include []string `json:"include"`
+	// Exclude drops matching interfaces, even if included. Defaults to
+	// the loopback interfaces.
+	Exclude []string `json:"exclude"`
+	// Groups maps a group name such as "uplinks" or "containers" to the
+	// patterns of its member interfaces
+	Groups map[string][]string `json:"groups"`
+	// Totals selects the interfaces summed into the dashboard totals, by
+	// pattern or group name; empty means every collected interface
+	Totals []string `json:"totals"`
+}
+
+// patternList is a compiled set of interface name patterns
+type patternList struct {
+	globs   []string
+	regexps []*regexp.Regexp
+}
+
+func compilePatterns(patterns []string) (patternList, error) {
+	var list patternList
+	for _, pattern := range patterns {
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return list, fmt.Errorf("invalid interface pattern %s: %w", pattern, err)
+			}
+			list.regexps = append(list.regexps, re)
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return list, fmt.Errorf("invalid interface pattern %q: %w", pattern, err)
+		}
+		list.globs = append(list.globs, pattern)
+	}
+	return list, nil
+}
+
+func (l patternList) empty() bool {
+	return len(l.globs) == 0 && len(l.regexps) == 0
+}
+
+func (l patternList) match(name string) bool {
+	for _, glob := range l.globs {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	for _, re := range l.regexps {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// interfaceRules is the compiled form of an InterfaceFilter
+type interfaceRules struct {
+	include     patternList
+	exclude     patternList
+	groups      map[string]patternList
+	totals      patternList
+	totalGroups []string
+}
+
+func compileInterfaceFilter(filter InterfaceFilter) (*interfaceRules, error) {
+	rules := &interfaceRules{groups: make(map[string]patternList)}
+
+	exclude := filter.Exclude
+	if exclude == nil {
+		exclude = defaultExclude
+	}
+
+	var err error
+	if rules.include, err = compilePatterns(filter.Include); err != nil {
+		return nil, err
+	}
+	if rules.exclude, err = compilePatterns(exclude); err != nil {
+		return nil, err
+	}
+	for name, patterns := range filter.Groups {
+		if rules.groups[name], err = compilePatterns(patterns); err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+	}
+
+	// Totals entries naming a group select that group's members
+	var totals []string
+	for _, entry := range filter.Totals {
+		if _, isGroup := filter.Groups[entry]; isGroup {
+			rules.totalGroups = append(rules.totalGroups, entry)
+		} else {
+			totals = append(totals, entry)
+		}
+	}
+	if rules.totals, err = compilePatterns(totals); err != nil {
+		return nil, fmt.Errorf("totals: %w", err)
+	}
+	return rules, nil
+}
+
+// collects reports whether the traffic of an interface is recorded
+func (r *interfaceRules) collects(name string) bool {
+	if !r.include.empty() && !r.include.match(name) {
+		return false
+	}
+	return !r.exclude.match(name)
+}
+
+// membership sorts the collected interfaces into their groups and picks the
+// ones counted toward the totals. A nil totals list means all of them.
+func (r *interfaceRules) membership(names []string) (map[string][]string, []string) {
+	sort.Strings(names)
+
+	groups := make(map[string][]string, len(r.groups))
+	for group, patterns := range r.groups {
+		members := []string{}
+		for _, name := range names {
+			if patterns.match(name) {
+				members = append(members, name)
+			}
+		}
+		groups[group] = members
+	}
+
+	if r.totals.empty() && len(r.totalGroups) == 0 {
+		return groups, nil
+	}
+
+	totals := []string{}
+	for _, name := range names {
+		counted := r.totals.match(name)
+		for _, group := range r.totalGroups {
+			if !counted && r.groups[group].match(name) {
+				counted = true
+			}
+		}
+		if counted {
+			totals = append(totals, name)
+		}
+	}
+	return groups, totals
+}
@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"network-monitor/internal/storage"
+)
+
+// MTRCollector keeps probing every hop of the path to critical targets so
+// that per-hop latency and loss can be tracked over time, like mtr does
+type MTRCollector struct {
+	store   *storage.Store
+	targets []string
+	opts    TraceOptions
+	hops    map[string]int // path length found by the last round
+}
+
+func NewMTRCollector(store *storage.Store, targets []string, opts TraceOptions) *MTRCollector {
+	if opts.Mode == "" {
+		opts.Mode = TraceModeICMP
+	}
+	return &MTRCollector{store: store, targets: targets, opts: opts.withDefaults(), hops: make(map[string]int)}
+}
+
+func (mc *MTRCollector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("MTR collector started for %v", mc.targets)
+
+	for {
+		mc.collectRound(interval)
+		<-ticker.C
+	}
+}
+
+// collectRound probes all targets at once, each with one probe per hop
+// sent together, so a round lasts one probe timeout and never more than
+// budget
+func (mc *MTRCollector) collectRound(budget time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	traces := make([]*storage.TraceRoute, len(mc.targets))
+	var wg sync.WaitGroup
+	for i, target := range mc.targets {
+		hops := mc.hops[target]
+		if hops == 0 {
+			hops = mc.opts.MaxHops
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			trace, err := sweepPath(ctx, target, mc.opts, hops)
+			if err != nil {
+				log.Printf("MTR round to %s failed: %v", target, err)
+				return
+			}
+			traces[i] = trace
+		}()
+	}
+	wg.Wait()
+
+	for i, trace := range traces {
+		if trace == nil {
+			continue
+		}
+		// Later rounds only probe as far as the destination; a round that
+		// misses it looks along the whole path again
+		if trace.Reached {
+			mc.hops[mc.targets[i]] = len(trace.Hops)
+		} else {
+			delete(mc.hops, mc.targets[i])
+		}
+		mc.store.StoreMTRRound(*trace)
+	}
+}
+
+// sweepPath sends one probe to each TTL up to hops without waiting in
+// between, then collects the replies that arrive within the probe timeout
+func sweepPath(ctx context.Context, host string, opts TraceOptions, hops int) (*storage.TraceRoute, error) {
+	dst, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve IP: %w", err)
+	}
+	t, err := newTracer(dst.IP, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer t.close()
+
+	trace := &storage.TraceRoute{
+		Target:    host,
+		Address:   dst.IP.String(),
+		Mode:      opts.Mode,
+		StartedAt: time.Now(),
+	}
+
+	// The TTL doubles as the sequence number; each round has its own
+	// tracer, so replies to earlier rounds do not match
+	sent := make([]time.Time, hops+1)
+	results := make([]storage.TraceHop, hops+1)
+	for ttl := 1; ttl <= hops; ttl++ {
+		results[ttl] = storage.TraceHop{TTL: ttl, RTTs: []time.Duration{}}
+		sent[ttl] = time.Now()
+		if err := t.send(ttl, ttl); err != nil {
+			return nil, err
+		}
+		results[ttl].Sent = 1
+	}
+
+	deadline := time.Now().Add(t.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := t.icmp.SetReadDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("set deadline: %w", err)
+	}
+
+	end := hops // the destination, or the router that ended the path
+	buf := make([]byte, 1500)
+	for pending := hops; pending > 0; {
+		n, peer, err := t.icmp.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		ttl, reply, ok := t.parseReply(buf[:n], peer)
+		if !ok || ttl < 1 || ttl > hops || results[ttl].Received > 0 {
+			continue
+		}
+		hop := &results[ttl]
+		hop.Received = 1
+		hop.Address = reply.addr
+		hop.RTTs = append(hop.RTTs, time.Since(sent[ttl]))
+		pending--
+		if (reply.reached || reply.stop) && ttl <= end {
+			end = ttl
+			trace.Reached = reply.reached
+		}
+	}
+
+	trace.Hops = results[1 : end+1]
+	for i := range trace.Hops {
+		summarizeHop(&trace.Hops[i])
+	}
+	trace.Duration = time.Since(trace.StartedAt)
+	return trace, nil
+}
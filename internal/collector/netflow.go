@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"log"
+	"net"
+
+	"network-monitor/internal/netflow"
+	"network-monitor/internal/pcap"
+	"network-monitor/internal/storage"
+)
+
+// maxNetFlowExporters caps the exporters accepted when no allowed
+// exporters are configured; UDP source addresses can be spoofed
+const maxNetFlowExporters = 256
+
+// NetFlowCollector receives NetFlow v5, v9 and IPFIX exports over UDP and
+// stores their records under the source "netflow:<exporter>"
+type NetFlowCollector struct {
+	store     *storage.Store
+	addr      string
+	decoder   *netflow.Decoder
+	allowed   map[string]bool // exporter addresses accepted; empty accepts any
+	exporters map[string]bool
+	warnings  senderWarnings
+}
+
+// NewNetFlowCollector accepts exports from the given exporter addresses,
+// or from the first maxNetFlowExporters exporters heard when none are given
+func NewNetFlowCollector(store *storage.Store, addr string, exporters []string) *NetFlowCollector {
+	nc := &NetFlowCollector{
+		store:     store,
+		addr:      addr,
+		decoder:   netflow.NewDecoder(),
+		allowed:   make(map[string]bool),
+		exporters: make(map[string]bool),
+	}
+	for _, exporter := range exporters {
+		if ip := net.ParseIP(exporter); ip != nil {
+			exporter = ip.String()
+		}
+		nc.allowed[exporter] = true
+	}
+	return nc
+}
+
+// Start listens on the configured UDP address until the socket fails
+func (nc *NetFlowCollector) Start() {
+	conn, err := net.ListenPacket("udp", nc.addr)
+	if err != nil {
+		log.Printf("NetFlow listener on %s unavailable: %v", nc.addr, err)
+		return
+	}
+	defer conn.Close()
+
+	log.Printf("NetFlow collector started on %s", nc.addr)
+
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Printf("NetFlow listener on %s stopped: %v", nc.addr, err)
+			return
+		}
+		exporter := addr.String()
+		if udp, ok := addr.(*net.UDPAddr); ok {
+			exporter = udp.IP.String()
+		}
+		nc.ingest(buf[:n], exporter)
+	}
+}
+
+// ingest decodes one export datagram and stores its records
+func (nc *NetFlowCollector) ingest(packet []byte, exporter string) {
+	if !nc.accept(exporter) {
+		return
+	}
+	missing, dropped := nc.decoder.MissingTemplate, nc.decoder.DroppedTemplates
+	records, err := nc.decoder.Decode(packet, exporter)
+	if err != nil {
+		nc.warnings.warnOnce(exporter, "Malformed NetFlow export from %s: %v", exporter, err)
+	}
+	if nc.decoder.MissingTemplate > missing {
+		nc.warnings.warnOnce(exporter, "NetFlow data from %s before its template; records dropped until the template is resent", exporter)
+	}
+	if nc.decoder.DroppedTemplates > dropped {
+		nc.warnings.warnOnce(exporter, "Ignoring NetFlow templates from %s: too many templates known", exporter)
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	flows := make([]storage.FlowRecord, 0, len(records))
+	for _, r := range records {
+		flows = append(flows, netflowRecord(r, "netflow:"+exporter))
+	}
+	nc.store.AddFlows(flows)
+}
+
+// accept reports whether exports from exporter are to be decoded
+func (nc *NetFlowCollector) accept(exporter string) bool {
+	if len(nc.allowed) > 0 && !nc.allowed[exporter] {
+		nc.warnings.warnOnce(exporter, "Ignoring NetFlow from %s, which is not in the allowed exporters", exporter)
+		return false
+	}
+	if !nc.exporters[exporter] {
+		if len(nc.exporters) >= maxNetFlowExporters {
+			nc.warnings.warnOnce(exporter, "Ignoring NetFlow from %s: already receiving from %d exporters", exporter, maxNetFlowExporters)
+			return false
+		}
+		nc.exporters[exporter] = true
+	}
+	return true
+}
+
+// netflowRecord converts a decoded export record to a flow record
+func netflowRecord(r netflow.Record, source string) storage.FlowRecord {
+	record := storage.FlowRecord{
+		Source:  source,
+		Proto:   pcap.ProtoName(r.Proto),
+		SrcPort: int(r.SrcPort),
+		DstPort: int(r.DstPort),
+		SrcMAC:  r.SrcMAC,
+		DstMAC:  r.DstMAC,
+		Bytes:   r.Bytes,
+		Packets: r.Packets,
+		Start:   r.Start,
+		End:     r.End,
+	}
+	if r.SrcAddr != nil {
+		record.SrcIP = r.SrcAddr.String()
+	}
+	if r.DstAddr != nil {
+		record.DstIP = r.DstAddr.String()
+	}
+	if r.Proto == pcap.ProtoTCP && r.TCPFlags != 0 {
+		record.TCPFlags = pcap.FlagString(r.TCPFlags)
+	}
+	return record
+}
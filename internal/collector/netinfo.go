@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"network-monitor/internal/storage"
+)
+
+const sysClassNet = "/sys/class/net"
+
+// NetworkInfoCollector snapshots interface configuration, link state and
+// the routing table, and records what changed between snapshots
+type NetworkInfoCollector struct {
+	store  *storage.Store
+	routes []storage.Route // last routing table read successfully
+}
+
+func NewNetworkInfoCollector(store *storage.Store) *NetworkInfoCollector {
+	return &NetworkInfoCollector{store: store}
+}
+
+func (nc *NetworkInfoCollector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Network info collector started")
+
+	for {
+		nc.collectNetworkInfo()
+		<-ticker.C
+	}
+}
+
+func (nc *NetworkInfoCollector) collectNetworkInfo() {
+	snapshot := storage.NetworkSnapshot{
+		Timestamp:  time.Now(),
+		Interfaces: readInterfaceInfo(),
+	}
+
+	// A failed read keeps the previous routes, so it is not reported as
+	// every route being removed and added back
+	routes, err := readRoutes()
+	if err != nil {
+		log.Printf("Error reading routing table: %v", err)
+		routes = nc.routes
+	}
+	nc.routes = routes
+	snapshot.Routes = routes
+
+	for _, change := range nc.store.UpdateNetworkSnapshot(snapshot) {
+		log.Printf("Network change: %s", change.Detail)
+	}
+}
+
+// readInterfaceInfo lists the host's interfaces with their addresses, and
+// on Linux the link details exposed in /sys/class/net
+func readInterfaceInfo() []storage.InterfaceInfo {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		log.Println("Error fetching interfaces:", err)
+		return nil
+	}
+
+	infos := make([]storage.InterfaceInfo, 0, len(ifaces))
+	for _, iface := range ifaces {
+		info := storage.InterfaceInfo{
+			Name:      iface.Name,
+			Index:     iface.Index,
+			MAC:       iface.HardwareAddr.String(),
+			MTU:       iface.MTU,
+			SpeedMbps: -1,
+			Addresses: []string{},
+			Type:      "virtual",
+			OperState: "down",
+		}
+		if iface.Flags&net.FlagUp != 0 {
+			info.OperState = "up"
+		}
+		if iface.Flags&net.FlagLoopback != 0 {
+			info.Type = "loopback"
+		}
+
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			info.Addresses = append(info.Addresses, addr.String())
+		}
+
+		if runtime.GOOS == "linux" {
+			readSysfsInfo(&info)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// readSysfsInfo fills in operstate, speed, duplex and interface type
+func readSysfsInfo(info *storage.InterfaceInfo) {
+	dir := filepath.Join(sysClassNet, info.Name)
+
+	if state := readSysfs(dir, "operstate"); state != "" {
+		info.OperState = state
+	}
+	// speed and duplex fail to read while the link is down
+	if speed, err := strconv.Atoi(readSysfs(dir, "speed")); err == nil && speed > 0 {
+		info.SpeedMbps = speed
+	}
+	if duplex := readSysfs(dir, "duplex"); duplex != "" && duplex != "unknown" {
+		info.Duplex = duplex
+	}
+
+	if info.Type != "loopback" {
+		info.Type = sysfsInterfaceType(dir)
+	}
+}
+
+// sysfsInterfaceType classifies an interface from its sysfs entries
+func sysfsInterfaceType(dir string) string {
+	devType := ""
+	for _, line := range strings.Split(readSysfs(dir, "uevent"), "\n") {
+		if value, ok := strings.CutPrefix(line, "DEVTYPE="); ok {
+			devType = value
+		}
+	}
+
+	switch {
+	case exists(filepath.Join(dir, "bridge")) || devType == "bridge":
+		return "bridge"
+	case exists(filepath.Join(dir, "bonding")) || devType == "bond":
+		return "bond"
+	case devType == "vlan":
+		return "vlan"
+	case devType == "wlan" || exists(filepath.Join(dir, "wireless")):
+		return "wireless"
+	case exists(filepath.Join(dir, "tun_flags")):
+		// ARPHRD_ETHER means a layer 2 tap device
+		if readSysfs(dir, "type") == "1" {
+			return "tap"
+		}
+		return "tun"
+	case exists(filepath.Join(dir, "device")):
+		return "physical"
+	case devType != "":
+		return devType
+	}
+
+	// A veth end links to its peer's index rather than its own
+	if ifindex, iflink := readSysfs(dir, "ifindex"), readSysfs(dir, "iflink"); ifindex != "" && ifindex != iflink {
+		return "veth"
+	}
+	return "virtual"
+}
+
+func readSysfs(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"network-monitor/internal/storage"
+)
+
+// stackGauges are the fields of /proc/net/snmp that hold current values or
+// settings rather than cumulative counters
+var stackGauges = map[string]bool{
+	"Ip.Forwarding":        true,
+	"Ip.DefaultTTL":        true,
+	"Tcp.RtoAlgorithm":     true,
+	"Tcp.RtoMin":           true,
+	"Tcp.RtoMax":           true,
+	"Tcp.MaxConn":          true,
+	"Tcp.CurrEstab":        true,
+	"MPTcpExt.MPCurrEstab": true,
+}
+
+// NetstatCollector reads the kernel's TCP/IP protocol counters
+type NetstatCollector struct {
+	store *storage.Store
+}
+
+func NewNetstatCollector(store *storage.Store) *NetstatCollector {
+	return &NetstatCollector{store: store}
+}
+
+func (nc *NetstatCollector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Netstat collector started")
+
+	for {
+		nc.collectNetstat()
+		<-ticker.C
+	}
+}
+
+func (nc *NetstatCollector) collectNetstat() {
+	values := make(map[string]int64)
+	for _, path := range []string{"/proc/net/snmp", "/proc/net/netstat"} {
+		if err := readProtoCounters(path, values); err != nil {
+			log.Printf("Error reading protocol counters: %v", err)
+			return
+		}
+	}
+	nc.store.UpdateStackMetrics(values, stackGauges)
+}
+
+// readProtoCounters parses the header/value line pairs of /proc/net/snmp
+// and /proc/net/netstat into values keyed "Proto.Field"
+func readProtoCounters(path string, values map[string]int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		header := strings.Fields(scanner.Text())
+		if !scanner.Scan() {
+			break
+		}
+		data := strings.Fields(scanner.Text())
+		if len(header) == 0 {
+			continue
+		}
+		if len(header) != len(data) || header[0] != data[0] {
+			return fmt.Errorf("%s: malformed %s lines", path, strings.TrimSuffix(header[0], ":"))
+		}
+
+		proto := strings.TrimSuffix(header[0], ":")
+		for i := 1; i < len(header); i++ {
+			// Tcp.MaxConn is -1, and the largest counters exceed int64
+			if value, err := strconv.ParseInt(data[i], 10, 64); err == nil {
+				values[proto+"."+header[i]] = value
+			} else if value, err := strconv.ParseUint(data[i], 10, 64); err == nil {
+				values[proto+"."+header[i]] = int64(value)
+			}
+		}
+	}
+	return scanner.Err()
+}
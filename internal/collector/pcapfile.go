@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"network-monitor/internal/pcap"
+	"network-monitor/internal/storage"
+)
+
+// PcapSummary describes an ingested capture file
+type PcapSummary struct {
+	Source  string    `json:"source"`
+	Packets int       `json:"packets"`
+	Skipped int       `json:"skipped"` // non-IP or undecodable packets
+	Bytes   uint64    `json:"bytes"`
+	Flows   int       `json:"flows"`
+	Devices int       `json:"devices"` // local hosts seen with a MAC address
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// IngestPcap reads a pcap or pcapng capture, reconstructs its flows and
+// loads them into the store under the source "pcap:<name>". With devices
+// set, local hosts seen with a MAC address are also added to the device
+// table with the traffic they sent and received; that is only right when
+// the store holds nothing but the capture, as live devices and bandwidth
+// would be overwritten with historical data.
+func IngestPcap(store *storage.Store, r io.Reader, name string, devices bool) (*PcapSummary, error) {
+	reader, err := pcap.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &PcapSummary{Source: "pcap:" + name}
+	table := newFlowTable(summary.Source)
+	usage := make(map[string]*storage.DeviceBytes)
+	macs := make(map[string]string)
+	addFlows := func(records []storage.FlowRecord) {
+		summary.Flows += len(records)
+		store.AddFlows(records)
+		captureDevices(usage, macs, records)
+	}
+	for {
+		packet, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Keep what was read from a truncated capture
+			if summary.Packets == 0 {
+				return nil, fmt.Errorf("read capture: %w", err)
+			}
+			break
+		}
+		summary.Packets++
+
+		decoded, err := pcap.Decode(packet.LinkType, packet.Data)
+		if err != nil {
+			summary.Skipped++
+			continue
+		}
+		length := decoded.Length
+		if length == 0 {
+			length = packet.Length
+		}
+		table.add(decoded, packet.Timestamp, length)
+		summary.Bytes += uint64(length)
+		// Store the flows idle the longest rather than holding every
+		// flow of a large capture in memory
+		if table.full() {
+			addFlows(table.flushOldest())
+		}
+
+		if summary.Start.IsZero() || packet.Timestamp.Before(summary.Start) {
+			summary.Start = packet.Timestamp
+		}
+		if packet.Timestamp.After(summary.End) {
+			summary.End = packet.Timestamp
+		}
+	}
+
+	addFlows(table.flush())
+	summary.Devices = len(usage)
+	if devices && len(usage) > 0 {
+		for ip, mac := range macs {
+			store.UpdateDevice(ip, mac, "")
+		}
+		// Traffic is averaged over the capture duration
+		store.UpdateDeviceBandwidth(usage, summary.End.Sub(summary.Start).Seconds())
+	}
+	return summary, nil
+}
+
+// captureDevices adds the traffic of the local hosts in records to usage
+// and their MAC addresses to macs
+func captureDevices(usage map[string]*storage.DeviceBytes, macs map[string]string, records []storage.FlowRecord) {
+	device := func(ip, mac string) *storage.DeviceBytes {
+		if mac == "" || !isLocalAddress(ip) {
+			return nil
+		}
+		entry, exists := usage[ip]
+		if !exists {
+			entry = &storage.DeviceBytes{}
+			usage[ip] = entry
+			macs[ip] = mac
+		}
+		return entry
+	}
+
+	for _, r := range records {
+		if entry := device(r.SrcIP, r.SrcMAC); entry != nil {
+			entry.Up += r.Bytes
+			entry.Flows++
+		}
+		if entry := device(r.DstIP, r.DstMAC); entry != nil {
+			entry.Down += r.Bytes
+			entry.Flows++
+		}
+	}
+}
+
+// isLocalAddress reports whether ip belongs to a private or link-local range
+func isLocalAddress(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && (addr.IsPrivate() || addr.IsLinkLocalUnicast())
+}
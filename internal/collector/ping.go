@@ -19,31 +19,41 @@ type PingResult struct {
     Host     string
     RTT      time.Duration
     Success  bool
-    Method   string // "ICMP" or "TCP"
+    Method   string // "ICMP", "TCP", "HTTP", "TLS" or "DNS"
     Error    error
+    Timings  *storage.ProbeTimings
+    HTTP     *storage.HTTPDetail
+    TLS      *storage.TLSDetail
+    DNS      *storage.DNSDetail
 }
 
 // PingCollector handles ping monitoring
 type PingCollector struct {
     store   *storage.Store
-    targets []string
+    targets []Target
+    gateway string          // address of the gateway target, if any
+    routes  []storage.Route // default routes seen on the last check
 }
 
 // NewPingCollector creates a new ping collector
 func NewPingCollector(store *storage.Store) *PingCollector {
-    targets := []string{"8.8.8.8", "1.1.1.1", "127.0.0.1"}
-    
+    pc := &PingCollector{store: store}
+    pc.targets = append(pc.targets, defaultTargets()...)
+
     // Add gateway IP if available
-    if gateway := getGatewayIP(); gateway != "" {
-        targets = append([]string{gateway}, targets...)
-        log.Printf("Gateway IP detected: %s", gateway)
-    }
-    
-    log.Printf("OS: %s, Initialized ping collector with targets: %v", runtime.GOOS, targets)
-    
-    return &PingCollector{
-        store:   store,
-        targets: targets,
+    pc.refreshGateway()
+
+    log.Printf("OS: %s, Initialized ping collector with targets: %v", runtime.GOOS, pc.targetKeys())
+
+    return pc
+}
+
+// defaultTargets are probed when no targets are configured
+func defaultTargets() []Target {
+    return []Target{
+        {Host: "8.8.8.8", Probe: ProbeICMP},
+        {Host: "1.1.1.1", Probe: ProbeICMP},
+        {Host: "127.0.0.1", Probe: ProbeICMP},
     }
 }
 
@@ -62,58 +72,160 @@ func (pc *PingCollector) Start(interval time.Duration) {
     }
 }
 
-// collectPingData performs ping tests on all targets
-func (pc *PingCollector) collectPingData() {
+// SetTargets replaces the default targets with the configured ones. The
+// detected gateway target is kept.
+func (pc *PingCollector) SetTargets(targets []Target) {
+    pc.targets = nil
+    if pc.gateway != "" {
+        pc.targets = append(pc.targets, gatewayTarget(pc.gateway))
+    }
+    for _, target := range targets {
+        pc.AddTarget(target)
+    }
+    pc.store.SetPingTargets(pc.targetKeys())
+}
+
+// AddTarget registers a target, rejecting it if its probe settings are
+// incomplete
+func (pc *PingCollector) AddTarget(target Target) {
+    if err := target.validate(); err != nil {
+        log.Printf("Skipping target %s: %v", target.Key(), err)
+        return
+    }
+    pc.targets = append(pc.targets, target)
+    log.Printf("Added %s target %s", target.Probe, target.Key())
+}
+
+// AddHTTPCheck registers a synthetic HTTP(S) check
+func (pc *PingCollector) AddHTTPCheck(check HTTPCheck) {
+    if err := check.compile(); err != nil {
+        log.Printf("Skipping target %s: %v", check.key(), err)
+        return
+    }
+    pc.AddTarget(Target{Name: check.key(), Host: check.URL, Probe: ProbeHTTP, HTTP: &check})
+}
+
+// AddTLSCheck registers a TLS endpoint whose certificate is monitored
+func (pc *PingCollector) AddTLSCheck(check TLSCheck) {
+    pc.AddTarget(Target{Name: check.key(), Host: check.Host, Probe: ProbeTLS, TLS: &check})
+}
+
+// AddDNSCheck registers a query against a specific resolver
+func (pc *PingCollector) AddDNSCheck(check DNSCheck) {
+    pc.AddTarget(Target{Name: check.key(), Host: check.Server, Probe: ProbeDNS, DNS: &check})
+}
+
+func (pc *PingCollector) targetKeys() []string {
+    keys := make([]string, 0, len(pc.targets))
+    for _, target := range pc.targets {
+        keys = append(keys, target.Key())
+    }
+    return keys
+}
+
+// gatewayTarget returns the ICMP target that follows the default gateway
+func gatewayTarget(address string) Target {
+    return Target{Host: address, Probe: ProbeICMP, gateway: true}
+}
+
+// refreshGateway re-reads the default routes and points the gateway target
+// at the primary IPv4 gateway, so it follows network switches
+func (pc *PingCollector) refreshGateway() {
+    routes, err := defaultRoutes()
+    if err != nil {
+        log.Printf("Could not read default routes: %v", err)
+        return
+    }
+
+    if !sameRoutes(routes, pc.routes) {
+        for _, route := range routes {
+            log.Printf("Default route: %s via %s dev %s metric %d", route.Family, route.Gateway, route.Interface, route.Metric)
+        }
+        if len(routes) == 0 {
+            log.Printf("No default route found")
+        }
+        pc.routes = routes
+        pc.store.SetDefaultRoutes(routes)
+    }
+
+    gateway := ""
+    for _, route := range routes {
+        if route.Family == "ipv4" {
+            gateway = route.Gateway
+            break
+        }
+    }
+    if gateway == pc.gateway {
+        return
+    }
+
+    // Swap the gateway target in place of the old one
+    targets := []Target{}
+    if gateway != "" {
+        targets = append(targets, gatewayTarget(gateway))
+    }
     for _, target := range pc.targets {
-        result := pingHost(target)
-        
-        // Store the ping result
-        pc.store.StorePingData(target, result.RTT, result.Success, result.Method)
-        
-        if result.Success {
-            log.Printf("✓ %s ping to %s: RTT = %v", result.Method, result.Host, result.RTT)
-        } else {
-            log.Printf("✗ Ping to %s failed: %v", result.Host, result.Error)
+        if !target.gateway {
+            targets = append(targets, target)
         }
     }
+    pc.targets = targets
+    pc.store.SetPingTargets(pc.targetKeys())
+
+    if pc.gateway == "" {
+        log.Printf("Gateway IP detected: %s", gateway)
+    } else {
+        log.Printf("Gateway changed from %s to %q", pc.gateway, gateway)
+    }
+    pc.gateway = gateway
 }
 
-// pingHost attempts ICMP ping first, then falls back to TCP ping
-func pingHost(host string) PingResult {
-    // Try ICMP first
-    rtt, err := pingICMP(host)
-    if err == nil {
-        return PingResult{
-            Host:    host,
-            RTT:     rtt,
-            Success: true,
-            Method:  "ICMP",
+func sameRoutes(a, b []storage.Route) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
         }
     }
+    return true
+}
+
+// collectPingData performs ping tests on all targets
+func (pc *PingCollector) collectPingData() {
+    pc.refreshGateway()
 
-    log.Printf("ICMP ping failed for %s: %v", host, err)
-    log.Printf("Trying TCP ping fallback...")
+    for _, target := range pc.targets {
+        result := runProbe(target)
+        pc.record(target.Key(), result)
 
-    // Try TCP ping on common ports
-    tcpPorts := []string{"80", "443", "53", "22"}
-    
-    for _, port := range tcpPorts {
-        rtt, err := tcpPing(host, port, 3*time.Second)
-        if err == nil {
-            return PingResult{
-                Host:    host,
-                RTT:     rtt,
-                Success: true,
-                Method:  fmt.Sprintf("TCP:%s", port),
-            }
+        if target.Probe == ProbeTLS {
+            pc.checkTLSAlerts(target.Key(), *target.TLS, result)
         }
     }
+}
+
+// record stores a probe result under key and logs the outcome
+func (pc *PingCollector) record(key string, result PingResult) {
+    sample := storage.PingSample{
+        Latency: result.RTT,
+        Success: result.Success,
+        Method:  result.Method,
+        Timings: result.Timings,
+        HTTP:    result.HTTP,
+        TLS:     result.TLS,
+        DNS:     result.DNS,
+    }
+    if result.Error != nil {
+        sample.Error = result.Error.Error()
+    }
+    pc.store.StoreProbeResult(key, sample)
 
-    return PingResult{
-        Host:    host,
-        Success: false,
-        Method:  "FAILED",
-        Error:   fmt.Errorf("both ICMP and TCP ping failed"),
+    if result.Success {
+        log.Printf("✓ %s ping to %s: RTT = %v", result.Method, result.Host, result.RTT)
+    } else {
+        log.Printf("✗ Ping to %s failed: %v", result.Host, result.Error)
     }
 }
 
@@ -125,22 +237,9 @@ func pingICMP(host string) (time.Duration, error) {
         return 0, fmt.Errorf("resolve IP: %w", err)
     }
 
-    // Create ICMP connection
-    var conn *icmp.PacketConn
-    if runtime.GOOS == "windows" {
-        // Windows typically allows unprivileged ICMP
-        conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-    } else {
-        // Unix systems usually require root for raw sockets
-        conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-        if err != nil {
-            // Try unprivileged ping socket (Linux 3.0+)
-            conn, err = icmp.ListenPacket("udp4", "0.0.0.0")
-        }
-    }
-    
+    conn, privileged, err := listenICMP()
     if err != nil {
-        return 0, fmt.Errorf("listen ICMP (may need root/admin): %w", err)
+        return 0, err
     }
     defer conn.Close()
 
@@ -160,9 +259,15 @@ func pingICMP(host string) (time.Duration, error) {
         return 0, fmt.Errorf("marshal ICMP: %w", err)
     }
 
+    // Unprivileged ping sockets are addressed like UDP sockets
+    var dst net.Addr = ipAddr
+    if !privileged {
+        dst = &net.UDPAddr{IP: ipAddr.IP}
+    }
+
     // Send ping
     start := time.Now()
-    _, err = conn.WriteTo(msgBytes, ipAddr)
+    _, err = conn.WriteTo(msgBytes, dst)
     if err != nil {
         return 0, fmt.Errorf("send ICMP: %w", err)
     }
@@ -173,53 +278,102 @@ func pingICMP(host string) (time.Duration, error) {
         return 0, fmt.Errorf("set deadline: %w", err)
     }
 
-    // Read reply
+    // Read replies until ours arrives. Raw sockets see every ICMP packet
+    // on the host, including our own request when pinging loopback.
     reply := make([]byte, 1500)
-    n, peer, err := conn.ReadFrom(reply)
-    if err != nil {
-        return 0, fmt.Errorf("read ICMP reply: %w", err)
+    for {
+        n, peer, err := conn.ReadFrom(reply)
+        if err != nil {
+            return 0, fmt.Errorf("read ICMP reply: %w", err)
+        }
+
+        duration := time.Since(start)
+
+        // Ignore traffic from other hosts
+        if peerIP := addrIP(peer); !peerIP.Equal(ipAddr.IP) {
+            continue
+        }
+
+        // Parse ICMP reply
+        parsedMsg, err := parseICMP(reply[:n])
+        if err != nil {
+            return 0, err
+        }
+
+        // Check if it's an echo reply
+        switch parsedMsg.Type {
+        case ipv4.ICMPTypeEchoReply:
+            // Verify it's our ping. The kernel rewrites the ID on
+            // unprivileged sockets and only delivers replies addressed
+            // to this socket.
+            if echo, ok := parsedMsg.Body.(*icmp.Echo); ok {
+                if !privileged || echo.ID == (os.Getpid() & 0xffff) {
+                    return duration, nil
+                }
+            }
+        case ipv4.ICMPTypeEcho:
+            // Our own request looped back
+        case ipv4.ICMPTypeDestinationUnreachable:
+            return 0, fmt.Errorf("destination unreachable")
+        case ipv4.ICMPTypeTimeExceeded:
+            return 0, fmt.Errorf("time exceeded")
+        default:
+            return 0, fmt.Errorf("unexpected ICMP type: %v", parsedMsg.Type)
+        }
     }
+}
 
-    duration := time.Since(start)
+// listenICMP opens an ICMP socket, preferring a raw socket and falling back
+// to the unprivileged ping socket on Unix. privileged reports which one was
+// opened; only raw sockets receive ICMP errors such as time exceeded.
+func listenICMP() (conn *icmp.PacketConn, privileged bool, err error) {
+    if runtime.GOOS == "windows" {
+        // Windows typically allows unprivileged ICMP
+        conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+    } else {
+        // Unix systems usually require root for raw sockets
+        conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+        if err != nil {
+            // Try unprivileged ping socket (Linux 3.0+)
+            conn, err = icmp.ListenPacket("udp4", "0.0.0.0")
+            if err == nil {
+                return conn, false, nil
+            }
+        }
+    }
 
-    // Verify the reply is from our target
-    if peer.String() != ipAddr.String() {
-        return 0, fmt.Errorf("reply from wrong host: got %s, expected %s", peer.String(), ipAddr.String())
+    if err != nil {
+        return nil, false, fmt.Errorf("listen ICMP (may need root/admin): %w", err)
     }
+    return conn, true, nil
+}
 
-    // Parse ICMP reply
-    var parsedMsg *icmp.Message
+// parseICMP parses an ICMP message read from a socket opened by listenICMP
+func parseICMP(packet []byte) (*icmp.Message, error) {
     if runtime.GOOS == "windows" {
         // Windows includes IP header in raw socket
-        if n < 20 {
-            return 0, fmt.Errorf("reply too short")
+        if len(packet) < 20 {
+            return nil, fmt.Errorf("reply too short")
         }
-        parsedMsg, err = icmp.ParseMessage(1, reply[20:n]) // 1 is the ICMP protocol number
-    } else {
-        parsedMsg, err = icmp.ParseMessage(1, reply[:n]) // 1 is the ICMP protocol number
+        packet = packet[20:]
     }
-    
+
+    msg, err := icmp.ParseMessage(1, packet) // 1 is the ICMP protocol number
     if err != nil {
-        return 0, fmt.Errorf("parse ICMP reply: %w", err)
+        return nil, fmt.Errorf("parse ICMP reply: %w", err)
     }
+    return msg, nil
+}
 
-    // Check if it's an echo reply
-    switch parsedMsg.Type {
-    case ipv4.ICMPTypeEchoReply:
-        // Verify it's our ping
-        if echo, ok := parsedMsg.Body.(*icmp.Echo); ok {
-            if echo.ID == (os.Getpid() & 0xffff) {
-                return duration, nil
-            }
-        }
-        return 0, fmt.Errorf("echo reply ID mismatch")
-    case ipv4.ICMPTypeDestinationUnreachable:
-        return 0, fmt.Errorf("destination unreachable")
-    case ipv4.ICMPTypeTimeExceeded:
-        return 0, fmt.Errorf("time exceeded")
-    default:
-        return 0, fmt.Errorf("unexpected ICMP type: %v", parsedMsg.Type)
+// addrIP extracts the IP from the address types returned by ICMP sockets
+func addrIP(addr net.Addr) net.IP {
+    switch a := addr.(type) {
+    case *net.IPAddr:
+        return a.IP
+    case *net.UDPAddr:
+        return a.IP
     }
+    return nil
 }
 
 // tcpPing performs TCP connectivity test
@@ -236,59 +390,3 @@ func tcpPing(host, port string, timeout time.Duration) (time.Duration, error) {
     duration := time.Since(start)
     return duration, nil
 }
-
-// getGatewayIP detects the default gateway IP address
-func getGatewayIP() string {
-    log.Println("Detecting gateway IP...")
-    
-    // Try to get default route
-    conn, err := net.Dial("udp", "8.8.8.8:80")
-    if err != nil {
-        log.Printf("Could not detect gateway via UDP dial: %v", err)
-        return ""
-    }
-    defer conn.Close()
-    
-    localAddr := conn.LocalAddr().(*net.UDPAddr)
-    localIP := localAddr.IP.String()
-    
-    // Parse the local IP to determine likely gateway
-    ip := net.ParseIP(localIP)
-    if ip == nil {
-        return ""
-    }
-    
-    // For typical home networks, gateway is usually x.x.x.1
-    if ip.To4() != nil {
-        octets := ip.To4()
-        gateway := fmt.Sprintf("%d.%d.%d.1", octets[0], octets[1], octets[2])
-        
-        // Verify this IP responds before returning it
-        if isValidGateway(gateway) {
-            log.Printf("Gateway IP found: %s", gateway)
-            return gateway
-        }
-    }
-    
-    log.Printf("No valid gateway IP found")
-    return ""
-}
-
-// isValidGateway checks if the IP responds to ping or TCP
-func isValidGateway(ip string) bool {
-    // Quick TCP check on port 80 or 53
-    conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, "80"), 2*time.Second)
-    if err == nil {
-        conn.Close()
-        return true
-    }
-    
-    // Try DNS port
-    conn, err = net.DialTimeout("tcp", net.JoinHostPort(ip, "53"), 2*time.Second)
-    if err == nil {
-        conn.Close()
-        return true
-    }
-    
-    return false
-}
\ No newline at end of file
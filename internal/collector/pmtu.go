@@ -0,0 +1,263 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"network-monitor/internal/storage"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// minPMTUProbe is the smallest packet used to check basic reachability
+	minPMTUProbe = 84
+	// ipICMPHeaderLen is the IPv4 plus ICMP echo header overhead
+	ipICMPHeaderLen = 28
+	// pmtuAttempts is how often a size is retried before it counts as lost
+	pmtuAttempts = 2
+	// pmtuBlackHoleRuns is the number of consecutive runs that must find
+	// a black hole before it is alerted on, so a lost probe is not enough
+	pmtuBlackHoleRuns = 2
+)
+
+// probeOutcome is what happened to a single DF-flagged echo
+type probeOutcome int
+
+const (
+	probeOK     probeOutcome = iota // echo reply received
+	probeTooBig                     // EMSGSIZE locally or ICMP fragmentation needed
+	probeSilent                     // no answer at all
+)
+
+// PMTUCollector periodically discovers the path MTU to selected targets
+type PMTUCollector struct {
+	store   *storage.Store
+	targets []string
+	maxMTU  int
+	timeout time.Duration
+}
+
+func NewPMTUCollector(store *storage.Store, targets []string, maxMTU int) *PMTUCollector {
+	if maxMTU <= 0 {
+		maxMTU = 1500
+	}
+	return &PMTUCollector{store: store, targets: targets, maxMTU: maxMTU, timeout: time.Second}
+}
+
+func (mc *PMTUCollector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("PMTU collector started for %v", mc.targets)
+
+	for {
+		mc.collectPMTU()
+		<-ticker.C
+	}
+}
+
+func (mc *PMTUCollector) collectPMTU() {
+	for _, target := range mc.targets {
+		result := discoverPMTU(target, mc.maxMTU, mc.timeout)
+		stats := mc.store.StorePMTU(target, result)
+
+		if result.Error != "" {
+			log.Printf("PMTU discovery to %s failed: %s", target, result.Error)
+			continue
+		}
+		log.Printf("PMTU to %s: %d bytes", target, result.PMTU)
+		mc.checkPMTUAlerts(target, stats, result)
+	}
+}
+
+// checkPMTUAlerts raises alerts when the PMTU is below the expected size,
+// until it recovers, or large packets keep vanishing without any ICMP
+// feedback
+func (mc *PMTUCollector) checkPMTUAlerts(target string, stats storage.PMTUStats, result storage.PMTUResult) {
+	dropKey := "pmtu-drop:" + target
+	if result.PMTU < stats.Expected {
+		mc.store.RaiseAlert(dropKey, "pmtu", target, storage.SeverityWarning,
+			fmt.Sprintf("Path MTU to %s dropped from %d to %d bytes", target, stats.Expected, result.PMTU))
+	} else {
+		mc.store.ResolveAlert(dropKey)
+	}
+
+	blackHoleKey := "pmtu-blackhole:" + target
+	if stats.BlackHoleRuns >= pmtuBlackHoleRuns {
+		mc.store.RaiseAlert(blackHoleKey, "pmtu", target, storage.SeverityCritical,
+			fmt.Sprintf("Packets to %s larger than %d bytes are silently dropped (MTU black hole)", target, result.PMTU))
+	} else {
+		mc.store.ResolveAlert(blackHoleKey)
+	}
+}
+
+// pmtuProber sends DF-flagged echoes of a chosen size to one target
+type pmtuProber struct {
+	conn    net.PacketConn
+	dst     *net.IPAddr
+	id      int
+	seq     int
+	timeout time.Duration
+
+	fragNeeded bool // a router answered with fragmentation needed
+	hintMTU    int  // next-hop MTU from the last fragmentation needed
+}
+
+// discoverPMTU binary searches the largest packet size that reaches host
+// with DF set. Sizes are whole IPv4 packet sizes, as reported by
+// "ping -M do -s".
+func discoverPMTU(host string, maxMTU int, timeout time.Duration) storage.PMTUResult {
+	result := storage.PMTUResult{Timestamp: time.Now(), MaxProbed: maxMTU}
+
+	dst, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		result.Error = fmt.Sprintf("resolve IP: %v", err)
+		return result
+	}
+
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) { sockErr = setDontFragment(fd) }); err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+	conn, err := lc.ListenPacket(context.Background(), "ip4:icmp", "0.0.0.0")
+	if err != nil {
+		result.Error = fmt.Sprintf("listen ICMP with DF (may need root): %v", err)
+		return result
+	}
+	defer conn.Close()
+
+	p := &pmtuProber{conn: conn, dst: dst, id: rand.Intn(0xffff), timeout: timeout}
+
+	if p.try(minPMTUProbe) != probeOK {
+		result.Error = fmt.Sprintf("%s does not answer %d byte pings", host, minPMTUProbe)
+		return result
+	}
+
+	// lo always fits, hi never does. The first probe tries the full size.
+	lo, hi := minPMTUProbe, maxMTU+1
+	size := maxMTU
+	silent := false
+	for hi-lo > 1 {
+		switch p.try(size) {
+		case probeOK:
+			lo = size
+		case probeTooBig:
+			hi = size
+		case probeSilent:
+			hi = size
+			silent = true
+		}
+
+		size = (lo + hi) / 2
+		// Jump straight to the MTU a router told us about when it is usable
+		if p.hintMTU > lo && p.hintMTU < hi {
+			size = p.hintMTU
+			p.hintMTU = 0
+		}
+	}
+
+	result.PMTU = lo
+	result.FragNeeded = p.fragNeeded
+	// Small pings work but larger ones vanish without the fragmentation
+	// needed message that PMTU discovery relies on
+	result.BlackHole = silent && !p.fragNeeded && lo < maxMTU
+	return result
+}
+
+// try sends a packet of the given size, retrying once on silence
+func (p *pmtuProber) try(size int) probeOutcome {
+	outcome := probeSilent
+	for attempt := 0; attempt < pmtuAttempts; attempt++ {
+		outcome = p.send(size)
+		if outcome != probeSilent {
+			return outcome
+		}
+	}
+	return outcome
+}
+
+func (p *pmtuProber) send(size int) probeOutcome {
+	p.seq++
+	seq := p.seq
+
+	payload := make([]byte, size-ipICMPHeaderLen)
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: p.id, Seq: seq, Data: payload},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return probeSilent
+	}
+
+	if _, err := p.conn.WriteTo(data, p.dst); err != nil {
+		if errors.Is(err, syscall.EMSGSIZE) {
+			// Larger than the local interface MTU
+			return probeTooBig
+		}
+		return probeSilent
+	}
+
+	if err := p.conn.SetReadDeadline(time.Now().Add(p.timeout)); err != nil {
+		return probeSilent
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, peer, err := p.conn.ReadFrom(buf)
+		if err != nil {
+			return probeSilent
+		}
+		packet := buf[:n]
+		if len(packet) < 8 {
+			continue
+		}
+
+		switch {
+		case packet[0] == byte(ipv4.ICMPTypeEchoReply):
+			if addrIP(peer).Equal(p.dst.IP) &&
+				int(binary.BigEndian.Uint16(packet[4:6])) == p.id &&
+				int(binary.BigEndian.Uint16(packet[6:8])) == seq {
+				return probeOK
+			}
+		case packet[0] == byte(ipv4.ICMPTypeDestinationUnreachable) && packet[1] == 4:
+			// Fragmentation needed; bytes 6-7 carry the next-hop MTU
+			if p.quotesProbe(packet[8:], seq) {
+				p.fragNeeded = true
+				if mtu := int(binary.BigEndian.Uint16(packet[6:8])); mtu >= minPMTUProbe {
+					p.hintMTU = mtu
+				}
+				return probeTooBig
+			}
+		}
+	}
+}
+
+// quotesProbe checks whether an ICMP error quotes the echo with seq
+func (p *pmtuProber) quotesProbe(quoted []byte, seq int) bool {
+	if len(quoted) < 20 {
+		return false
+	}
+	headerLen := int(quoted[0]&0x0f) * 4
+	if len(quoted) < headerLen+8 || quoted[9] != 1 {
+		return false
+	}
+	echo := quoted[headerLen:]
+	return echo[0] == byte(ipv4.ICMPTypeEcho) &&
+		int(binary.BigEndian.Uint16(echo[4:6])) == p.id &&
+		int(binary.BigEndian.Uint16(echo[6:8])) == seq
+}
@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"testing"
+
+	"network-monitor/internal/storage"
+)
+
+func TestPMTUDropAlertPersists(t *testing.T) {
+	s := storage.NewStore()
+	mc := &PMTUCollector{store: s}
+	steps := []struct {
+		pmtu      int
+		wantAlert bool
+	}{
+		// Lower than expected from the very first run
+		{1400, true},
+		{1500, false},
+		// A lasting drop stays alerted after it becomes the baseline
+		{1400, true},
+		{1400, true},
+		{1400, true},
+		{1400, true},
+		{1500, false},
+	}
+	for i, step := range steps {
+		result := storage.PMTUResult{PMTU: step.pmtu, MaxProbed: 1500}
+		mc.checkPMTUAlerts("192.0.2.1", s.StorePMTU("192.0.2.1", result), result)
+		active := len(s.GetAlerts(true)) == 1
+		if active != step.wantAlert {
+			t.Errorf("run %d (PMTU %d): drop alert active %v, want %v", i, step.pmtu, active, step.wantAlert)
+		}
+	}
+	if n := len(s.GetAlerts(false)); n != 2 {
+		t.Errorf("%d drop alerts fired, want 2", n)
+	}
+}
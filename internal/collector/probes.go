@@ -0,0 +1,264 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// Probe types a Target can declare
+const (
+	ProbeICMP = "icmp"
+	ProbeTCP  = "tcp"
+	ProbeUDP  = "udp"
+	ProbeHTTP = "http"
+	ProbeTLS  = "tls"
+	ProbeDNS  = "dns"
+)
+
+// maxProbeResponse caps how much of a TCP/UDP response is read for
+// matching against Expect
+const maxProbeResponse = 4096
+
+// Target is a monitored endpoint together with the probe used to check it.
+// The HTTP, TLS and DNS probes take their settings from the matching
+// sub-struct; ICMP, TCP and UDP use the fields on Target directly.
+type Target struct {
+	Name      string `json:"name,omitempty"`
+	Host      string `json:"host"`
+	Probe     string `json:"probe"`
+	Port      string `json:"port,omitempty"`
+	Payload   string `json:"payload,omitempty"` // tcp/udp: data sent after connecting
+	Expect    string `json:"expect,omitempty"`  // tcp/udp: regex the response must match
+	TimeoutMs int    `json:"timeout_ms,omitempty"`
+
+	HTTP *HTTPCheck `json:"http,omitempty"`
+	TLS  *TLSCheck  `json:"tls,omitempty"`
+	DNS  *DNSCheck  `json:"dns,omitempty"`
+
+	gateway bool // follows the detected default gateway
+}
+
+// Key returns the name the target's results are stored under. ICMP
+// targets keep the bare host so existing dashboards and exports line up.
+func (t Target) Key() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	switch t.Probe {
+	case ProbeTCP, ProbeUDP:
+		return net.JoinHostPort(t.Host, t.Port) + "/" + t.Probe
+	case ProbeHTTP:
+		if t.HTTP != nil {
+			return t.HTTP.key()
+		}
+	case ProbeTLS:
+		if t.TLS != nil {
+			return t.TLS.key()
+		}
+	case ProbeDNS:
+		if t.DNS != nil {
+			return t.DNS.key()
+		}
+	}
+	return t.Host
+}
+
+func (t Target) timeout() time.Duration {
+	if t.TimeoutMs > 0 {
+		return time.Duration(t.TimeoutMs) * time.Millisecond
+	}
+	return 3 * time.Second
+}
+
+// validate reports configuration mistakes before the target is scheduled
+func (t Target) validate() error {
+	switch t.Probe {
+	case ProbeICMP:
+		if t.Host == "" {
+			return fmt.Errorf("icmp target needs a host")
+		}
+	case ProbeTCP, ProbeUDP:
+		if t.Host == "" || t.Port == "" {
+			return fmt.Errorf("%s target needs a host and port", t.Probe)
+		}
+		if t.Expect != "" {
+			if _, err := regexp.Compile(t.Expect); err != nil {
+				return fmt.Errorf("invalid expect pattern: %w", err)
+			}
+		}
+	case ProbeHTTP:
+		if t.HTTP == nil || t.HTTP.URL == "" {
+			return fmt.Errorf("http target needs an http.url")
+		}
+	case ProbeTLS:
+		if t.TLS == nil || t.TLS.Host == "" {
+			return fmt.Errorf("tls target needs a tls.host")
+		}
+	case ProbeDNS:
+		if t.DNS == nil || t.DNS.Server == "" || t.DNS.Query == "" {
+			return fmt.Errorf("dns target needs dns.server and dns.query")
+		}
+	default:
+		return fmt.Errorf("unknown probe type %q", t.Probe)
+	}
+	return nil
+}
+
+// runProbe checks a target with its declared probe type
+func runProbe(t Target) PingResult {
+	var result PingResult
+
+	switch t.Probe {
+	case ProbeICMP:
+		result = PingResult{Host: t.Host, Method: "ICMP"}
+		rtt, err := pingICMP(t.Host)
+		if err != nil {
+			result.Error = err
+		} else {
+			result.RTT = rtt
+			result.Success = true
+		}
+	case ProbeTCP:
+		result = tcpProbe(t)
+	case ProbeUDP:
+		result = udpProbe(t)
+	case ProbeHTTP:
+		result = httpProbe(*t.HTTP)
+	case ProbeTLS:
+		result = tlsProbe(*t.TLS)
+	case ProbeDNS:
+		result = dnsProbe(*t.DNS)
+	default:
+		result = PingResult{Host: t.Host, Method: "FAILED", Error: fmt.Errorf("unknown probe type %q", t.Probe)}
+	}
+
+	result.Host = t.Key()
+	return result
+}
+
+// tcpProbe connects to the target port and, when configured, sends a
+// payload and waits for a response matching Expect
+func tcpProbe(t Target) PingResult {
+	result := PingResult{Host: t.Host, Method: fmt.Sprintf("TCP:%s", t.Port)}
+
+	if t.Payload == "" && t.Expect == "" {
+		rtt, err := tcpPing(t.Host, t.Port, t.timeout())
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		result.RTT = rtt
+		result.Success = true
+		return result
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(t.Host, t.Port), t.timeout())
+	if err != nil {
+		result.Error = fmt.Errorf("TCP connect to %s:%s: %w", t.Host, t.Port, err)
+		return result
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(start.Add(t.timeout())); err != nil {
+		result.Error = fmt.Errorf("set deadline: %w", err)
+		return result
+	}
+
+	if t.Payload != "" {
+		if _, err := conn.Write([]byte(t.Payload)); err != nil {
+			result.Error = fmt.Errorf("send payload: %w", err)
+			return result
+		}
+	}
+
+	if t.Expect == "" {
+		result.RTT = time.Since(start)
+		result.Success = true
+		return result
+	}
+
+	// Keep reading until the pattern matches; banners and replies may
+	// arrive split across several segments
+	re := regexp.MustCompile(t.Expect)
+	var response []byte
+	buf := make([]byte, 1024)
+	for len(response) < maxProbeResponse {
+		n, err := conn.Read(buf)
+		response = append(response, buf[:n]...)
+		if re.Match(response) {
+			result.RTT = time.Since(start)
+			result.Success = true
+			return result
+		}
+		if err != nil {
+			result.Error = fmt.Errorf("response %q does not match /%s/: %w", truncate(response, 64), t.Expect, err)
+			return result
+		}
+	}
+
+	result.Error = fmt.Errorf("response %q does not match /%s/", truncate(response, 64), t.Expect)
+	return result
+}
+
+// udpProbe sends the payload to the target port. A reply (matching Expect,
+// if set) or an ICMP port-unreachable both prove the host is up; silence
+// until the timeout counts as a failure.
+func udpProbe(t Target) PingResult {
+	result := PingResult{Host: t.Host, Method: fmt.Sprintf("UDP:%s", t.Port)}
+
+	// A connected UDP socket surfaces ICMP port-unreachable as ECONNREFUSED
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(t.Host, t.Port), t.timeout())
+	if err != nil {
+		result.Error = fmt.Errorf("UDP dial %s:%s: %w", t.Host, t.Port, err)
+		return result
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(t.timeout())); err != nil {
+		result.Error = fmt.Errorf("set deadline: %w", err)
+		return result
+	}
+
+	if _, err := conn.Write([]byte(t.Payload)); err != nil {
+		result.Error = fmt.Errorf("send payload: %w", err)
+		return result
+	}
+
+	reply := make([]byte, maxProbeResponse)
+	n, err := conn.Read(reply)
+	rtt := time.Since(start)
+
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) && t.Expect == "" {
+			result.RTT = rtt
+			result.Success = true
+			result.Method += " (port unreachable)"
+			return result
+		}
+		result.Error = fmt.Errorf("no UDP reply from %s:%s: %w", t.Host, t.Port, err)
+		return result
+	}
+
+	if t.Expect != "" && !regexp.MustCompile(t.Expect).Match(reply[:n]) {
+		result.Error = fmt.Errorf("reply %q does not match /%s/", truncate(reply[:n], 64), t.Expect)
+		return result
+	}
+
+	result.RTT = rtt
+	result.Success = true
+	return result
+}
+
+// truncate shortens a response for inclusion in error messages
+func truncate(data []byte, max int) string {
+	if len(data) > max {
+		return string(data[:max]) + "..."
+	}
+	return string(data)
+}
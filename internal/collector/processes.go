@@ -0,0 +1,188 @@
+package collector
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"network-monitor/internal/storage"
+)
+
+// maxCmdline caps the command line kept per process
+const maxCmdline = 256
+
+// ProcessCollector attributes sockets, and the traffic on them, to the
+// processes that own them
+type ProcessCollector struct {
+	store *storage.Store
+
+	prevBytes map[uint64]socketBytes
+	prevTime  time.Time
+	warned    bool
+}
+
+func NewProcessCollector(store *storage.Store) *ProcessCollector {
+	return &ProcessCollector{store: store}
+}
+
+func (pc *ProcessCollector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Process collector started")
+
+	for {
+		pc.collectProcesses()
+		<-ticker.C
+	}
+}
+
+func (pc *ProcessCollector) collectProcesses() {
+	sockets, err := readSockets()
+	if err != nil {
+		log.Printf("Error reading socket tables: %v", err)
+		return
+	}
+	owners := socketOwners()
+
+	now := time.Now()
+	byteCounters := true
+	sampled, err := sampleSocketBytes()
+	if err != nil {
+		byteCounters = false
+		if !pc.warned {
+			log.Printf("Per-socket byte counters unavailable, showing connections only: %v", err)
+			pc.warned = true
+		}
+	}
+	seconds := now.Sub(pc.prevTime).Seconds()
+
+	processes := make(map[int]*storage.ProcessStats)
+	for _, sock := range sockets {
+		owner, ok := owners[sock.Inode]
+		if !ok || sock.Inode == 0 {
+			continue
+		}
+		proc, exists := processes[owner.PID]
+		if !exists {
+			proc = &storage.ProcessStats{
+				PID:         owner.PID,
+				Name:        owner.Name,
+				Cmdline:     owner.Cmdline,
+				Connections: []storage.ProcessConnection{},
+			}
+			processes[owner.PID] = proc
+		}
+
+		conn := storage.ProcessConnection{
+			Proto:  sock.Proto,
+			Local:  sock.localAddr(),
+			Remote: sock.remoteAddr(),
+			State:  sock.State,
+		}
+		if counts, ok := sampled[sock.Inode]; ok {
+			conn.BytesSent = counts.Sent
+			conn.BytesReceived = counts.Received
+			// Only rate sockets seen last time; a new socket's bytes
+			// may have been sent long before this interval
+			if prev, seen := pc.prevBytes[sock.Inode]; seen && seconds > 0 {
+				if counts.Sent >= prev.Sent {
+					conn.SendRate = float64(counts.Sent-prev.Sent) / seconds
+				}
+				if counts.Received >= prev.Received {
+					conn.RecvRate = float64(counts.Received-prev.Received) / seconds
+				}
+			}
+		}
+
+		proc.BytesSent += conn.BytesSent
+		proc.BytesReceived += conn.BytesReceived
+		proc.SendRate += conn.SendRate
+		proc.RecvRate += conn.RecvRate
+		proc.Connections = append(proc.Connections, conn)
+	}
+
+	pc.prevBytes = sampled
+	pc.prevTime = now
+
+	snapshot := storage.ProcessSnapshot{
+		Timestamp:    now,
+		ByteCounters: byteCounters,
+		Processes:    make([]*storage.ProcessStats, 0, len(processes)),
+	}
+	for _, proc := range processes {
+		snapshot.Processes = append(snapshot.Processes, proc)
+	}
+	pc.store.SetProcesses(snapshot)
+}
+
+// processInfo identifies the process owning a socket
+type processInfo struct {
+	PID     int
+	Name    string
+	Cmdline string
+}
+
+// socketOwners maps socket inodes to their owning process by scanning the
+// file descriptors in /proc/*/fd. Processes of other users are only
+// visible when running as root.
+func socketOwners() map[uint64]processInfo {
+	owners := make(map[uint64]processInfo)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return owners
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+		if err != nil {
+			continue
+		}
+
+		var info *processInfo
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(dir, "fd", fd.Name()))
+			if err != nil {
+				continue
+			}
+			inodeStr, ok := strings.CutPrefix(link, "socket:[")
+			if !ok {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(inodeStr, "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+
+			if info == nil {
+				info = &processInfo{PID: pid, Name: readSysfs(dir, "comm"), Cmdline: readCmdline(dir)}
+			}
+			// A socket shared by a parent and its children goes to the
+			// lowest PID, usually the parent
+			if existing, ok := owners[inode]; !ok || pid < existing.PID {
+				owners[inode] = *info
+			}
+		}
+	}
+	return owners
+}
+
+func readCmdline(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return ""
+	}
+	cmdline := strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))
+	if len(cmdline) > maxCmdline {
+		cmdline = cmdline[:maxCmdline] + "..."
+	}
+	return cmdline
+}
@@ -0,0 +1,217 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"network-monitor/internal/storage"
+)
+
+const (
+	rtfUp      = 0x0001
+	rtfGateway = 0x0002
+	rtfReject  = 0x0200
+)
+
+// readRoutes returns the kernel routing table. On Linux it is read from
+// /proc/net/route and /proc/net/ipv6_route; elsewhere only the default
+// routes reported by netstat are available.
+func readRoutes() ([]storage.Route, error) {
+	if runtime.GOOS != "linux" {
+		return netstatDefaultRoutes()
+	}
+
+	routes, err := readIPv4Routes("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+
+	// IPv6 may be disabled; that is not an error
+	if v6, err := readIPv6Routes("/proc/net/ipv6_route"); err == nil {
+		routes = append(routes, v6...)
+	}
+	return routes, nil
+}
+
+// defaultRoutes returns the default routes ordered by metric, IPv4 first
+func defaultRoutes() ([]storage.Route, error) {
+	routes, err := readRoutes()
+	if err != nil {
+		return nil, err
+	}
+
+	var defaults []storage.Route
+	for _, route := range routes {
+		if route.IsDefault() && route.Gateway != "" {
+			defaults = append(defaults, route)
+		}
+	}
+	sort.SliceStable(defaults, func(i, j int) bool {
+		if defaults[i].Family != defaults[j].Family {
+			return defaults[i].Family == "ipv4"
+		}
+		return defaults[i].Metric < defaults[j].Metric
+	})
+	return defaults, nil
+}
+
+// readIPv4Routes parses /proc/net/route, where addresses are little endian
+// hex words
+func readIPv4Routes(path string) ([]storage.Route, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read routes: %w", err)
+	}
+	defer file.Close()
+
+	var routes []storage.Route
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+
+		flags, _ := strconv.ParseUint(fields[3], 16, 32)
+		if flags&rtfUp == 0 {
+			continue
+		}
+		dst, err1 := hexIPv4(fields[1])
+		gateway, err2 := hexIPv4(fields[2])
+		mask, err3 := hexIPv4(fields[7])
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+		ones, _ := net.IPMask(mask.To4()).Size()
+
+		route := storage.Route{
+			Family:      "ipv4",
+			Destination: fmt.Sprintf("%s/%d", dst, ones),
+			Interface:   fields[0],
+			Metric:      metric,
+		}
+		if flags&rtfGateway != 0 {
+			route.Gateway = gateway.String()
+		}
+		routes = append(routes, route)
+	}
+	return routes, scanner.Err()
+}
+
+// readIPv6Routes parses /proc/net/ipv6_route
+func readIPv6Routes(path string) ([]storage.Route, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read IPv6 routes: %w", err)
+	}
+	defer file.Close()
+
+	var routes []storage.Route
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// dest plen src plen next_hop metric refcnt use flags iface
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		flags, _ := strconv.ParseUint(fields[8], 16, 32)
+		if flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+		dst, err1 := hexIPv6(fields[0])
+		nextHop, err2 := hexIPv6(fields[4])
+		plen, err3 := strconv.ParseUint(fields[1], 16, 8)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		metric, _ := strconv.ParseUint(fields[5], 16, 32)
+
+		route := storage.Route{
+			Family:      "ipv6",
+			Destination: fmt.Sprintf("%s/%d", dst, plen),
+			Interface:   fields[9],
+			Metric:      int(metric),
+		}
+		if !nextHop.IsUnspecified() {
+			route.Gateway = nextHop.String()
+		}
+		routes = append(routes, route)
+	}
+	return routes, scanner.Err()
+}
+
+func hexIPv4(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4 {
+		return nil, fmt.Errorf("bad IPv4 word %q", s)
+	}
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+	return ip, nil
+}
+
+func hexIPv6(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 16 {
+		return nil, fmt.Errorf("bad IPv6 address %q", s)
+	}
+	return net.IP(b), nil
+}
+
+// netstatDefaultRoutes reads the default routes from "netstat -rn" on
+// systems without /proc (macOS, BSD and Windows)
+func netstatDefaultRoutes() ([]storage.Route, error) {
+	cmd := exec.Command("netstat", "-rn")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("netstat -rn: %w", err)
+	}
+
+	var routes []storage.Route
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) >= 4 && fields[0] == "default":
+			// macOS/BSD: Destination Gateway Flags Netif ...
+			gateway := net.ParseIP(strings.SplitN(fields[1], "%", 2)[0])
+			if gateway == nil {
+				continue
+			}
+			route := storage.Route{Family: "ipv4", Destination: "0.0.0.0/0", Gateway: gateway.String(), Interface: fields[3]}
+			if gateway.To4() == nil {
+				route.Family = "ipv6"
+				route.Destination = "::/0"
+			}
+			routes = append(routes, route)
+		case len(fields) >= 5 && fields[0] == "0.0.0.0" && fields[1] == "0.0.0.0":
+			// Windows: Network Destination Netmask Gateway Interface Metric
+			if net.ParseIP(fields[2]) == nil {
+				continue
+			}
+			metric, _ := strconv.Atoi(fields[4])
+			routes = append(routes, storage.Route{
+				Family:      "ipv4",
+				Destination: "0.0.0.0/0",
+				Gateway:     fields[2],
+				Interface:   fields[3],
+				Metric:      metric,
+			})
+		}
+	}
+	return routes, nil
+}
@@ -0,0 +1,241 @@
+package collector
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"network-monitor/internal/pcap"
+	"network-monitor/internal/sflow"
+	"network-monitor/internal/storage"
+)
+
+const (
+	// maxSFlowAgents caps the agents accepted when no allowed agents are
+	// configured; agents are taken from the datagrams, which can be spoofed
+	maxSFlowAgents = 256
+	// maxSFlowPorts caps the remote interfaces kept per agent
+	maxSFlowPorts = 4096
+)
+
+// SFlowCollector receives sFlow v5 datagrams from switches. Counter samples
+// become remote interfaces named "<agent>:<ifIndex>"; flow samples are
+// scaled by their sampling rate and stored as flows under "sflow:<agent>".
+type SFlowCollector struct {
+	store    *storage.Store
+	addr     string
+	allowed  map[string]bool            // agent addresses accepted; empty accepts any
+	agents   map[string]map[uint32]bool // interface indexes seen per agent
+	mu       sync.Mutex
+	tables   map[string]*flowTable
+	warnings senderWarnings
+}
+
+// NewSFlowCollector accepts datagrams from the given agent addresses, or
+// from the first maxSFlowAgents agents heard when none are given
+func NewSFlowCollector(store *storage.Store, addr string, agents []string) *SFlowCollector {
+	sc := &SFlowCollector{
+		store:   store,
+		addr:    addr,
+		allowed: make(map[string]bool),
+		agents:  make(map[string]map[uint32]bool),
+		tables:  make(map[string]*flowTable),
+	}
+	for _, agent := range agents {
+		if ip := net.ParseIP(agent); ip != nil {
+			agent = ip.String()
+		}
+		sc.allowed[agent] = true
+	}
+	return sc
+}
+
+// Start listens on the configured UDP address until the socket fails,
+// flushing sampled flows every interval
+func (sc *SFlowCollector) Start(interval time.Duration) {
+	conn, err := net.ListenPacket("udp", sc.addr)
+	if err != nil {
+		log.Printf("sFlow listener on %s unavailable: %v", sc.addr, err)
+		return
+	}
+	defer conn.Close()
+
+	log.Printf("sFlow collector started on %s", sc.addr)
+
+	// Flushing stops with the listener
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sc.flush()
+			}
+		}
+	}()
+
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Printf("sFlow listener on %s stopped: %v", sc.addr, err)
+			sc.flush()
+			return
+		}
+		source := addr.String()
+		if udp, ok := addr.(*net.UDPAddr); ok {
+			source = udp.IP.String()
+		}
+		sc.ingest(buf[:n], source)
+	}
+}
+
+// ingest decodes one datagram received from the source address. Samples
+// are attributed to the agent address inside the datagram.
+func (sc *SFlowCollector) ingest(data []byte, source string) {
+	datagram, err := sflow.Decode(data)
+	if err != nil {
+		sc.warnings.warnOnce(source, "Malformed sFlow datagram from %s: %v", source, err)
+	}
+	if datagram == nil || datagram.Agent == nil {
+		return
+	}
+	agent := datagram.Agent.String()
+	ports, ok := sc.accept(agent, source)
+	if !ok {
+		return
+	}
+
+	for _, sample := range datagram.CounterSamples {
+		if c := sample.Interface; c != nil {
+			if !ports[c.IfIndex] {
+				if len(ports) >= maxSFlowPorts {
+					sc.warnings.warnOnce(agent, "sFlow agent %s has more than %d ports; ignoring the rest", agent, maxSFlowPorts)
+					continue
+				}
+				ports[c.IfIndex] = true
+			}
+			sc.store.UpdateRemoteInterface(storage.RemoteInterface{
+				Agent:   agent,
+				IfIndex: c.IfIndex,
+				Speed:   c.IfSpeed,
+				OperUp:  c.OperUp(),
+			}, storage.InterfaceCounters{
+				BytesRx:   c.InOctets,
+				BytesTx:   c.OutOctets,
+				PacketsRx: uint64(c.InUcastPkts) + uint64(c.InMulticastPkts) + uint64(c.InBroadcastPkts),
+				PacketsTx: uint64(c.OutUcastPkts) + uint64(c.OutMulticastPkts) + uint64(c.OutBroadcastPkts),
+				ErrorsRx:  uint64(c.InErrors),
+				ErrorsTx:  uint64(c.OutErrors),
+				DropsRx:   uint64(c.InDiscards),
+				DropsTx:   uint64(c.OutDiscards),
+				// sFlow carries 64-bit octet counters but 32-bit packet,
+				// error and discard counters
+				Packets32: true,
+				Errors32:  true,
+			})
+		}
+	}
+
+	if len(datagram.FlowSamples) == 0 {
+		return
+	}
+	now := time.Now()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	table, exists := sc.tables[agent]
+	if !exists {
+		table = newFlowTable("sflow:" + agent)
+		sc.tables[agent] = table
+	}
+	for _, sample := range datagram.FlowSamples {
+		decoded, length, ok := sampledPacket(sample)
+		if !ok {
+			continue
+		}
+		rate := uint64(sample.SamplingRate)
+		if rate == 0 {
+			rate = 1
+		}
+		table.addSampled(decoded, now, length, rate)
+	}
+}
+
+// accept returns the interface indexes seen from an agent, if its samples
+// are to be stored. Any sender can write any agent address into a
+// datagram, so the sender must be allowed as well or, when no agents are
+// configured, be the agent itself.
+func (sc *SFlowCollector) accept(agent, source string) (map[uint32]bool, bool) {
+	if len(sc.allowed) > 0 && !sc.allowed[agent] {
+		sc.warnings.warnOnce(agent, "Ignoring sFlow from agent %s, which is not in the allowed agents", agent)
+		return nil, false
+	}
+	if source != agent && (len(sc.allowed) == 0 || !sc.allowed[source]) {
+		sc.warnings.warnOnce(source, "Ignoring sFlow for agent %s sent from %s", agent, source)
+		return nil, false
+	}
+	ports, exists := sc.agents[agent]
+	if !exists {
+		if len(sc.agents) >= maxSFlowAgents {
+			sc.warnings.warnOnce(agent, "Ignoring sFlow from agent %s: already receiving from %d agents", agent, maxSFlowAgents)
+			return nil, false
+		}
+		ports = make(map[uint32]bool)
+		sc.agents[agent] = ports
+	}
+	return ports, true
+}
+
+// sampledPacket extracts the addresses and length of a flow sample
+func sampledPacket(sample sflow.FlowSample) (pcap.Decoded, int, bool) {
+	if sample.Header != nil {
+		linkType := -1
+		switch sample.HeaderProtocol {
+		case sflow.HeaderEthernet:
+			linkType = pcap.LinkTypeEthernet
+		case sflow.HeaderIPv4:
+			linkType = pcap.LinkTypeIPv4
+		case sflow.HeaderIPv6:
+			linkType = pcap.LinkTypeIPv6
+		}
+		if decoded, err := pcap.Decode(linkType, sample.Header); err == nil {
+			// The header is truncated; the IP length field, or else the
+			// frame length, gives the size of the sampled packet
+			length := decoded.Length
+			if length == 0 {
+				length = int(sample.FrameLength)
+			}
+			return decoded, length, true
+		}
+	}
+	if ip := sample.IP; ip != nil {
+		return pcap.Decoded{
+			SrcIP:    ip.SrcIP,
+			DstIP:    ip.DstIP,
+			Proto:    ip.Proto,
+			SrcPort:  ip.SrcPort,
+			DstPort:  ip.DstPort,
+			TCPFlags: ip.TCPFlags,
+			Length:   int(ip.Length),
+		}, int(ip.Length), true
+	}
+	return pcap.Decoded{}, 0, false
+}
+
+func (sc *SFlowCollector) flush() {
+	sc.mu.Lock()
+	var records []storage.FlowRecord
+	for _, table := range sc.tables {
+		records = append(records, table.flush()...)
+	}
+	sc.mu.Unlock()
+
+	if len(records) > 0 {
+		sc.store.AddFlows(records)
+	}
+}
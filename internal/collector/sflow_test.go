@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"testing"
+
+	"network-monitor/internal/storage"
+)
+
+func TestSFlowAcceptChecksSource(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowed       []string
+		agent, source string
+		want          bool
+	}{
+		{"agent sends itself", nil, "192.0.2.10", "192.0.2.10", true},
+		{"claims another agent", nil, "192.0.2.10", "198.51.100.7", false},
+		{"allowed agent", []string{"192.0.2.10"}, "192.0.2.10", "192.0.2.10", true},
+		{"spoofs an allowed agent", []string{"192.0.2.10"}, "192.0.2.10", "198.51.100.7", false},
+		{"allowed sender for an allowed agent", []string{"192.0.2.10", "192.0.2.11"}, "192.0.2.10", "192.0.2.11", true},
+		{"agent not allowed", []string{"192.0.2.10"}, "192.0.2.12", "192.0.2.12", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := NewSFlowCollector(storage.NewStore(), "", tt.allowed)
+			if _, ok := sc.accept(tt.agent, tt.source); ok != tt.want {
+				t.Errorf("accept(%s, %s) = %t, want %t", tt.agent, tt.source, ok, tt.want)
+			}
+		})
+	}
+}
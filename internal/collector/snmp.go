@@ -0,0 +1,242 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"network-monitor/internal/snmp"
+	"network-monitor/internal/storage"
+)
+
+// SNMPDevice is a router or switch whose IF-MIB is polled. Version is "2c"
+// (the default) or "3"; v3 takes its credentials from the embedded User.
+type SNMPDevice struct {
+	Name      string `json:"name,omitempty"` // defaults to the address
+	Address   string `json:"address"`        // host or host:port
+	Version   string `json:"version,omitempty"`
+	Community string `json:"community,omitempty"`
+	snmp.User
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+}
+
+// Key returns the name the device's interfaces are stored under
+func (d SNMPDevice) Key() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	if host, _, err := net.SplitHostPort(d.Address); err == nil {
+		return host
+	}
+	return d.Address
+}
+
+// IF-MIB columns. The 64-bit ifXTable counters are preferred; the ifTable
+// ones cover agents without them.
+var (
+	ifDescr       = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.2")
+	ifSpeed       = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.5")
+	ifOperStatus  = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.8")
+	ifInOctets    = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.10")
+	ifInUcastPkts = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.11")
+	ifInDiscards  = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.13")
+	ifInErrors    = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.14")
+	ifOutOctets   = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.16")
+	ifOutUcast    = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.17")
+	ifOutDiscards = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.19")
+	ifOutErrors   = snmp.MustParseOID("1.3.6.1.2.1.2.2.1.20")
+	ifName        = snmp.MustParseOID("1.3.6.1.2.1.31.1.1.1.1")
+	ifHCInOctets  = snmp.MustParseOID("1.3.6.1.2.1.31.1.1.1.6")
+	ifHCInUcast   = snmp.MustParseOID("1.3.6.1.2.1.31.1.1.1.7")
+	ifHCOutOctets = snmp.MustParseOID("1.3.6.1.2.1.31.1.1.1.10")
+	ifHCOutUcast  = snmp.MustParseOID("1.3.6.1.2.1.31.1.1.1.11")
+	ifHighSpeed   = snmp.MustParseOID("1.3.6.1.2.1.31.1.1.1.15")
+	ifAlias       = snmp.MustParseOID("1.3.6.1.2.1.31.1.1.1.18")
+)
+
+// ifRow collects the polled columns of one interface
+type ifRow struct {
+	port     storage.RemoteInterface
+	descr    string
+	counters storage.InterfaceCounters
+}
+
+// SNMPCollector polls the interface counters of routers and switches and
+// stores them as remote interfaces named "<device>:<ifIndex>"
+type SNMPCollector struct {
+	store   *storage.Store
+	devices []SNMPDevice
+	mu      sync.Mutex
+	clients map[string]*snmp.Client
+}
+
+func NewSNMPCollector(store *storage.Store, devices []SNMPDevice) *SNMPCollector {
+	return &SNMPCollector{
+		store:   store,
+		devices: devices,
+		clients: make(map[string]*snmp.Client),
+	}
+}
+
+func (sc *SNMPCollector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("SNMP collector started for %d devices", len(sc.devices))
+
+	for {
+		sc.collectSNMP()
+		<-ticker.C
+	}
+}
+
+// collectSNMP polls all devices in parallel so a dead device does not
+// delay the others
+func (sc *SNMPCollector) collectSNMP() {
+	var wg sync.WaitGroup
+	for _, device := range sc.devices {
+		wg.Add(1)
+		go func(device SNMPDevice) {
+			defer wg.Done()
+			sc.pollDevice(device)
+		}(device)
+	}
+	wg.Wait()
+}
+
+func (sc *SNMPCollector) pollDevice(device SNMPDevice) {
+	key := device.Key()
+	alertKey := "snmp:" + key
+
+	rows, err := sc.walkInterfaces(device)
+	if err != nil {
+		log.Printf("SNMP poll of %s failed: %v", key, err)
+		sc.store.RaiseAlert(alertKey, "snmp", key, storage.SeverityWarning,
+			fmt.Sprintf("SNMP poll of %s failed: %v", key, err))
+		// Reconnect next time, rediscovering the v3 engine
+		sc.mu.Lock()
+		if client, exists := sc.clients[key]; exists {
+			client.Close()
+			delete(sc.clients, key)
+		}
+		sc.mu.Unlock()
+		return
+	}
+	sc.store.ResolveAlert(alertKey)
+
+	for _, row := range rows {
+		if row.port.IfName == "" {
+			row.port.IfName = row.descr
+		}
+		sc.store.UpdateRemoteInterface(row.port, row.counters)
+	}
+}
+
+// client returns the connected client of a device, connecting on first use
+func (sc *SNMPCollector) client(device SNMPDevice) (*snmp.Client, error) {
+	key := device.Key()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if client, exists := sc.clients[key]; exists {
+		return client, nil
+	}
+
+	client := &snmp.Client{
+		Address:   device.Address,
+		Version:   snmp.Version2c,
+		Community: device.Community,
+		User:      device.User,
+		Retries:   1,
+	}
+	switch device.Version {
+	case "", "2c", "2":
+		if client.Community == "" {
+			client.Community = "public"
+		}
+	case "3":
+		client.Version = snmp.Version3
+	case "1":
+		client.Version = snmp.Version1
+	default:
+		return nil, fmt.Errorf("unsupported SNMP version %q", device.Version)
+	}
+	if device.TimeoutMs > 0 {
+		client.Timeout = time.Duration(device.TimeoutMs) * time.Millisecond
+	}
+
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	sc.clients[key] = client
+	return client, nil
+}
+
+// walkInterfaces walks the IF-MIB columns of a device into one row per
+// ifIndex
+func (sc *SNMPCollector) walkInterfaces(device SNMPDevice) (map[uint32]*ifRow, error) {
+	client, err := sc.client(device)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[uint32]*ifRow)
+	row := func(index uint32) *ifRow {
+		r, exists := rows[index]
+		if !exists {
+			r = &ifRow{port: storage.RemoteInterface{Agent: device.Key(), IfIndex: index}}
+			rows[index] = r
+		}
+		return r
+	}
+
+	columns := []struct {
+		oid snmp.OID
+		set func(r *ifRow, v snmp.Variable)
+	}{
+		{ifDescr, func(r *ifRow, v snmp.Variable) { r.descr = v.String() }},
+		{ifSpeed, func(r *ifRow, v snmp.Variable) { r.port.Speed = v.Uint64() }},
+		{ifOperStatus, func(r *ifRow, v snmp.Variable) { r.port.OperUp = v.Int64() == 1 }},
+		{ifInOctets, func(r *ifRow, v snmp.Variable) { r.counters.BytesRx, r.counters.Bytes32 = v.Uint64(), true }},
+		{ifOutOctets, func(r *ifRow, v snmp.Variable) { r.counters.BytesTx = v.Uint64() }},
+		{ifInUcastPkts, func(r *ifRow, v snmp.Variable) { r.counters.PacketsRx, r.counters.Packets32 = v.Uint64(), true }},
+		{ifOutUcast, func(r *ifRow, v snmp.Variable) { r.counters.PacketsTx = v.Uint64() }},
+		{ifInDiscards, func(r *ifRow, v snmp.Variable) { r.counters.DropsRx, r.counters.Errors32 = v.Uint64(), true }},
+		{ifOutDiscards, func(r *ifRow, v snmp.Variable) { r.counters.DropsTx = v.Uint64() }},
+		{ifInErrors, func(r *ifRow, v snmp.Variable) { r.counters.ErrorsRx, r.counters.Errors32 = v.Uint64(), true }},
+		{ifOutErrors, func(r *ifRow, v snmp.Variable) { r.counters.ErrorsTx = v.Uint64() }},
+		// ifXTable last, so its values replace the 32-bit ones and
+		// clear their flags
+		{ifName, func(r *ifRow, v snmp.Variable) { r.port.IfName = v.String() }},
+		{ifAlias, func(r *ifRow, v snmp.Variable) { r.port.Description = v.String() }},
+		{ifHighSpeed, func(r *ifRow, v snmp.Variable) {
+			if mbps := v.Uint64(); mbps > 0 {
+				r.port.Speed = mbps * 1000000
+			}
+		}},
+		{ifHCInOctets, func(r *ifRow, v snmp.Variable) { r.counters.BytesRx, r.counters.Bytes32 = v.Uint64(), false }},
+		{ifHCOutOctets, func(r *ifRow, v snmp.Variable) { r.counters.BytesTx = v.Uint64() }},
+		{ifHCInUcast, func(r *ifRow, v snmp.Variable) { r.counters.PacketsRx, r.counters.Packets32 = v.Uint64(), false }},
+		{ifHCOutUcast, func(r *ifRow, v snmp.Variable) { r.counters.PacketsTx = v.Uint64() }},
+	}
+
+	for _, column := range columns {
+		err := client.Walk(column.oid, func(v snmp.Variable) error {
+			// The ifIndex is the single sub-identifier after the column
+			if len(v.OID) != len(column.oid)+1 {
+				return nil
+			}
+			column.set(row(v.OID[len(column.oid)]), v)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walk %s: %w", column.oid, err)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("agent has no IF-MIB interfaces")
+	}
+	return rows, nil
+}
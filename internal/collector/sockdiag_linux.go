@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+)
+
+const (
+	sockDiagByFamily = 20 // SOCK_DIAG_BY_FAMILY
+	inetDiagInfo     = 2  // INET_DIAG_INFO attribute carrying struct tcp_info
+	inetDiagMsgLen   = 72 // sizeof(struct inet_diag_msg)
+
+	// Offsets of tcpi_bytes_acked and tcpi_bytes_received in struct tcp_info
+	tcpInfoBytesAcked    = 120
+	tcpInfoBytesReceived = 128
+)
+
+// socketBytes is the byte count of one TCP socket as reported by the kernel
+type socketBytes struct {
+	Sent     uint64 // bytes acknowledged by the peer
+	Received uint64
+}
+
+// sampleSocketBytes dumps all TCP sockets over netlink sock_diag and
+// returns their byte counters keyed by inode. Kernels older than 4.2 (or
+// 4.1 for the received count) don't report them and yield an empty map.
+func sampleSocketBytes() (map[uint64]socketBytes, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_INET_DIAG)
+	if err != nil {
+		return nil, fmt.Errorf("open sock_diag socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	result := make(map[uint64]socketBytes)
+	for seq, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		if err := dumpTCPSockets(fd, uint32(seq+1), family, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func dumpTCPSockets(fd int, seq uint32, family uint8, result map[uint64]socketBytes) error {
+	// struct nlmsghdr followed by struct inet_diag_req_v2
+	req := make([]byte, syscall.NLMSG_HDRLEN+56)
+	binary.NativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:6], sockDiagByFamily)
+	binary.NativeEndian.PutUint16(req[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(req[8:12], seq)
+	body := req[syscall.NLMSG_HDRLEN:]
+	body[0] = family
+	body[1] = syscall.IPPROTO_TCP
+	body[2] = 1 << (inetDiagInfo - 1)                    // ask for tcp_info
+	binary.NativeEndian.PutUint32(body[4:8], 0xffffffff) // every state
+
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return fmt.Errorf("sock_diag request: %w", err)
+	}
+
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return fmt.Errorf("sock_diag receive: %w", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return fmt.Errorf("sock_diag parse: %w", err)
+		}
+
+		for _, msg := range msgs {
+			if msg.Header.Seq != seq {
+				continue
+			}
+			switch msg.Header.Type {
+			case syscall.NLMSG_DONE:
+				return nil
+			case syscall.NLMSG_ERROR:
+				if len(msg.Data) >= 4 {
+					if errno := int32(binary.NativeEndian.Uint32(msg.Data[0:4])); errno != 0 {
+						return fmt.Errorf("sock_diag: %w", syscall.Errno(-errno))
+					}
+				}
+				return nil
+			case sockDiagByFamily:
+				parseInetDiagMsg(msg.Data, result)
+			}
+		}
+	}
+}
+
+// parseInetDiagMsg reads the inode and tcp_info byte counters from one
+// struct inet_diag_msg and its attributes
+func parseInetDiagMsg(data []byte, result map[uint64]socketBytes) {
+	if len(data) < inetDiagMsgLen {
+		return
+	}
+	inode := uint64(binary.NativeEndian.Uint32(data[68:72]))
+	if inode == 0 {
+		return // TIME_WAIT and similar sockets without an owner
+	}
+
+	attrs := data[inetDiagMsgLen:]
+	for len(attrs) >= syscall.SizeofRtAttr {
+		attrLen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		attrType := binary.NativeEndian.Uint16(attrs[2:4])
+		if attrLen < syscall.SizeofRtAttr || attrLen > len(attrs) {
+			return
+		}
+
+		if attrType == inetDiagInfo {
+			info := attrs[syscall.SizeofRtAttr:attrLen]
+			if len(info) >= tcpInfoBytesReceived+8 {
+				result[inode] = socketBytes{
+					Sent:     binary.NativeEndian.Uint64(info[tcpInfoBytesAcked:]),
+					Received: binary.NativeEndian.Uint64(info[tcpInfoBytesReceived:]),
+				}
+			}
+		}
+
+		aligned := (attrLen + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+		if aligned > len(attrs) {
+			return
+		}
+		attrs = attrs[aligned:]
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package collector
+
+import "errors"
+
+// socketBytes is the byte count of one TCP socket as reported by the kernel
+type socketBytes struct {
+	Sent     uint64
+	Received uint64
+}
+
+// sampleSocketBytes is only implemented on Linux
+func sampleSocketBytes() (map[uint64]socketBytes, error) {
+	return nil, errors.New("per-socket byte counters are only supported on Linux")
+}
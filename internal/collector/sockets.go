@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// tcpStates names the kernel TCP states as numbered in /proc/net/tcp
+var tcpStates = map[uint64]string{
+	0x01: "ESTABLISHED",
+	0x02: "SYN_SENT",
+	0x03: "SYN_RECV",
+	0x04: "FIN_WAIT1",
+	0x05: "FIN_WAIT2",
+	0x06: "TIME_WAIT",
+	0x07: "CLOSE",
+	0x08: "CLOSE_WAIT",
+	0x09: "LAST_ACK",
+	0x0A: "LISTEN",
+	0x0B: "CLOSING",
+	0x0C: "NEW_SYN_RECV",
+}
+
+// socketEntry is one row of a /proc/net socket table
+type socketEntry struct {
+	Proto      string // tcp, udp, tcp6 or udp6
+	LocalIP    net.IP
+	LocalPort  int
+	RemoteIP   net.IP
+	RemotePort int
+	State      string
+	TxQueue    uint64
+	RxQueue    uint64
+	UID        int
+	Inode      uint64
+}
+
+func (e socketEntry) localAddr() string {
+	return net.JoinHostPort(e.LocalIP.String(), strconv.Itoa(e.LocalPort))
+}
+
+func (e socketEntry) remoteAddr() string {
+	return net.JoinHostPort(e.RemoteIP.String(), strconv.Itoa(e.RemotePort))
+}
+
+// readSockets lists the TCP and UDP sockets of all address families. IPv6
+// tables are skipped when IPv6 is disabled.
+func readSockets() ([]socketEntry, error) {
+	var sockets []socketEntry
+	for _, proto := range []string{"tcp", "udp", "tcp6", "udp6"} {
+		entries, err := readSocketTable("/proc/net/"+proto, proto)
+		if err != nil {
+			if strings.HasSuffix(proto, "6") && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		sockets = append(sockets, entries...)
+	}
+	return sockets, nil
+}
+
+// readSocketTable parses a /proc/net/{tcp,udp,tcp6,udp6} table
+func readSocketTable(path, proto string) ([]socketEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []socketEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		localIP, localPort, err1 := procSocketAddr(fields[1])
+		remoteIP, remotePort, err2 := procSocketAddr(fields[2])
+		state, err3 := strconv.ParseUint(fields[3], 16, 8)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+
+		entry := socketEntry{
+			Proto:      proto,
+			LocalIP:    localIP,
+			LocalPort:  localPort,
+			RemoteIP:   remoteIP,
+			RemotePort: remotePort,
+		}
+		if strings.HasPrefix(proto, "tcp") {
+			entry.State = tcpStates[state]
+		} else if state == 0x01 {
+			// UDP sockets only distinguish connected from unconnected
+			entry.State = "ESTABLISHED"
+		} else {
+			entry.State = "UNCONN"
+		}
+		if queues := strings.SplitN(fields[4], ":", 2); len(queues) == 2 {
+			entry.TxQueue, _ = strconv.ParseUint(queues[0], 16, 64)
+			entry.RxQueue, _ = strconv.ParseUint(queues[1], 16, 64)
+		}
+		entry.UID, _ = strconv.Atoi(fields[7])
+		entry.Inode, _ = strconv.ParseUint(fields[9], 10, 64)
+
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// procSocketAddr parses an "ADDR:PORT" pair. The address is stored as
+// 32-bit words in host (little endian) order, the port as big endian hex.
+func procSocketAddr(s string) (net.IP, int, error) {
+	addr, port, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, fmt.Errorf("bad socket address %q", s)
+	}
+	p, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("bad socket port %q", s)
+	}
+
+	if len(addr) == 8 {
+		ip, err := hexIPv4(addr)
+		return ip, int(p), err
+	}
+
+	b, err := hex.DecodeString(addr)
+	if err != nil || len(b) != 16 {
+		return nil, 0, fmt.Errorf("bad IPv6 socket address %q", s)
+	}
+	ip := make(net.IP, 16)
+	for i := 0; i < 16; i += 4 {
+		binary.BigEndian.PutUint32(ip[i:], binary.LittleEndian.Uint32(b[i:]))
+	}
+	return ip, int(p), nil
+}
@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"network-monitor/internal/storage"
+	"network-monitor/internal/syslog"
+)
+
+const (
+	// maxSyslogConns bounds the TCP and TLS senders connected at once
+	maxSyslogConns = 256
+	// syslogIdleTimeout closes connections that send nothing, so idle
+	// senders cannot hold every slot; senders reconnect when they have
+	// something to log
+	syslogIdleTimeout = 5 * time.Minute
+)
+
+// SyslogOptions enables the syslog listeners; each address is optional,
+// e.g. UDP ":514", TCP ":514" and TLS ":6514" (RFC 5425)
+type SyslogOptions struct {
+	UDP      string `json:"udp"`
+	TCP      string `json:"tcp"`
+	TLS      string `json:"tls"`
+	CertFile string `json:"cert_file"` // PEM certificate and key for TLS
+	KeyFile  string `json:"key_file"`
+}
+
+// Enabled reports whether any listener is configured
+func (o SyslogOptions) Enabled() bool {
+	return o.UDP != "" || o.TCP != "" || o.TLS != ""
+}
+
+// SyslogReceiver stores the syslog messages of routers, switches and
+// access points. BSD timestamps are read in the local time zone.
+type SyslogReceiver struct {
+	store     *storage.Store
+	opts      SyslogOptions
+	tlsConfig *tls.Config
+	conns     chan struct{}
+	warnings  senderWarnings
+}
+
+// NewSyslogReceiver loads the TLS certificate, if a TLS listener is
+// configured
+func NewSyslogReceiver(store *storage.Store, opts SyslogOptions) (*SyslogReceiver, error) {
+	sr := &SyslogReceiver{
+		store:    store,
+		opts:     opts,
+		conns:    make(chan struct{}, maxSyslogConns),
+		warnings: senderWarnings{prefix: "Syslog from %s: "},
+	}
+	if opts.TLS != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, fmt.Errorf("syslog over TLS needs cert_file and key_file")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load syslog TLS certificate: %w", err)
+		}
+		sr.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	return sr, nil
+}
+
+// Start runs the configured listeners until they all fail
+func (sr *SyslogReceiver) Start() {
+	var wg sync.WaitGroup
+	run := func(listen func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listen()
+		}()
+	}
+	if sr.opts.UDP != "" {
+		run(sr.listenUDP)
+	}
+	if sr.opts.TCP != "" {
+		run(func() { sr.listenStream("TCP", sr.opts.TCP, nil) })
+	}
+	if sr.opts.TLS != "" {
+		run(func() { sr.listenStream("TLS", sr.opts.TLS, sr.tlsConfig) })
+	}
+	wg.Wait()
+}
+
+func (sr *SyslogReceiver) listenUDP() {
+	conn, err := net.ListenPacket("udp", sr.opts.UDP)
+	if err != nil {
+		log.Printf("Syslog UDP listener on %s unavailable: %v", sr.opts.UDP, err)
+		return
+	}
+	defer conn.Close()
+
+	log.Printf("Syslog receiver started on %s (UDP)", sr.opts.UDP)
+
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Printf("Syslog UDP listener on %s stopped: %v", sr.opts.UDP, err)
+			return
+		}
+		sr.ingest(buf[:n], hostOf(addr))
+	}
+}
+
+// listenStream accepts TCP senders, over TLS when config is set
+func (sr *SyslogReceiver) listenStream(kind, addr string, config *tls.Config) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("Syslog %s listener on %s unavailable: %v", kind, addr, err)
+		return
+	}
+	if config != nil {
+		listener = tls.NewListener(listener, config)
+	}
+	defer listener.Close()
+
+	log.Printf("Syslog receiver started on %s (%s)", addr, kind)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			log.Printf("Syslog %s listener on %s stopped: %v", kind, addr, err)
+			return
+		}
+		select {
+		case sr.conns <- struct{}{}:
+			go func() {
+				defer func() { <-sr.conns }()
+				sr.serve(conn)
+			}()
+		default:
+			sr.warnings.warnOnce(hostOf(conn.RemoteAddr()), "too many connections; refused")
+			conn.Close()
+		}
+	}
+}
+
+// serve reads messages from one sender until it disconnects
+func (sr *SyslogReceiver) serve(conn net.Conn) {
+	defer conn.Close()
+	host := hostOf(conn.RemoteAddr())
+
+	// Senders stalling the handshake would hold a connection slot
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		tlsConn.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := tlsConn.Handshake(); err != nil {
+			sr.warnings.warnOnce(host, "TLS handshake failed: %v", err)
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+	}
+
+	reader := syslog.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(syslogIdleTimeout))
+		msg, err := reader.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !errors.Is(err, os.ErrDeadlineExceeded) {
+				sr.warnings.warnOnce(host, "connection closed: %v", err)
+			}
+			return
+		}
+		sr.ingest(msg, host)
+	}
+}
+
+// ingest parses one message and stores it
+func (sr *SyslogReceiver) ingest(data []byte, host string) {
+	now := time.Now()
+	msg, err := syslog.Parse(data, now, time.Local)
+	if err != nil {
+		sr.warnings.warnOnce(host, "malformed message: %v", err)
+		return
+	}
+	sr.store.AddLogEntry(storage.LogEntry{
+		Received:       now,
+		Timestamp:      msg.Timestamp,
+		Host:           host,
+		Hostname:       msg.Hostname,
+		Facility:       syslog.FacilityName(msg.Facility),
+		Severity:       msg.Severity,
+		Level:          syslog.SeverityName(msg.Severity),
+		App:            msg.AppName,
+		ProcID:         msg.ProcID,
+		MsgID:          msg.MsgID,
+		StructuredData: msg.StructuredData,
+		Message:        msg.Text,
+	})
+}
+
+// hostOf returns the IP address of a sender
+func hostOf(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.TCPAddr:
+		return a.IP.String()
+	}
+	return addr.String()
+}
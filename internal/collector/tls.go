@@ -0,0 +1,178 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	"network-monitor/internal/storage"
+)
+
+// defaultTLSWarnDays is used when a TLS check does not set WarnDays
+const defaultTLSWarnDays = 14
+
+// TLSCheck describes a TLS endpoint whose certificate is monitored
+type TLSCheck struct {
+	Name       string `json:"name"`
+	Host       string `json:"host"`
+	Port       string `json:"port,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+	WarnDays   int    `json:"warn_days,omitempty"`
+	TimeoutMs  int    `json:"timeout_ms,omitempty"`
+}
+
+// key returns the name the check's results are stored under
+func (c TLSCheck) key() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return net.JoinHostPort(c.Host, c.port())
+}
+
+func (c TLSCheck) port() string {
+	if c.Port == "" {
+		return "443"
+	}
+	return c.Port
+}
+
+func (c TLSCheck) serverName() string {
+	if c.ServerName != "" {
+		return c.ServerName
+	}
+	return c.Host
+}
+
+func (c TLSCheck) warnDays() int {
+	if c.WarnDays > 0 {
+		return c.WarnDays
+	}
+	return defaultTLSWarnDays
+}
+
+// tlsProbe performs a TLS handshake with the check's endpoint and records
+// the presented certificate chain. The handshake itself skips verification
+// so that an invalid chain is still reported instead of just failing.
+func tlsProbe(check TLSCheck) PingResult {
+	result := PingResult{Host: check.key(), Method: "TLS"}
+
+	timeout := 10 * time.Second
+	if check.TimeoutMs > 0 {
+		timeout = time.Duration(check.TimeoutMs) * time.Millisecond
+	}
+
+	start := time.Now()
+	rawConn, err := net.DialTimeout("tcp", net.JoinHostPort(check.Host, check.port()), timeout)
+	if err != nil {
+		result.Error = fmt.Errorf("TCP connect to %s:%s: %w", check.Host, check.port(), err)
+		return result
+	}
+	defer rawConn.Close()
+	connected := time.Now()
+
+	conn := tls.Client(rawConn, &tls.Config{
+		ServerName:         check.serverName(),
+		InsecureSkipVerify: true,
+	})
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		result.Error = fmt.Errorf("set deadline: %w", err)
+		return result
+	}
+	if err := conn.Handshake(); err != nil {
+		result.Error = fmt.Errorf("TLS handshake with %s: %w", check.key(), err)
+		return result
+	}
+	done := time.Now()
+
+	result.RTT = done.Sub(start)
+	result.Timings = &storage.ProbeTimings{
+		Connect: connected.Sub(start),
+		TLS:     done.Sub(connected),
+		Total:   done.Sub(start),
+	}
+	result.TLS = describeTLS(conn.ConnectionState(), check.serverName())
+	result.Success = true
+	return result
+}
+
+// describeTLS summarises the negotiated connection and verifies the peer
+// chain against the system roots for serverName
+func describeTLS(state tls.ConnectionState, serverName string) *storage.TLSDetail {
+	detail := &storage.TLSDetail{
+		ServerName: serverName,
+		Version:    tls.VersionName(state.Version),
+		Cipher:     tls.CipherSuiteName(state.CipherSuite),
+	}
+
+	certs := state.PeerCertificates
+	if len(certs) == 0 {
+		detail.ChainError = "no peer certificates presented"
+		return detail
+	}
+
+	leaf := certs[0]
+	detail.Subject = leaf.Subject.String()
+	detail.Issuer = leaf.Issuer.String()
+	detail.SANs = append(detail.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		detail.SANs = append(detail.SANs, ip.String())
+	}
+	detail.NotBefore = leaf.NotBefore
+	detail.NotAfter = leaf.NotAfter
+	detail.DaysToExpiry = int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24))
+
+	for _, cert := range certs {
+		detail.Chain = append(detail.Chain, cert.Subject.String())
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		detail.ChainError = err.Error()
+	} else {
+		detail.ChainValid = true
+	}
+
+	return detail
+}
+
+// checkTLSAlerts raises or resolves the expiry and chain alerts for a
+// completed TLS probe stored under key
+func (pc *PingCollector) checkTLSAlerts(key string, check TLSCheck, result PingResult) {
+	expiryKey := "tls-expiry:" + key
+	chainKey := "tls-chain:" + key
+
+	detail := result.TLS
+	if detail == nil {
+		// Handshake failed; the reachability alert covers it
+		return
+	}
+
+	if detail.DaysToExpiry <= check.warnDays() {
+		severity := storage.SeverityWarning
+		message := fmt.Sprintf("Certificate for %s expires in %d days (%s)", key, detail.DaysToExpiry, detail.NotAfter.Format("2006-01-02"))
+		if detail.DaysToExpiry < 0 {
+			severity = storage.SeverityCritical
+			message = fmt.Sprintf("Certificate for %s expired on %s", key, detail.NotAfter.Format("2006-01-02"))
+		}
+		pc.store.RaiseAlert(expiryKey, "tls", key, severity, message)
+	} else {
+		pc.store.ResolveAlert(expiryKey)
+	}
+
+	if !detail.ChainValid {
+		pc.store.RaiseAlert(chainKey, "tls", key, storage.SeverityCritical,
+			fmt.Sprintf("Certificate chain for %s is invalid: %s", key, detail.ChainError))
+	} else {
+		pc.store.ResolveAlert(chainKey)
+	}
+}
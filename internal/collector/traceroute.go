@@ -0,0 +1,351 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"network-monitor/internal/storage"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// Traceroute modes
+const (
+	TraceModeUDP  = "udp"
+	TraceModeICMP = "icmp"
+)
+
+// traceBasePort is the first destination port used by UDP probes, as in
+// the classic traceroute implementation
+const traceBasePort = 33434
+
+// TraceOptions controls how a traceroute is run. Zero values pick the
+// defaults: UDP mode, 30 hops, 3 probes per hop and a 1s probe timeout.
+type TraceOptions struct {
+	Mode         string `json:"mode,omitempty"`
+	MaxHops      int    `json:"max_hops,omitempty"`
+	ProbesPerHop int    `json:"probes_per_hop,omitempty"`
+	TimeoutMs    int    `json:"timeout_ms,omitempty"`
+}
+
+func (o TraceOptions) withDefaults() TraceOptions {
+	if o.Mode == "" {
+		o.Mode = TraceModeUDP
+	}
+	if o.MaxHops <= 0 {
+		o.MaxHops = 30
+	}
+	if o.ProbesPerHop <= 0 {
+		o.ProbesPerHop = 3
+	}
+	if o.TimeoutMs <= 0 {
+		o.TimeoutMs = 1000
+	}
+	return o
+}
+
+// tracer holds the sockets and identifiers of a single traceroute run
+type tracer struct {
+	icmp    *icmp.PacketConn
+	udp     *ipv4.PacketConn
+	dst     net.IP
+	mode    string
+	id      int
+	srcPort int
+	timeout time.Duration
+}
+
+// probeReply is the answer to a single TTL-limited probe
+type probeReply struct {
+	addr    string
+	rtt     time.Duration
+	reached bool // the destination itself answered
+	stop    bool // a router reported the destination unreachable
+}
+
+// Traceroute discovers the path to host by sending probes with increasing
+// TTL and collecting the ICMP time exceeded replies from each router. It
+// needs a raw ICMP socket, so it only works with root or CAP_NET_RAW. The
+// trace is abandoned when ctx is done.
+func Traceroute(ctx context.Context, host string, opts TraceOptions) (*storage.TraceRoute, error) {
+	opts = opts.withDefaults()
+	if opts.Mode != TraceModeUDP && opts.Mode != TraceModeICMP {
+		return nil, fmt.Errorf("unknown traceroute mode %q", opts.Mode)
+	}
+
+	dst, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve IP: %w", err)
+	}
+
+	t, err := newTracer(dst.IP, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer t.close()
+
+	trace := &storage.TraceRoute{
+		Target:    host,
+		Address:   dst.IP.String(),
+		Mode:      opts.Mode,
+		Hops:      []storage.TraceHop{},
+		StartedAt: time.Now(),
+	}
+
+	seq := 0
+	for ttl := 1; ttl <= opts.MaxHops; ttl++ {
+		hop := storage.TraceHop{TTL: ttl, RTTs: []time.Duration{}}
+		done := false
+
+		for i := 0; i < opts.ProbesPerHop; i++ {
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("traceroute to %s: %w", host, err)
+			}
+			seq++
+			hop.Sent++
+			reply, err := t.probe(ctx, ttl, seq)
+			if err != nil {
+				continue
+			}
+
+			hop.Received++
+			hop.RTTs = append(hop.RTTs, reply.rtt)
+			if hop.Address == "" {
+				hop.Address = reply.addr
+			}
+			if reply.reached {
+				trace.Reached = true
+				done = true
+			}
+			if reply.stop {
+				done = true
+			}
+		}
+
+		summarizeHop(&hop)
+		trace.Hops = append(trace.Hops, hop)
+		if done {
+			break
+		}
+	}
+
+	trace.Duration = time.Since(trace.StartedAt)
+	return trace, nil
+}
+
+func newTracer(dst net.IP, opts TraceOptions) (*tracer, error) {
+	conn, privileged, err := listenICMP()
+	if err != nil {
+		return nil, err
+	}
+	if !privileged {
+		conn.Close()
+		return nil, fmt.Errorf("traceroute needs a raw ICMP socket (run as root or grant CAP_NET_RAW)")
+	}
+
+	t := &tracer{
+		icmp:    conn,
+		dst:     dst,
+		mode:    opts.Mode,
+		id:      rand.Intn(0xffff),
+		timeout: time.Duration(opts.TimeoutMs) * time.Millisecond,
+	}
+
+	if opts.Mode == TraceModeUDP {
+		udpConn, err := net.ListenPacket("udp4", "0.0.0.0:0")
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("listen UDP: %w", err)
+		}
+		t.udp = ipv4.NewPacketConn(udpConn)
+		t.srcPort = udpConn.LocalAddr().(*net.UDPAddr).Port
+	}
+
+	return t, nil
+}
+
+func (t *tracer) close() {
+	t.icmp.Close()
+	if t.udp != nil {
+		t.udp.Close()
+	}
+}
+
+// probe sends one probe with the given TTL and waits for the matching
+// ICMP reply, at most until the probe timeout or the deadline of ctx
+func (t *tracer) probe(ctx context.Context, ttl, seq int) (probeReply, error) {
+	start := time.Now()
+	if err := t.send(ttl, seq); err != nil {
+		return probeReply{}, err
+	}
+
+	deadline := start.Add(t.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := t.icmp.SetReadDeadline(deadline); err != nil {
+		return probeReply{}, fmt.Errorf("set deadline: %w", err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := t.icmp.ReadFrom(buf)
+		if err != nil {
+			return probeReply{}, fmt.Errorf("no reply for TTL %d: %w", ttl, err)
+		}
+		if got, reply, ok := t.parseReply(buf[:n], peer); ok && got == seq {
+			reply.rtt = time.Since(start)
+			return reply, nil
+		}
+	}
+}
+
+// parseReply decodes an ICMP message that answers one of this tracer's
+// probes and returns the probe's sequence number. The RTT is left to the
+// caller.
+func (t *tracer) parseReply(data []byte, peer net.Addr) (int, probeReply, bool) {
+	msg, err := parseICMP(data)
+	if err != nil {
+		return 0, probeReply{}, false
+	}
+	peerIP := addrIP(peer)
+
+	switch body := msg.Body.(type) {
+	case *icmp.Echo:
+		if msg.Type == ipv4.ICMPTypeEchoReply && t.mode == TraceModeICMP &&
+			body.ID == t.id && peerIP.Equal(t.dst) {
+			return body.Seq, probeReply{addr: peerIP.String(), reached: true}, true
+		}
+	case *icmp.TimeExceeded:
+		if seq, ok := t.quotedSeq(body.Data); ok {
+			return seq, probeReply{addr: peerIP.String()}, true
+		}
+	case *icmp.DstUnreach:
+		if seq, ok := t.quotedSeq(body.Data); ok {
+			// Port unreachable from the destination is how UDP probes
+			// arrive; anything else ends the trace early
+			reached := peerIP.Equal(t.dst)
+			return seq, probeReply{addr: peerIP.String(), reached: reached, stop: !reached}, true
+		}
+	}
+	return 0, probeReply{}, false
+}
+
+// send transmits a probe with the given TTL. The sequence number is
+// encoded in the echo sequence (ICMP mode) or destination port (UDP mode)
+// so replies can be matched back to it.
+func (t *tracer) send(ttl, seq int) error {
+	if t.mode == TraceModeICMP {
+		if err := t.icmp.IPv4PacketConn().SetTTL(ttl); err != nil {
+			return fmt.Errorf("set TTL: %w", err)
+		}
+		msg := icmp.Message{
+			Type: ipv4.ICMPTypeEcho,
+			Body: &icmp.Echo{ID: t.id, Seq: seq, Data: []byte("traceroute-probe")},
+		}
+		data, err := msg.Marshal(nil)
+		if err != nil {
+			return fmt.Errorf("marshal ICMP: %w", err)
+		}
+		if _, err := t.icmp.WriteTo(data, &net.IPAddr{IP: t.dst}); err != nil {
+			return fmt.Errorf("send ICMP: %w", err)
+		}
+		return nil
+	}
+
+	if err := t.udp.SetTTL(ttl); err != nil {
+		return fmt.Errorf("set TTL: %w", err)
+	}
+	dst := &net.UDPAddr{IP: t.dst, Port: traceBasePort + seq}
+	if _, err := t.udp.WriteTo([]byte("traceroute-probe"), nil, dst); err != nil {
+		return fmt.Errorf("send UDP: %w", err)
+	}
+	return nil
+}
+
+// quotedSeq returns the sequence number of the probe quoted in an ICMP
+// error (IP header plus at least 8 bytes of payload), if it is ours
+func (t *tracer) quotedSeq(quoted []byte) (int, bool) {
+	if len(quoted) < 20 {
+		return 0, false
+	}
+	headerLen := int(quoted[0]&0x0f) * 4
+	if len(quoted) < headerLen+8 || !net.IP(quoted[16:20]).Equal(t.dst) {
+		return 0, false
+	}
+	payload := quoted[headerLen:]
+
+	switch t.mode {
+	case TraceModeICMP:
+		if quoted[9] == 1 && // ICMP
+			payload[0] == byte(ipv4.ICMPTypeEcho) &&
+			int(binary.BigEndian.Uint16(payload[4:6])) == t.id {
+			return int(binary.BigEndian.Uint16(payload[6:8])), true
+		}
+	case TraceModeUDP:
+		port := int(binary.BigEndian.Uint16(payload[2:4]))
+		if quoted[9] == 17 && // UDP
+			int(binary.BigEndian.Uint16(payload[0:2])) == t.srcPort && port > traceBasePort {
+			return port - traceBasePort, true
+		}
+	}
+	return 0, false
+}
+
+// summarizeHop fills in the loss and average RTT of a hop
+func summarizeHop(hop *storage.TraceHop) {
+	if hop.Sent > 0 {
+		hop.Loss = float64(hop.Sent-hop.Received) / float64(hop.Sent) * 100
+	}
+	if len(hop.RTTs) > 0 {
+		var total time.Duration
+		for _, rtt := range hop.RTTs {
+			total += rtt
+		}
+		hop.AvgRTT = total / time.Duration(len(hop.RTTs))
+	}
+}
+
+// TraceCollector runs traceroutes to selected targets on a schedule and
+// records path changes
+type TraceCollector struct {
+	store   *storage.Store
+	targets []string
+	opts    TraceOptions
+}
+
+func NewTraceCollector(store *storage.Store, targets []string, opts TraceOptions) *TraceCollector {
+	return &TraceCollector{store: store, targets: targets, opts: opts}
+}
+
+func (tc *TraceCollector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Trace collector started for %v", tc.targets)
+
+	for {
+		tc.collectTraces()
+		<-ticker.C
+	}
+}
+
+func (tc *TraceCollector) collectTraces() {
+	for _, target := range tc.targets {
+		trace, err := Traceroute(context.Background(), target, tc.opts)
+		if err != nil {
+			log.Printf("Traceroute to %s failed: %v", target, err)
+			continue
+		}
+
+		if tc.store.StoreTrace(*trace) {
+			log.Printf("Path to %s changed: %v", target, trace.Path())
+		}
+	}
+}
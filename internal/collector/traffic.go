@@ -11,10 +11,23 @@ import (
 
 type TrafficCollector struct {
 	store *storage.Store
+	rules *interfaceRules
 }
 
 func NewTrafficCollector(store *storage.Store) *TrafficCollector {
-	return &TrafficCollector{store: store}
+	rules, _ := compileInterfaceFilter(InterfaceFilter{})
+	return &TrafficCollector{store: store, rules: rules}
+}
+
+// SetFilter replaces the interface include/exclude rules, groups and totals
+// selection
+func (tc *TrafficCollector) SetFilter(filter InterfaceFilter) error {
+	rules, err := compileInterfaceFilter(filter)
+	if err != nil {
+		return err
+	}
+	tc.rules = rules
+	return nil
 }
 
 func (tc *TrafficCollector) Start(interval time.Duration) {
@@ -36,18 +49,27 @@ func (tc *TrafficCollector) collectTrafficStats() {
 		return
 	}
 
+	var collected []string
 	for _, iface := range interfaces {
-		// Skip loopback interfaces (Linux and Windows)
-		if iface.Name == "lo" || iface.Name == "Loopback Pseudo-Interface 1" {
+		if !tc.rules.collects(iface.Name) {
 			continue
 		}
+		collected = append(collected, iface.Name)
 
-		tc.store.UpdateInterface(
-			iface.Name,
-			iface.BytesRecv,
-			iface.BytesSent,
-			iface.PacketsRecv,
-			iface.PacketsSent,
-		)
+		tc.store.UpdateInterfaceCounters(iface.Name, storage.InterfaceCounters{
+			BytesRx:   iface.BytesRecv,
+			BytesTx:   iface.BytesSent,
+			PacketsRx: iface.PacketsRecv,
+			PacketsTx: iface.PacketsSent,
+			ErrorsRx:  iface.Errin,
+			ErrorsTx:  iface.Errout,
+			DropsRx:   iface.Dropin,
+			DropsTx:   iface.Dropout,
+			FifoRx:    iface.Fifoin,
+			FifoTx:    iface.Fifoout,
+		})
 	}
+
+	groups, totals := tc.rules.membership(collected)
+	tc.store.SetInterfaceGroups(groups, totals)
 }
@@ -0,0 +1,361 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"network-monitor/internal/snmp"
+	"network-monitor/internal/storage"
+)
+
+// TrapOptions configures the SNMP trap receiver
+type TrapOptions struct {
+	// Communities accepted from v1/v2c senders; empty accepts any
+	Communities []string `json:"communities"`
+	// Users accepted from v3 senders
+	Users []snmp.User `json:"users"`
+	// MIBFiles are name maps ("name OID" per line, or snmptranslate -Tz
+	// output) used to name trap and variable OIDs
+	MIBFiles []string   `json:"mib_files"`
+	Rules    []TrapRule `json:"rules"`
+}
+
+// TrapRule raises an alert when a matching trap arrives
+type TrapRule struct {
+	Trap      string `json:"trap"`                 // trap name or OID; an OID matches its whole subtree
+	Agent     string `json:"agent,omitempty"`      // only traps sent by this address
+	Severity  string `json:"severity,omitempty"`   // warning by default
+	ClearTrap string `json:"clear_trap,omitempty"` // trap resolving the alert, e.g. linkUp for linkDown
+}
+
+type trapRule struct {
+	TrapRule
+	oid   snmp.OID
+	clear snmp.OID
+}
+
+// Interface table columns; a trap carrying one of them is about the
+// interface with that index
+var (
+	ifEntry  = snmp.MustParseOID("1.3.6.1.2.1.2.2.1")
+	ifXEntry = snmp.MustParseOID("1.3.6.1.2.1.31.1.1.1")
+)
+
+// Reports sent to v3 senders (RFC 3414)
+var (
+	usmStatsUnknownUserNames = snmp.MustParseOID("1.3.6.1.6.3.15.1.1.3.0")
+	usmStatsUnknownEngineIDs = snmp.MustParseOID("1.3.6.1.6.3.15.1.1.4.0")
+	usmStatsWrongDigests     = snmp.MustParseOID("1.3.6.1.6.3.15.1.1.5.0")
+)
+
+// TrapReceiver records SNMP traps and informs as events and raises the
+// alerts of matching rules
+type TrapReceiver struct {
+	store       *storage.Store
+	addr        string
+	communities map[string]bool
+	users       map[string]snmp.User
+	masterKeys  map[string]*snmp.MasterKeys // by user
+	mib         *snmp.MIB
+	rules       []trapRule
+
+	// This receiver is the authoritative engine for v3 informs
+	engineID []byte
+	started  time.Time
+
+	warnings senderWarnings
+}
+
+// NewTrapReceiver loads the MIB name maps and compiles the rules
+func NewTrapReceiver(store *storage.Store, addr string, opts TrapOptions) (*TrapReceiver, error) {
+	mib := snmp.NewMIB()
+	for _, path := range opts.MIBFiles {
+		if err := mib.LoadFile(path); err != nil {
+			return nil, fmt.Errorf("load MIB names: %w", err)
+		}
+	}
+
+	tr := &TrapReceiver{
+		store:       store,
+		addr:        addr,
+		communities: make(map[string]bool),
+		users:       make(map[string]snmp.User),
+		masterKeys:  make(map[string]*snmp.MasterKeys),
+		mib:         mib,
+		engineID:    localEngineID(),
+		started:     time.Now(),
+		warnings:    senderWarnings{prefix: "SNMP trap from %s: "},
+	}
+	for _, community := range opts.Communities {
+		tr.communities[community] = true
+	}
+	for _, user := range opts.Users {
+		// The passwords are hashed once; each trap only localizes the
+		// keys to its engine
+		master, err := user.MasterKeys()
+		if err != nil {
+			return nil, fmt.Errorf("SNMP user %s: %w", user.Name, err)
+		}
+		tr.users[user.Name] = user
+		tr.masterKeys[user.Name] = master
+	}
+	for _, rule := range opts.Rules {
+		compiled := trapRule{TrapRule: rule}
+		oid, ok := mib.Lookup(rule.Trap)
+		if !ok {
+			return nil, fmt.Errorf("trap rule: unknown trap %q", rule.Trap)
+		}
+		compiled.oid = oid
+		if rule.ClearTrap != "" {
+			if compiled.clear, ok = mib.Lookup(rule.ClearTrap); !ok {
+				return nil, fmt.Errorf("trap rule: unknown trap %q", rule.ClearTrap)
+			}
+		}
+		if compiled.Severity == "" {
+			compiled.Severity = storage.SeverityWarning
+		}
+		tr.rules = append(tr.rules, compiled)
+	}
+	return tr, nil
+}
+
+// localEngineID derives a stable engine ID from the host name, in the
+// RFC 3411 text format
+func localEngineID() []byte {
+	host, _ := os.Hostname()
+	id := "netmon-" + host
+	if len(id) > 27 {
+		id = id[:27]
+	}
+	return append([]byte{0x80, 0x00, 0x00, 0x00, 0x04}, id...)
+}
+
+// Start listens for traps until the socket fails
+func (tr *TrapReceiver) Start() {
+	conn, err := net.ListenPacket("udp", tr.addr)
+	if err != nil {
+		log.Printf("SNMP trap receiver on %s unavailable: %v", tr.addr, err)
+		return
+	}
+	defer conn.Close()
+
+	log.Printf("SNMP trap receiver started on %s", tr.addr)
+
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Printf("SNMP trap receiver on %s stopped: %v", tr.addr, err)
+			return
+		}
+		tr.handle(conn, append([]byte(nil), buf[:n]...), addr)
+	}
+}
+
+func (tr *TrapReceiver) handle(conn net.PacketConn, data []byte, addr net.Addr) {
+	sender := addr.String()
+	if udp, ok := addr.(*net.UDPAddr); ok {
+		sender = udp.IP.String()
+	}
+
+	m, err := snmp.Unmarshal(data)
+	if err != nil {
+		tr.warnings.warnOnce(sender, "malformed message: %v", err)
+		return
+	}
+
+	var keys *snmp.SecurityKeys
+	switch m.Version {
+	case snmp.Version1, snmp.Version2c:
+		if len(tr.communities) > 0 && !tr.communities[m.Community] {
+			tr.warnings.warnOnce(sender, "unknown community %q", m.Community)
+			return
+		}
+	case snmp.Version3:
+		var ok bool
+		if keys, ok = tr.openV3(conn, m, addr, sender); !ok {
+			return
+		}
+	}
+
+	notification, err := snmp.NotificationFromPDU(m.PDU)
+	if err != nil {
+		tr.warnings.warnOnce(sender, "%v", err)
+		return
+	}
+	if m.PDU.Type == snmp.InformRequest {
+		tr.acknowledge(conn, m, keys, addr)
+	}
+	tr.record(notification, sender, m)
+}
+
+// openV3 authenticates and decrypts a v3 message. Informs are addressed to
+// this receiver's engine; senders that do not know it yet get a report
+// carrying it, as do senders with unknown users or wrong keys.
+func (tr *TrapReceiver) openV3(conn net.PacketConn, m *snmp.Message, addr net.Addr, sender string) (*snmp.SecurityKeys, bool) {
+	if m.Reportable && !bytes.Equal(m.Security.EngineID, tr.engineID) {
+		tr.report(conn, m, addr, usmStatsUnknownEngineIDs)
+		return nil, false
+	}
+
+	user, known := tr.users[m.Security.UserName]
+	if !known {
+		tr.warnings.warnOnce(sender, "unknown user %q", m.Security.UserName)
+		if m.Reportable {
+			tr.report(conn, m, addr, usmStatsUnknownUserNames)
+		}
+		return nil, false
+	}
+
+	if user.AuthProtocol != snmp.AuthNone && !m.Authenticated() {
+		tr.warnings.warnOnce(sender, "unauthenticated message for user %s", user.Name)
+		return nil, false
+	}
+	// Traps are sent with the sender's engine ID, informs with ours
+	keys := tr.masterKeys[user.Name].Localize(m.Security.EngineID)
+	if err := m.Open(keys); err != nil {
+		tr.warnings.warnOnce(sender, "user %s: %v", user.Name, err)
+		if m.Reportable {
+			tr.report(conn, m, addr, usmStatsWrongDigests)
+		}
+		return nil, false
+	}
+	return keys, true
+}
+
+// v3Reply prepares a v3 message from this receiver's engine
+func (tr *TrapReceiver) v3Reply(m *snmp.Message, pdu snmp.PDU) *snmp.Message {
+	return &snmp.Message{
+		Version:         snmp.Version3,
+		MessageID:       m.MessageID,
+		ContextEngineID: tr.engineID,
+		ContextName:     m.ContextName,
+		PDU:             pdu,
+		Security: snmp.SecurityParameters{
+			EngineID:    tr.engineID,
+			EngineBoots: 1,
+			EngineTime:  int32(time.Since(tr.started).Seconds()),
+			UserName:    m.Security.UserName,
+		},
+	}
+}
+
+// report sends an unauthenticated USM report
+func (tr *TrapReceiver) report(conn net.PacketConn, m *snmp.Message, addr net.Addr, oid snmp.OID) {
+	reply := tr.v3Reply(m, snmp.PDU{
+		Type:      snmp.Report,
+		RequestID: m.PDU.RequestID,
+		Variables: []snmp.Variable{{OID: oid, Type: snmp.Counter32, Value: uint64(1)}},
+	})
+	if data, err := reply.Marshal(nil); err == nil {
+		conn.WriteTo(data, addr)
+	}
+}
+
+// acknowledge answers an inform with a response echoing its variables
+func (tr *TrapReceiver) acknowledge(conn net.PacketConn, m *snmp.Message, keys *snmp.SecurityKeys, addr net.Addr) {
+	pdu := snmp.PDU{Type: snmp.GetResponse, RequestID: m.PDU.RequestID, Variables: m.PDU.Variables}
+	reply := &snmp.Message{Version: m.Version, Community: m.Community, PDU: pdu}
+	if m.Version == snmp.Version3 {
+		reply = tr.v3Reply(m, pdu)
+	}
+	data, err := reply.Marshal(keys)
+	if err != nil {
+		log.Printf("Could not acknowledge inform: %v", err)
+		return
+	}
+	conn.WriteTo(data, addr)
+}
+
+// record stores the notification as an event and applies the rules
+func (tr *TrapReceiver) record(n *snmp.Notification, sender string, m *snmp.Message) {
+	trapName := tr.mib.Name(n.TrapOID)
+	fields := map[string]string{
+		"trap":     trapName,
+		"trap_oid": n.TrapOID.String(),
+		"version":  m.Version.String(),
+		"uptime":   (time.Duration(n.Uptime) * 10 * time.Millisecond).String(),
+	}
+	if m.Version == snmp.Version3 {
+		fields["user"] = m.Security.UserName
+	}
+	if n.AgentAddress != nil && !n.AgentAddress.IsUnspecified() {
+		fields["agent_address"] = n.AgentAddress.String()
+	}
+
+	var values []string
+	instance := ""
+	for _, v := range n.Variables {
+		name := tr.mib.Name(v.OID)
+		value := v.String()
+		if oid, ok := v.Value.(snmp.OID); ok {
+			value = tr.mib.Name(oid)
+		}
+		fields[name] = value
+		values = append(values, name+"="+value)
+
+		if instance == "" {
+			instance = interfaceIndex(v.OID)
+		}
+	}
+	if instance != "" {
+		fields["ifIndex"] = instance
+	}
+
+	message := fmt.Sprintf("%s from %s", trapName, sender)
+	if len(values) > 0 {
+		message += ": " + strings.Join(values, ", ")
+	}
+
+	severity := defaultTrapSeverity(trapName)
+	for _, rule := range tr.rules {
+		if rule.Agent != "" && rule.Agent != sender {
+			continue
+		}
+		alertKey := "trap:" + rule.Trap + ":" + sender
+		if instance != "" {
+			alertKey += ":" + instance
+		}
+		if n.TrapOID.HasPrefix(rule.oid) {
+			severity = rule.Severity
+			tr.store.RaiseAlert(alertKey, "snmp", sender, rule.Severity, message)
+		} else if rule.clear != nil && n.TrapOID.HasPrefix(rule.clear) {
+			tr.store.ResolveAlert(alertKey)
+		}
+	}
+
+	tr.store.AddEvent(storage.Event{
+		Type:     storage.EventSNMPTrap,
+		Source:   "snmp",
+		Target:   sender,
+		Severity: severity,
+		Message:  message,
+		Fields:   fields,
+	})
+}
+
+// interfaceIndex returns the ifIndex of an ifTable or ifXTable column
+// instance, or "" for other OIDs
+func interfaceIndex(oid snmp.OID) string {
+	for _, entry := range []snmp.OID{ifEntry, ifXEntry} {
+		// entry, column, index
+		if len(oid) == len(entry)+2 && oid.HasPrefix(entry) {
+			return fmt.Sprint(oid[len(oid)-1])
+		}
+	}
+	return ""
+}
+
+// defaultTrapSeverity grades the generic traps that signal a problem
+func defaultTrapSeverity(trap string) string {
+	switch trap {
+	case "linkDown", "authenticationFailure":
+		return storage.SeverityWarning
+	}
+	return storage.SeverityInfo
+}
@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"log"
+	"sync"
+)
+
+// maxWarnings caps the problems a senderWarnings remembers. Senders are
+// taken from UDP datagrams, which can be spoofed, so once the cap is
+// reached new problems are not logged rather than remembered.
+const maxWarnings = 512
+
+// senderWarnings logs each problem with a sender the first time it
+// happens. Prefix, if set, is printed before every message with the
+// sender. It is safe for concurrent use.
+type senderWarnings struct {
+	prefix string
+
+	mu     sync.Mutex
+	warned map[string]bool
+}
+
+func (w *senderWarnings) warnOnce(sender, format string, args ...any) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := sender + ":" + format
+	if w.warned[key] || len(w.warned) >= maxWarnings {
+		return
+	}
+	if w.warned == nil {
+		w.warned = make(map[string]bool)
+	}
+	w.warned[key] = true
+	if w.prefix != "" {
+		format, args = w.prefix+format, append([]any{sender}, args...)
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSenderWarningsBounded(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	log.SetFlags(0)
+	t.Cleanup(func() { log.SetOutput(os.Stderr); log.SetFlags(log.LstdFlags) })
+
+	w := senderWarnings{prefix: "Syslog from %s: "}
+	w.warnOnce("192.0.2.1", "malformed message: %v", "bad priority")
+	w.warnOnce("192.0.2.1", "malformed message: %v", "bad timestamp")
+	if got := out.String(); got != "Syslog from 192.0.2.1: malformed message: bad priority\n" {
+		t.Fatalf("logged %q, want the first problem once", got)
+	}
+
+	for i := 0; i < 2*maxWarnings; i++ {
+		w.warnOnce(fmt.Sprintf("sender%d", i), "malformed message: %v", "junk")
+	}
+	if len(w.warned) != maxWarnings {
+		t.Errorf("%d problems remembered, want %d", len(w.warned), maxWarnings)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != maxWarnings {
+		t.Errorf("%d lines logged, want %d", lines, maxWarnings)
+	}
+}
@@ -0,0 +1,415 @@
+// Package netflow decodes NetFlow v5, NetFlow v9 and IPFIX export packets.
+// Templates announced by v9 and IPFIX exporters are cached per exporter and
+// observation domain, so a Decoder must see every packet of an exporter.
+package netflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Record is one decoded flow record
+type Record struct {
+	SrcAddr  net.IP
+	DstAddr  net.IP
+	SrcPort  uint16
+	DstPort  uint16
+	Proto    uint8
+	TCPFlags uint8
+	SrcMAC   string
+	DstMAC   string
+	Bytes    uint64
+	Packets  uint64
+	Start    time.Time
+	End      time.Time
+}
+
+// Information element IDs shared by NetFlow v9 and IPFIX
+const (
+	fieldInBytes        = 1
+	fieldInPkts         = 2
+	fieldProtocol       = 4
+	fieldTCPFlags       = 6
+	fieldSrcPort        = 7
+	fieldIPv4Src        = 8
+	fieldDstPort        = 11
+	fieldIPv4Dst        = 12
+	fieldLastSwitched   = 21
+	fieldFirstSwitched  = 22
+	fieldOutBytes       = 23
+	fieldOutPkts        = 24
+	fieldIPv6Src        = 27
+	fieldIPv6Dst        = 28
+	fieldSamplingInt    = 34
+	fieldSrcMAC         = 56
+	fieldV9DstMAC       = 57 // OUT_DST_MAC in v9
+	fieldDstMAC         = 80 // destinationMacAddress in IPFIX
+	fieldOctetTotal     = 85
+	fieldPacketTotal    = 86
+	fieldStartSeconds   = 150
+	fieldEndSeconds     = 151
+	fieldStartMillis    = 152
+	fieldEndMillis      = 153
+	fieldSamplingPktInt = 305
+)
+
+const variableLength = 65535
+
+// maxTemplates caps the templates remembered across all exporters, as
+// each exporter may define thousands of them
+const maxTemplates = 8192
+
+var errShort = errors.New("short packet")
+
+type templateKey struct {
+	exporter string
+	domain   uint32
+	id       uint16
+}
+
+type templateField struct {
+	id         uint16
+	length     uint16
+	enterprise bool
+}
+
+// Decoder decodes export packets, remembering the templates they define
+type Decoder struct {
+	templates map[templateKey][]templateField
+
+	// MissingTemplate counts data records dropped because their template
+	// had not been received yet
+	MissingTemplate uint64
+	// DroppedTemplates counts new templates ignored because maxTemplates
+	// were already known
+	DroppedTemplates uint64
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{templates: make(map[templateKey][]templateField)}
+}
+
+// Decode parses one export packet received from exporter
+func (d *Decoder) Decode(packet []byte, exporter string) ([]Record, error) {
+	if len(packet) < 2 {
+		return nil, errShort
+	}
+	switch version := binary.BigEndian.Uint16(packet[0:2]); version {
+	case 5:
+		return decodeV5(packet)
+	case 9:
+		return d.decodeV9(packet, exporter)
+	case 10:
+		return d.decodeIPFIX(packet, exporter)
+	default:
+		return nil, fmt.Errorf("unsupported NetFlow version %d", version)
+	}
+}
+
+// decodeV5 parses the fixed NetFlow v5 format
+func decodeV5(packet []byte) ([]Record, error) {
+	const headerLen, recordLen = 24, 48
+	if len(packet) < headerLen {
+		return nil, errShort
+	}
+	count := int(binary.BigEndian.Uint16(packet[2:4]))
+	uptime := binary.BigEndian.Uint32(packet[4:8])
+	exportTime := time.Unix(int64(binary.BigEndian.Uint32(packet[8:12])), int64(binary.BigEndian.Uint32(packet[12:16])))
+	sampling := uint64(binary.BigEndian.Uint16(packet[22:24]) & 0x3fff)
+	if sampling == 0 {
+		sampling = 1
+	}
+	if len(packet) < headerLen+count*recordLen {
+		return nil, errShort
+	}
+
+	records := make([]Record, 0, count)
+	for i := 0; i < count; i++ {
+		r := packet[headerLen+i*recordLen:]
+		records = append(records, Record{
+			SrcAddr:  net.IP(append([]byte(nil), r[0:4]...)),
+			DstAddr:  net.IP(append([]byte(nil), r[4:8]...)),
+			Packets:  uint64(binary.BigEndian.Uint32(r[16:20])) * sampling,
+			Bytes:    uint64(binary.BigEndian.Uint32(r[20:24])) * sampling,
+			Start:    uptimeToTime(exportTime, uptime, binary.BigEndian.Uint32(r[24:28])),
+			End:      uptimeToTime(exportTime, uptime, binary.BigEndian.Uint32(r[28:32])),
+			SrcPort:  binary.BigEndian.Uint16(r[32:34]),
+			DstPort:  binary.BigEndian.Uint16(r[34:36]),
+			TCPFlags: r[37],
+			Proto:    r[38],
+		})
+	}
+	return records, nil
+}
+
+// decodeV9 walks the flowsets of a NetFlow v9 packet
+func (d *Decoder) decodeV9(packet []byte, exporter string) ([]Record, error) {
+	const headerLen = 20
+	if len(packet) < headerLen {
+		return nil, errShort
+	}
+	uptime := binary.BigEndian.Uint32(packet[4:8])
+	exportTime := time.Unix(int64(binary.BigEndian.Uint32(packet[8:12])), 0)
+	sourceID := binary.BigEndian.Uint32(packet[16:20])
+
+	var records []Record
+	sets := packet[headerLen:]
+	for len(sets) >= 4 {
+		setID := binary.BigEndian.Uint16(sets[0:2])
+		length := int(binary.BigEndian.Uint16(sets[2:4]))
+		if length < 4 || length > len(sets) {
+			return records, fmt.Errorf("flowset length %d out of range", length)
+		}
+		body := sets[4:length]
+		sets = sets[length:]
+
+		switch {
+		case setID == 0:
+			d.parseTemplates(body, exporter, sourceID, false)
+		case setID == 1:
+			// Options templates describe exporter metadata, not flows
+		case setID >= 256:
+			fields, ok := d.templates[templateKey{exporter, sourceID, setID}]
+			if !ok {
+				d.MissingTemplate++
+				continue
+			}
+			records = append(records, decodeDataSet(body, fields, exportTime, uptime)...)
+		}
+	}
+	return records, nil
+}
+
+// decodeIPFIX walks the sets of an IPFIX message
+func (d *Decoder) decodeIPFIX(packet []byte, exporter string) ([]Record, error) {
+	const headerLen = 16
+	if len(packet) < headerLen {
+		return nil, errShort
+	}
+	length := int(binary.BigEndian.Uint16(packet[2:4]))
+	if length < headerLen || length > len(packet) {
+		return nil, fmt.Errorf("IPFIX message length %d out of range", length)
+	}
+	exportTime := time.Unix(int64(binary.BigEndian.Uint32(packet[4:8])), 0)
+	domain := binary.BigEndian.Uint32(packet[12:16])
+
+	var records []Record
+	sets := packet[headerLen:length]
+	for len(sets) >= 4 {
+		setID := binary.BigEndian.Uint16(sets[0:2])
+		setLen := int(binary.BigEndian.Uint16(sets[2:4]))
+		if setLen < 4 || setLen > len(sets) {
+			return records, fmt.Errorf("set length %d out of range", setLen)
+		}
+		body := sets[4:setLen]
+		sets = sets[setLen:]
+
+		switch {
+		case setID == 2:
+			d.parseTemplates(body, exporter, domain, true)
+		case setID == 3:
+			// Options templates
+		case setID >= 256:
+			fields, ok := d.templates[templateKey{exporter, domain, setID}]
+			if !ok {
+				d.MissingTemplate++
+				continue
+			}
+			// IPFIX has no uptime in the header; relative times fall
+			// back to the export time
+			records = append(records, decodeDataSet(body, fields, exportTime, 0)...)
+		}
+	}
+	return records, nil
+}
+
+// parseTemplates stores the templates of a template set. IPFIX field
+// specifiers with the enterprise bit carry a 4 byte enterprise number.
+func (d *Decoder) parseTemplates(body []byte, exporter string, domain uint32, ipfix bool) {
+	for len(body) >= 4 {
+		id := binary.BigEndian.Uint16(body[0:2])
+		count := int(binary.BigEndian.Uint16(body[2:4]))
+		body = body[4:]
+		if id < 256 {
+			return // padding
+		}
+
+		fields := make([]templateField, 0, count)
+		size := 0
+		for i := 0; i < count; i++ {
+			if len(body) < 4 {
+				return
+			}
+			field := templateField{
+				id:     binary.BigEndian.Uint16(body[0:2]),
+				length: binary.BigEndian.Uint16(body[2:4]),
+			}
+			body = body[4:]
+			if ipfix && field.id&0x8000 != 0 {
+				if len(body) < 4 {
+					return
+				}
+				field.id &^= 0x8000
+				field.enterprise = true
+				body = body[4:]
+			}
+			if field.length == variableLength {
+				size++ // at least the length byte
+			} else {
+				size += int(field.length)
+			}
+			fields = append(fields, field)
+		}
+
+		key := templateKey{exporter, domain, id}
+		if count == 0 {
+			delete(d.templates, key) // template withdrawal
+			continue
+		}
+		if size == 0 {
+			continue // records of no bytes could never be walked past
+		}
+		if _, known := d.templates[key]; !known && len(d.templates) >= maxTemplates {
+			d.DroppedTemplates++
+			continue
+		}
+		d.templates[key] = fields
+	}
+}
+
+// decodeDataSet parses the records of a data set. uptime is the exporter's
+// uptime in milliseconds when the packet was sent, 0 if unknown.
+func decodeDataSet(body []byte, fields []templateField, exportTime time.Time, uptime uint32) []Record {
+	var records []Record
+	for len(body) > 0 {
+		before := len(body)
+		record := Record{}
+		var sampling uint64 = 1
+		var firstUp, lastUp uint32
+		var haveFirst, haveLast bool
+		ok := true
+
+		for _, field := range fields {
+			length := int(field.length)
+			if field.length == variableLength {
+				if len(body) < 1 {
+					ok = false
+					break
+				}
+				length = int(body[0])
+				body = body[1:]
+				if length == 255 {
+					if len(body) < 2 {
+						ok = false
+						break
+					}
+					length = int(binary.BigEndian.Uint16(body[0:2]))
+					body = body[2:]
+				}
+			}
+			if length > len(body) {
+				ok = false
+				break
+			}
+			value := body[:length]
+			body = body[length:]
+			if field.enterprise {
+				continue
+			}
+
+			switch field.id {
+			case fieldInBytes, fieldOctetTotal:
+				record.Bytes = uintValue(value)
+			case fieldOutBytes:
+				if record.Bytes == 0 {
+					record.Bytes = uintValue(value)
+				}
+			case fieldInPkts, fieldPacketTotal:
+				record.Packets = uintValue(value)
+			case fieldOutPkts:
+				if record.Packets == 0 {
+					record.Packets = uintValue(value)
+				}
+			case fieldProtocol:
+				record.Proto = uint8(uintValue(value))
+			case fieldTCPFlags:
+				record.TCPFlags = uint8(uintValue(value))
+			case fieldSrcPort:
+				record.SrcPort = uint16(uintValue(value))
+			case fieldDstPort:
+				record.DstPort = uint16(uintValue(value))
+			case fieldIPv4Src, fieldIPv6Src:
+				record.SrcAddr = net.IP(append([]byte(nil), value...))
+			case fieldIPv4Dst, fieldIPv6Dst:
+				record.DstAddr = net.IP(append([]byte(nil), value...))
+			case fieldSrcMAC:
+				record.SrcMAC = macValue(value)
+			case fieldV9DstMAC, fieldDstMAC:
+				record.DstMAC = macValue(value)
+			case fieldFirstSwitched:
+				firstUp, haveFirst = uint32(uintValue(value)), true
+			case fieldLastSwitched:
+				lastUp, haveLast = uint32(uintValue(value)), true
+			case fieldStartSeconds:
+				record.Start = time.Unix(int64(uintValue(value)), 0)
+			case fieldEndSeconds:
+				record.End = time.Unix(int64(uintValue(value)), 0)
+			case fieldStartMillis:
+				record.Start = time.UnixMilli(int64(uintValue(value)))
+			case fieldEndMillis:
+				record.End = time.UnixMilli(int64(uintValue(value)))
+			case fieldSamplingInt, fieldSamplingPktInt:
+				if v := uintValue(value); v > 1 {
+					sampling = v
+				}
+			}
+		}
+		if !ok || len(body) == before {
+			break // trailing padding
+		}
+		if record.SrcAddr == nil && record.DstAddr == nil {
+			continue
+		}
+
+		if record.Start.IsZero() {
+			record.Start = exportTime
+			if haveFirst && uptime > 0 {
+				record.Start = uptimeToTime(exportTime, uptime, firstUp)
+			}
+		}
+		if record.End.IsZero() {
+			record.End = exportTime
+			if haveLast && uptime > 0 {
+				record.End = uptimeToTime(exportTime, uptime, lastUp)
+			}
+		}
+		record.Bytes *= sampling
+		record.Packets *= sampling
+		records = append(records, record)
+	}
+	return records
+}
+
+// uptimeToTime converts an exporter uptime timestamp to wall clock time
+func uptimeToTime(exportTime time.Time, uptime, at uint32) time.Time {
+	// Unsigned subtraction handles the 49.7 day uptime wrap
+	return exportTime.Add(-time.Duration(uptime-at) * time.Millisecond)
+}
+
+func uintValue(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func macValue(b []byte) string {
+	if len(b) != 6 {
+		return ""
+	}
+	return net.HardwareAddr(b).String()
+}
@@ -0,0 +1,212 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// be appends big-endian integers of the given widths to b
+func be(b []byte, values ...any) []byte {
+	for _, v := range values {
+		switch v := v.(type) {
+		case uint8:
+			b = append(b, v)
+		case uint16:
+			b = binary.BigEndian.AppendUint16(b, v)
+		case uint32:
+			b = binary.BigEndian.AppendUint32(b, v)
+		case []byte:
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+// set wraps a body in a v9 flowset or IPFIX set header
+func set(id uint16, body []byte) []byte {
+	return append(be(nil, id, uint16(4+len(body))), body...)
+}
+
+func v5Packet() []byte {
+	p := be(nil, uint16(5), uint16(1), uint32(60000), uint32(1700000000), uint32(0), uint32(1), uint8(0), uint8(0), uint16(0))
+	r := be(nil,
+		[]byte{192, 168, 1, 10}, []byte{8, 8, 8, 8}, []byte{0, 0, 0, 0},
+		uint16(0), uint16(0), uint32(3), uint32(1500),
+		uint32(50000), uint32(59000), uint16(40000), uint16(53),
+		uint8(0), uint8(0x18), uint8(17), uint8(0),
+		uint16(0), uint16(0), uint8(0), uint8(0), uint16(0))
+	return append(p, r...)
+}
+
+func v9Packet() []byte {
+	template := be(nil, uint16(300), uint16(5),
+		uint16(fieldIPv4Src), uint16(4),
+		uint16(fieldIPv4Dst), uint16(4),
+		uint16(fieldDstPort), uint16(2),
+		uint16(fieldProtocol), uint16(1),
+		uint16(fieldInBytes), uint16(4))
+	data := be(nil, []byte{10, 0, 0, 1}, []byte{1, 1, 1, 1}, uint16(443), uint8(6), uint32(9000))
+	data = append(data, 0, 0, 0) // padding
+	p := be(nil, uint16(9), uint16(2), uint32(60000), uint32(1700000000), uint32(1), uint32(7))
+	p = append(p, set(0, template)...)
+	return append(p, set(300, data)...)
+}
+
+func ipfixMessage(sets ...[]byte) []byte {
+	var body []byte
+	for _, s := range sets {
+		body = append(body, s...)
+	}
+	p := be(nil, uint16(10), uint16(16+len(body)), uint32(1700000000), uint32(1), uint32(3))
+	return append(p, body...)
+}
+
+func TestDecode(t *testing.T) {
+	ipfixTemplate := be(nil, uint16(400), uint16(4),
+		uint16(fieldIPv6Src), uint16(16),
+		uint16(fieldIPv6Dst), uint16(16),
+		uint16(fieldOctetTotal), uint16(8),
+		uint16(fieldPacketTotal), uint16(8))
+	ipfixData := be(nil, []byte(net.ParseIP("2001:db8::1")), []byte(net.ParseIP("2001:db8::2")), uint32(0), uint32(4000), uint32(0), uint32(4))
+
+	tests := []struct {
+		name    string
+		packet  []byte
+		want    []Record
+		wantErr bool
+	}{
+		{
+			name:   "v5",
+			packet: v5Packet(),
+			want: []Record{{
+				SrcAddr: net.IPv4(192, 168, 1, 10), DstAddr: net.IPv4(8, 8, 8, 8),
+				SrcPort: 40000, DstPort: 53, Proto: 17, TCPFlags: 0x18,
+				Bytes: 1500, Packets: 3,
+			}},
+		},
+		{
+			name:   "v9 template and data",
+			packet: v9Packet(),
+			want: []Record{{
+				SrcAddr: net.IPv4(10, 0, 0, 1), DstAddr: net.IPv4(1, 1, 1, 1),
+				DstPort: 443, Proto: 6, Bytes: 9000,
+			}},
+		},
+		{
+			name:   "IPFIX v6",
+			packet: ipfixMessage(set(2, ipfixTemplate), set(400, ipfixData)),
+			want: []Record{{
+				SrcAddr: net.ParseIP("2001:db8::1"), DstAddr: net.ParseIP("2001:db8::2"),
+				Bytes: 4000, Packets: 4,
+			}},
+		},
+		{
+			name:   "IPFIX data without template",
+			packet: ipfixMessage(set(401, ipfixData)),
+		},
+		{
+			// A template of zero length fields would make every record
+			// consume nothing
+			name:   "IPFIX zero length template",
+			packet: ipfixMessage([]byte{0, 2, 0, 12, 1, 0, 0, 1, 0, 8, 0, 0}, []byte{1, 0, 0, 8, 1, 2, 3, 4}),
+		},
+		{
+			name:    "bad set length",
+			packet:  ipfixMessage([]byte{0, 2, 0, 2}),
+			wantErr: true,
+		},
+		{
+			name:    "unknown version",
+			packet:  []byte{0, 7, 0, 0},
+			wantErr: true,
+		},
+		{
+			name:    "short",
+			packet:  []byte{0},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			type result struct {
+				records []Record
+				err     error
+			}
+			done := make(chan result, 1)
+			go func() {
+				records, err := NewDecoder().Decode(tt.packet, "192.0.2.1")
+				done <- result{records, err}
+			}()
+
+			var got result
+			select {
+			case got = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Decode did not return")
+			}
+			if (got.err != nil) != tt.wantErr {
+				t.Fatalf("Decode error = %v, want error %v", got.err, tt.wantErr)
+			}
+			if len(got.records) != len(tt.want) {
+				t.Fatalf("Decode returned %d records, want %d", len(got.records), len(tt.want))
+			}
+			for i, want := range tt.want {
+				r := got.records[i]
+				if !r.SrcAddr.Equal(want.SrcAddr) || !r.DstAddr.Equal(want.DstAddr) ||
+					r.SrcPort != want.SrcPort || r.DstPort != want.DstPort ||
+					r.Proto != want.Proto || r.TCPFlags != want.TCPFlags ||
+					r.Bytes != want.Bytes || r.Packets != want.Packets {
+					t.Errorf("record %d = %+v, want %+v", i, r, want)
+				}
+				if r.Start.IsZero() || r.End.Before(r.Start) {
+					t.Errorf("record %d times %v to %v", i, r.Start, r.End)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeTemplateAcrossPackets(t *testing.T) {
+	d := NewDecoder()
+	packet := v9Packet()
+	templateEnd := 20 + 4 + 24
+	templateOnly := append([]byte(nil), packet[:templateEnd]...)
+	dataOnly := append(append([]byte(nil), packet[:20]...), packet[templateEnd:]...)
+
+	if records, err := d.Decode(dataOnly, "192.0.2.1"); err != nil || len(records) != 0 {
+		t.Fatalf("data before template: %d records, %v", len(records), err)
+	}
+	if d.MissingTemplate != 1 {
+		t.Errorf("MissingTemplate = %d, want 1", d.MissingTemplate)
+	}
+	if _, err := d.Decode(templateOnly, "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := d.Decode(dataOnly, "192.0.2.1"); err != nil || len(records) != 1 {
+		t.Fatalf("data after template: %d records, %v", len(records), err)
+	}
+	// Templates are per exporter
+	if records, _ := d.Decode(dataOnly, "192.0.2.2"); len(records) != 0 {
+		t.Errorf("other exporter decoded %d records", len(records))
+	}
+}
+
+func TestTemplatesBounded(t *testing.T) {
+	d := NewDecoder()
+	templateOnly := v9Packet()[:20+4+24]
+	for i := 0; i < maxTemplates+10; i++ {
+		if _, err := d.Decode(templateOnly, net.IPv4(10, byte(i>>16), byte(i>>8), byte(i)).String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(d.templates) != maxTemplates || d.DroppedTemplates != 10 {
+		t.Fatalf("%d templates kept, %d dropped, want %d and 10", len(d.templates), d.DroppedTemplates, maxTemplates)
+	}
+	// Known templates can still be refreshed
+	if _, err := d.Decode(templateOnly, "10.0.0.0"); err != nil || d.DroppedTemplates != 10 {
+		t.Errorf("refreshing a known template: %v, %d dropped", err, d.DroppedTemplates)
+	}
+}
@@ -0,0 +1,219 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// IP protocol numbers
+const (
+	ProtoICMP   = 1
+	ProtoTCP    = 6
+	ProtoUDP    = 17
+	ProtoICMPv6 = 58
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+)
+
+// TCP flag bits
+const (
+	TCPFin = 0x01
+	TCPSyn = 0x02
+	TCPRst = 0x04
+	TCPPsh = 0x08
+	TCPAck = 0x10
+	TCPUrg = 0x20
+)
+
+// Decoded holds the header fields of a packet relevant to flow accounting
+type Decoded struct {
+	SrcMAC   string // empty unless the link layer is Ethernet
+	DstMAC   string
+	SrcIP    net.IP
+	DstIP    net.IP
+	Proto    uint8
+	SrcPort  uint16
+	DstPort  uint16
+	TCPFlags uint8
+	Length   int // IP packet length
+}
+
+var errUnsupported = errors.New("unsupported packet")
+
+// Decode parses a captured frame of the given link type. Non-IP frames and
+// unknown link types return an error.
+func Decode(linkType int, data []byte) (Decoded, error) {
+	var d Decoded
+
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return d, errUnsupported
+		}
+		d.DstMAC = net.HardwareAddr(data[0:6]).String()
+		d.SrcMAC = net.HardwareAddr(data[6:12]).String()
+		etherType := binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(data) < 4 {
+				return d, errUnsupported
+			}
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return d, errUnsupported
+		}
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return d, errUnsupported
+		}
+		data = data[16:]
+	case LinkTypeSLL2:
+		if len(data) < 20 {
+			return d, errUnsupported
+		}
+		data = data[20:]
+	case LinkTypeNull:
+		if len(data) < 4 {
+			return d, errUnsupported
+		}
+		data = data[4:]
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+	default:
+		return d, errUnsupported
+	}
+
+	return d, decodeIP(&d, data)
+}
+
+// decodeIP parses an IPv4 or IPv6 packet and its transport header
+func decodeIP(d *Decoded, data []byte) error {
+	if len(data) < 1 {
+		return errUnsupported
+	}
+
+	var payload []byte
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return errUnsupported
+		}
+		headerLen := int(data[0]&0x0f) * 4
+		if headerLen < 20 || len(data) < headerLen {
+			return errUnsupported
+		}
+		d.Length = int(binary.BigEndian.Uint16(data[2:4]))
+		if d.Length == 0 {
+			// Segmentation offload leaves the length unset
+			d.Length = len(data)
+		}
+		d.Proto = data[9]
+		d.SrcIP = net.IP(append([]byte(nil), data[12:16]...))
+		d.DstIP = net.IP(append([]byte(nil), data[16:20]...))
+		// Only the first fragment carries the transport header
+		if binary.BigEndian.Uint16(data[6:8])&0x1fff != 0 {
+			return nil
+		}
+		payload = data[headerLen:]
+	case 6:
+		if len(data) < 40 {
+			return errUnsupported
+		}
+		d.Length = int(binary.BigEndian.Uint16(data[4:6])) + 40
+		d.SrcIP = net.IP(append([]byte(nil), data[8:24]...))
+		d.DstIP = net.IP(append([]byte(nil), data[24:40]...))
+		next := data[6]
+		payload = data[40:]
+		// Walk the extension headers to the transport protocol
+	headers:
+		for {
+			switch next {
+			case 0, 43, 60: // hop-by-hop, routing, destination options
+				if len(payload) < 8 || len(payload) < 8+int(payload[1])*8 {
+					return errUnsupported
+				}
+				next = payload[0]
+				payload = payload[8+int(payload[1])*8:]
+			case 44: // fragment
+				if len(payload) < 8 {
+					return errUnsupported
+				}
+				next = payload[0]
+				if binary.BigEndian.Uint16(payload[2:4])&0xfff8 != 0 {
+					d.Proto = next
+					return nil
+				}
+				payload = payload[8:]
+			default:
+				break headers
+			}
+		}
+		d.Proto = next
+	default:
+		return errUnsupported
+	}
+
+	switch d.Proto {
+	case ProtoTCP:
+		if len(payload) >= 14 {
+			d.SrcPort = binary.BigEndian.Uint16(payload[0:2])
+			d.DstPort = binary.BigEndian.Uint16(payload[2:4])
+			d.TCPFlags = payload[13]
+		}
+	case ProtoUDP:
+		if len(payload) >= 4 {
+			d.SrcPort = binary.BigEndian.Uint16(payload[0:2])
+			d.DstPort = binary.BigEndian.Uint16(payload[2:4])
+		}
+	case ProtoICMP, ProtoICMPv6:
+		// Report type and code in the port fields, as NetFlow does
+		if len(payload) >= 2 {
+			d.DstPort = uint16(payload[0])<<8 | uint16(payload[1])
+		}
+	}
+	return nil
+}
+
+// ProtoName returns the conventional name of an IP protocol number
+func ProtoName(proto uint8) string {
+	switch proto {
+	case ProtoICMP:
+		return "icmp"
+	case ProtoTCP:
+		return "tcp"
+	case ProtoUDP:
+		return "udp"
+	case ProtoICMPv6:
+		return "icmpv6"
+	case 47:
+		return "gre"
+	case 50:
+		return "esp"
+	case 132:
+		return "sctp"
+	}
+	return "ip-" + strconv.Itoa(int(proto))
+}
+
+// FlagString formats TCP flags as e.g. "SYN,ACK"
+func FlagString(flags uint8) string {
+	var names []string
+	for _, f := range []struct {
+		bit  uint8
+		name string
+	}{{TCPSyn, "SYN"}, {TCPAck, "ACK"}, {TCPPsh, "PSH"}, {TCPFin, "FIN"}, {TCPRst, "RST"}, {TCPUrg, "URG"}} {
+		if flags&f.bit != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,352 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ipv4 builds an IPv4 header of total length 20+len(payload) around payload
+func ipv4(proto uint8, src, dst string, payload []byte) []byte {
+	b := make([]byte, 20, 20+len(payload))
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:4], uint16(20+len(payload)))
+	b[8] = 64
+	b[9] = proto
+	copy(b[12:16], net.ParseIP(src).To4())
+	copy(b[16:20], net.ParseIP(dst).To4())
+	return append(b, payload...)
+}
+
+// tcp builds a 20-byte TCP header with the given ports and flags
+func tcp(src, dst uint16, flags uint8) []byte {
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint16(b[0:2], src)
+	binary.BigEndian.PutUint16(b[2:4], dst)
+	b[12] = 5 << 4
+	b[13] = flags
+	return b
+}
+
+// ethernet wraps payload in an Ethernet II header, with optional VLAN tags
+func ethernet(etherType uint16, payload []byte, vlans ...uint16) []byte {
+	b := []byte{0x02, 0, 0, 0, 0, 0x02, 0x02, 0, 0, 0, 0, 0x01}
+	for _, vlan := range vlans {
+		b = binary.BigEndian.AppendUint16(b, etherTypeVLAN)
+		b = binary.BigEndian.AppendUint16(b, vlan)
+	}
+	b = binary.BigEndian.AppendUint16(b, etherType)
+	return append(b, payload...)
+}
+
+func TestDecode(t *testing.T) {
+	synAck := ipv4(ProtoTCP, "192.0.2.1", "198.51.100.2", tcp(443, 51000, TCPSyn|TCPAck))
+
+	udp6 := make([]byte, 40)
+	udp6[0] = 6 << 4
+	binary.BigEndian.PutUint16(udp6[4:6], 16)
+	udp6[6] = 0 // hop-by-hop options first
+	copy(udp6[8:24], net.ParseIP("2001:db8::1"))
+	copy(udp6[24:40], net.ParseIP("2001:db8::2"))
+	udp6 = append(udp6, ProtoUDP, 0, 0, 0, 0, 0, 0, 0) // 8-byte hop-by-hop header
+	udp6 = binary.BigEndian.AppendUint16(udp6, 5353)
+	udp6 = binary.BigEndian.AppendUint16(udp6, 53)
+	udp6 = append(udp6, 0, 8, 0, 0)
+
+	fragment := ipv4(ProtoUDP, "192.0.2.1", "192.0.2.2", make([]byte, 8))
+	binary.BigEndian.PutUint16(fragment[6:8], 185) // offset 1480, no transport header
+
+	echo := ipv4(ProtoICMP, "192.0.2.1", "192.0.2.2", []byte{8, 0, 0, 0})
+
+	tests := []struct {
+		name     string
+		linkType int
+		data     []byte
+		want     Decoded
+		wantErr  bool
+	}{
+		{
+			name:     "ethernet TCP",
+			linkType: LinkTypeEthernet,
+			data:     ethernet(etherTypeIPv4, synAck),
+			want: Decoded{SrcMAC: "02:00:00:00:00:01", DstMAC: "02:00:00:00:00:02",
+				SrcIP: net.ParseIP("192.0.2.1"), DstIP: net.ParseIP("198.51.100.2"),
+				Proto: ProtoTCP, SrcPort: 443, DstPort: 51000, TCPFlags: TCPSyn | TCPAck, Length: 40},
+		},
+		{
+			name:     "double VLAN tag",
+			linkType: LinkTypeEthernet,
+			data:     ethernet(etherTypeIPv4, synAck, 100, 200),
+			want: Decoded{SrcMAC: "02:00:00:00:00:01", DstMAC: "02:00:00:00:00:02",
+				SrcIP: net.ParseIP("192.0.2.1"), DstIP: net.ParseIP("198.51.100.2"),
+				Proto: ProtoTCP, SrcPort: 443, DstPort: 51000, TCPFlags: TCPSyn | TCPAck, Length: 40},
+		},
+		{
+			name:     "IPv6 UDP after hop-by-hop",
+			linkType: LinkTypeRaw,
+			data:     udp6,
+			want: Decoded{SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2001:db8::2"),
+				Proto: ProtoUDP, SrcPort: 5353, DstPort: 53, Length: 56},
+		},
+		{
+			name:     "Linux cooked capture",
+			linkType: LinkTypeLinuxSLL,
+			data:     append(make([]byte, 16), echo...),
+			want: Decoded{SrcIP: net.ParseIP("192.0.2.1"), DstIP: net.ParseIP("192.0.2.2"),
+				Proto: ProtoICMP, DstPort: 8 << 8, Length: 24},
+		},
+		{
+			name:     "BSD loopback",
+			linkType: LinkTypeNull,
+			data:     append([]byte{2, 0, 0, 0}, echo...),
+			want: Decoded{SrcIP: net.ParseIP("192.0.2.1"), DstIP: net.ParseIP("192.0.2.2"),
+				Proto: ProtoICMP, DstPort: 8 << 8, Length: 24},
+		},
+		{
+			name:     "later fragment",
+			linkType: LinkTypeIPv4,
+			data:     fragment,
+			want: Decoded{SrcIP: net.ParseIP("192.0.2.1"), DstIP: net.ParseIP("192.0.2.2"),
+				Proto: ProtoUDP, Length: 28},
+		},
+		{name: "ARP", linkType: LinkTypeEthernet, data: ethernet(0x0806, make([]byte, 28)), wantErr: true},
+		{name: "short IPv4", linkType: LinkTypeRaw, data: synAck[:12], wantErr: true},
+		{name: "unknown link type", linkType: 147, data: synAck, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.linkType, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.SrcIP.Equal(tt.want.SrcIP) || !got.DstIP.Equal(tt.want.DstIP) {
+				t.Errorf("addresses %s -> %s, want %s -> %s", got.SrcIP, got.DstIP, tt.want.SrcIP, tt.want.DstIP)
+			}
+			got.SrcIP, got.DstIP, tt.want.SrcIP, tt.want.DstIP = nil, nil, nil, nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// byteOrder both reads and appends integers
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// pcapFile builds a classic pcap capture holding the given frames
+func pcapFile(order byteOrder, magic uint32, linkType uint32, ts time.Time, frames ...[]byte) []byte {
+	b := order.AppendUint32(nil, magic)
+	b = order.AppendUint16(b, 2)
+	b = order.AppendUint16(b, 4)
+	b = append(b, make([]byte, 8)...) // thiszone, sigfigs
+	b = order.AppendUint32(b, 65535)
+	b = order.AppendUint32(b, linkType)
+	for _, frame := range frames {
+		frac := uint32(ts.Nanosecond() / 1000)
+		if magic == magicNanos {
+			frac = uint32(ts.Nanosecond())
+		}
+		b = order.AppendUint32(b, uint32(ts.Unix()))
+		b = order.AppendUint32(b, frac)
+		b = order.AppendUint32(b, uint32(len(frame)))
+		b = order.AppendUint32(b, uint32(len(frame))+100)
+		b = append(b, frame...)
+	}
+	return b
+}
+
+// block frames a pcapng block body, padding it to 32 bits
+func block(order byteOrder, blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	length := uint32(12 + len(body))
+	b := order.AppendUint32(nil, blockType)
+	b = order.AppendUint32(b, length)
+	b = append(b, body...)
+	return order.AppendUint32(b, length)
+}
+
+func sectionHeader(order byteOrder) []byte {
+	body := order.AppendUint32(nil, pcapngByteOrder)
+	body = order.AppendUint16(body, 1)
+	body = order.AppendUint16(body, 0)
+	body = append(body, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff) // unknown section length
+	// The block type reads the same in both byte orders
+	b := binary.LittleEndian.AppendUint32(nil, pcapngSHB)
+	length := uint32(12 + len(body))
+	b = order.AppendUint32(b, length)
+	b = append(b, body...)
+	return order.AppendUint32(b, length)
+}
+
+// interfaceBlock describes an interface, with a timestamp resolution
+// option when tsresol is non-zero
+func interfaceBlock(order byteOrder, linkType uint16, tsresol uint8) []byte {
+	body := order.AppendUint16(nil, linkType)
+	body = order.AppendUint16(body, 0)
+	body = order.AppendUint32(body, 65535)
+	if tsresol != 0 {
+		body = order.AppendUint16(body, optIfTsresol)
+		body = order.AppendUint16(body, 1)
+		body = append(body, tsresol, 0, 0, 0)
+	}
+	body = order.AppendUint16(body, optEndOfOptions)
+	body = order.AppendUint16(body, 0)
+	return block(order, pcapngIDB, body)
+}
+
+func enhancedPacket(order byteOrder, iface uint32, ts uint64, frame []byte) []byte {
+	body := order.AppendUint32(nil, iface)
+	body = order.AppendUint32(body, uint32(ts>>32))
+	body = order.AppendUint32(body, uint32(ts))
+	body = order.AppendUint32(body, uint32(len(frame)))
+	body = order.AppendUint32(body, uint32(len(frame)))
+	return block(order, pcapngEPB, append(body, frame...))
+}
+
+func readAll(t *testing.T, data []byte) []Packet {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var packets []Packet
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatalf("Next after %d packets: %v", len(packets), err)
+		}
+		packets = append(packets, p)
+	}
+}
+
+func TestReadPcap(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	frame := ethernet(etherTypeIPv4, ipv4(ProtoTCP, "192.0.2.1", "192.0.2.2", tcp(1, 2, TCPSyn)))
+
+	tests := []struct {
+		name   string
+		data   []byte
+		wantTS time.Time
+	}{
+		{"little-endian microseconds", pcapFile(binary.LittleEndian, magicMicros, LinkTypeEthernet, ts, frame, frame), ts.Truncate(time.Microsecond)},
+		{"big-endian nanoseconds", pcapFile(binary.BigEndian, magicNanos, LinkTypeEthernet, ts, frame, frame), ts},
+		// FCS bits in the upper link type field are ignored
+		{"link type with FCS bits", pcapFile(binary.LittleEndian, magicMicros, 0x10000000|LinkTypeEthernet, ts, frame, frame), ts.Truncate(time.Microsecond)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packets := readAll(t, tt.data)
+			if len(packets) != 2 {
+				t.Fatalf("read %d packets, want 2", len(packets))
+			}
+			p := packets[0]
+			if p.LinkType != LinkTypeEthernet || p.Length != len(frame)+100 || !bytes.Equal(p.Data, frame) {
+				t.Errorf("packet link type %d, length %d, %d bytes", p.LinkType, p.Length, len(p.Data))
+			}
+			if !p.Timestamp.Equal(tt.wantTS) {
+				t.Errorf("timestamp %v, want %v", p.Timestamp, tt.wantTS)
+			}
+		})
+	}
+}
+
+func TestReadPcapng(t *testing.T) {
+	frame := ethernet(etherTypeIPv4, ipv4(ProtoUDP, "192.0.2.1", "192.0.2.2", make([]byte, 8)))
+	raw := ipv4(ProtoICMP, "192.0.2.1", "192.0.2.2", []byte{0, 0, 0, 0})
+	le, be := binary.LittleEndian, binary.BigEndian
+
+	var data []byte
+	data = append(data, sectionHeader(le)...)
+	data = append(data, interfaceBlock(le, LinkTypeEthernet, 0)...) // microseconds
+	data = append(data, interfaceBlock(le, LinkTypeRaw, 9)...)      // nanoseconds
+	data = append(data, block(le, 5, make([]byte, 8))...)           // interface statistics, skipped
+	data = append(data, enhancedPacket(le, 0, 1700000000_000001, frame)...)
+	data = append(data, enhancedPacket(le, 1, 1700000000_000000002, raw)...)
+	spb := le.AppendUint32(nil, uint32(len(frame)))
+	data = append(data, block(le, pcapngSPB, append(spb, frame...))...)
+	// A second section restarts interface numbering in another byte order
+	data = append(data, sectionHeader(be)...)
+	data = append(data, interfaceBlock(be, LinkTypeRaw, 0x80|10)...) // 2^-10 s
+	data = append(data, enhancedPacket(be, 0, 1024*5, raw)...)
+
+	packets := readAll(t, data)
+	want := []struct {
+		linkType int
+		ts       time.Time
+		data     []byte
+	}{
+		{LinkTypeEthernet, time.Unix(1700000000, 1000), frame},
+		{LinkTypeRaw, time.Unix(1700000000, 2), raw},
+		// Untimed, so it takes the time of the packet before
+		{LinkTypeEthernet, time.Unix(1700000000, 2), frame},
+		{LinkTypeRaw, time.Unix(5, 0), raw},
+	}
+	if len(packets) != len(want) {
+		t.Fatalf("read %d packets, want %d", len(packets), len(want))
+	}
+	for i, w := range want {
+		p := packets[i]
+		if p.LinkType != w.linkType || !bytes.Equal(p.Data, w.data) || p.Length != len(w.data) {
+			t.Errorf("packet %d: link type %d, length %d, %d bytes", i, p.LinkType, p.Length, len(p.Data))
+		}
+		if !p.Timestamp.Equal(w.ts) {
+			t.Errorf("packet %d: timestamp %v, want %v", i, p.Timestamp, w.ts)
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	frame := ethernet(etherTypeIPv4, ipv4(ProtoTCP, "192.0.2.1", "192.0.2.2", tcp(1, 2, TCPSyn)))
+	good := pcapFile(binary.LittleEndian, magicMicros, LinkTypeEthernet, time.Unix(0, 0), frame)
+	le := binary.LittleEndian
+
+	huge := append([]byte(nil), good...)
+	le.PutUint32(huge[24+8:], maxPacketSize+1)
+
+	ng := append(sectionHeader(le), interfaceBlock(le, LinkTypeEthernet, 0)...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"not a capture", []byte("GET / HTTP/1.1\r\n\r\n......"), "not a pcap"},
+		{"truncated packet", good[:len(good)-5], "truncated packet"},
+		{"truncated packet header", good[:24+10], "truncated packet header"},
+		{"oversized packet", huge, "exceeds the maximum"},
+		{"unknown interface", slices.Concat(ng, enhancedPacket(le, 3, 0, frame)), "unknown interface"},
+		{"bad block length", slices.Concat(ng, le.AppendUint32(le.AppendUint32(nil, pcapngEPB), 13)), "invalid pcapng block length"},
+		{"truncated block", slices.Concat(ng, enhancedPacket(le, 0, 0, frame)[:40]), "truncated pcapng block"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.data))
+			if err == nil {
+				for err == nil {
+					_, err = r.Next()
+				}
+			}
+			if err == io.EOF || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
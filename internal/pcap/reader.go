@@ -0,0 +1,275 @@
+// Package pcap reads classic pcap and pcapng capture files and decodes the
+// link, network and transport headers of the packets in them.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Link types (LINKTYPE_* values) understood by Decode
+const (
+	LinkTypeNull     = 0   // BSD loopback, host byte order family
+	LinkTypeEthernet = 1   // IEEE 802.3 Ethernet
+	LinkTypeRaw      = 101 // raw IPv4 or IPv6
+	LinkTypeLinuxSLL = 113 // Linux "any" device cooked capture
+	LinkTypeIPv4     = 228
+	LinkTypeIPv6     = 229
+	LinkTypeSLL2     = 276 // Linux cooked capture v2
+)
+
+const (
+	magicMicros      = 0xa1b2c3d4
+	magicNanos       = 0xa1b23c4d
+	pcapngSHB        = 0x0a0d0d0a
+	pcapngByteOrder  = 0x1a2b3c4d
+	pcapngIDB        = 0x00000001
+	pcapngSPB        = 0x00000003
+	pcapngEPB        = 0x00000006
+	maxPacketSize    = 256 * 1024
+	maxPcapngBlock   = 16 * 1024 * 1024
+	optEndOfOptions  = 0
+	optIfTsresol     = 9
+	defaultTsresolNs = 1000 // pcapng default resolution is microseconds
+)
+
+// Packet is one captured frame
+type Packet struct {
+	Timestamp time.Time
+	LinkType  int
+	Length    int // length on the wire, which may exceed len(Data)
+	Data      []byte
+}
+
+// Reader reads packets from a pcap or pcapng stream
+type Reader struct {
+	r     *bufio.Reader
+	ng    bool
+	order binary.ByteOrder
+
+	// classic pcap
+	linkType int
+	nanos    bool
+
+	// pcapng: one entry per interface description block in the section
+	interfaces []ngInterface
+	lastTS     time.Time // of the last enhanced packet, for simple packets
+}
+
+type ngInterface struct {
+	linkType int
+	unitNs   float64 // nanoseconds per timestamp unit
+}
+
+// NewReader detects the capture format from the file header
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("read capture header: %w", err)
+	}
+
+	reader := &Reader{r: br}
+	if binary.LittleEndian.Uint32(magic) == pcapngSHB {
+		reader.ng = true
+		return reader, nil
+	}
+
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("read pcap header: %w", err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(header) == magicMicros:
+		reader.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == magicMicros:
+		reader.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header) == magicNanos:
+		reader.order, reader.nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(header) == magicNanos:
+		reader.order, reader.nanos = binary.BigEndian, true
+	default:
+		return nil, errors.New("not a pcap or pcapng file")
+	}
+	// The upper bits of the link type field carry FCS information
+	reader.linkType = int(reader.order.Uint32(header[20:24]) & 0x0fffffff)
+	return reader, nil
+}
+
+// Next returns the next packet, or io.EOF at the end of the capture
+func (r *Reader) Next() (Packet, error) {
+	if r.ng {
+		return r.nextBlock()
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Packet{}, fmt.Errorf("truncated packet header: %w", err)
+		}
+		return Packet{}, err
+	}
+	sec := r.order.Uint32(header[0:4])
+	frac := r.order.Uint32(header[4:8])
+	capLen := r.order.Uint32(header[8:12])
+	origLen := r.order.Uint32(header[12:16])
+	if capLen > maxPacketSize {
+		return Packet{}, fmt.Errorf("packet of %d bytes exceeds the maximum capture size", capLen)
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Packet{}, fmt.Errorf("truncated packet: %w", err)
+	}
+
+	nsec := int64(frac) * 1000
+	if r.nanos {
+		nsec = int64(frac)
+	}
+	return Packet{
+		Timestamp: time.Unix(int64(sec), nsec),
+		LinkType:  r.linkType,
+		Length:    int(origLen),
+		Data:      data,
+	}, nil
+}
+
+// nextBlock reads pcapng blocks until one holds a packet
+func (r *Reader) nextBlock() (Packet, error) {
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(r.r, header); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return Packet{}, fmt.Errorf("truncated block header: %w", err)
+			}
+			return Packet{}, err
+		}
+
+		// A section header sets the byte order for everything after it
+		if binary.LittleEndian.Uint32(header[0:4]) == pcapngSHB {
+			bom := make([]byte, 4)
+			if _, err := io.ReadFull(r.r, bom); err != nil {
+				return Packet{}, fmt.Errorf("truncated section header: %w", err)
+			}
+			switch {
+			case binary.LittleEndian.Uint32(bom) == pcapngByteOrder:
+				r.order = binary.LittleEndian
+			case binary.BigEndian.Uint32(bom) == pcapngByteOrder:
+				r.order = binary.BigEndian
+			default:
+				return Packet{}, errors.New("invalid pcapng byte order magic")
+			}
+			r.interfaces = nil
+			length := r.order.Uint32(header[4:8])
+			if length < 28 || length > maxPcapngBlock {
+				return Packet{}, fmt.Errorf("invalid section header length %d", length)
+			}
+			if _, err := r.r.Discard(int(length) - 12); err != nil {
+				return Packet{}, fmt.Errorf("truncated section header: %w", err)
+			}
+			continue
+		}
+
+		if r.order == nil {
+			return Packet{}, errors.New("pcapng block before section header")
+		}
+		blockType := r.order.Uint32(header[0:4])
+		length := r.order.Uint32(header[4:8])
+		if length < 12 || length%4 != 0 || length > maxPcapngBlock {
+			return Packet{}, fmt.Errorf("invalid pcapng block length %d", length)
+		}
+		body := make([]byte, length-12)
+		if _, err := io.ReadFull(r.r, body); err != nil {
+			return Packet{}, fmt.Errorf("truncated pcapng block: %w", err)
+		}
+		if _, err := r.r.Discard(4); err != nil { // trailing length
+			return Packet{}, fmt.Errorf("truncated pcapng block: %w", err)
+		}
+
+		switch blockType {
+		case pcapngIDB:
+			if len(body) < 8 {
+				return Packet{}, errors.New("short interface description block")
+			}
+			iface := ngInterface{linkType: int(r.order.Uint16(body[0:2])), unitNs: defaultTsresolNs}
+			r.parseIDBOptions(body[8:], &iface)
+			r.interfaces = append(r.interfaces, iface)
+		case pcapngEPB:
+			if len(body) < 20 {
+				return Packet{}, errors.New("short enhanced packet block")
+			}
+			id := int(r.order.Uint32(body[0:4]))
+			if id >= len(r.interfaces) {
+				return Packet{}, fmt.Errorf("packet for unknown interface %d", id)
+			}
+			iface := r.interfaces[id]
+			ts := uint64(r.order.Uint32(body[4:8]))<<32 | uint64(r.order.Uint32(body[8:12]))
+			capLen := int(r.order.Uint32(body[12:16]))
+			origLen := int(r.order.Uint32(body[16:20]))
+			if capLen > len(body)-20 {
+				return Packet{}, errors.New("enhanced packet block data exceeds block")
+			}
+			r.lastTS = unitsToTime(ts, iface.unitNs)
+			return Packet{
+				Timestamp: r.lastTS,
+				LinkType:  iface.linkType,
+				Length:    origLen,
+				Data:      body[20 : 20+capLen],
+			}, nil
+		case pcapngSPB:
+			if len(body) < 4 || len(r.interfaces) == 0 {
+				return Packet{}, errors.New("invalid simple packet block")
+			}
+			origLen := int(r.order.Uint32(body[0:4]))
+			data := body[4:]
+			if origLen < len(data) {
+				data = data[:origLen]
+			}
+			// Simple packets carry no timestamp; they are taken to follow
+			// the last timed packet closely
+			return Packet{Timestamp: r.lastTS, LinkType: r.interfaces[0].linkType, Length: origLen, Data: data}, nil
+		}
+	}
+}
+
+// parseIDBOptions reads the timestamp resolution of an interface
+func (r *Reader) parseIDBOptions(options []byte, iface *ngInterface) {
+	for len(options) >= 4 {
+		code := r.order.Uint16(options[0:2])
+		length := int(r.order.Uint16(options[2:4]))
+		if code == optEndOfOptions || 4+length > len(options) {
+			return
+		}
+		if code == optIfTsresol && length >= 1 {
+			// High bit set: negative power of two, otherwise of ten
+			res := options[4]
+			unit := 1e9
+			if res&0x80 != 0 {
+				for i := 0; i < int(res&0x7f); i++ {
+					unit /= 2
+				}
+			} else {
+				for i := 0; i < int(res); i++ {
+					unit /= 10
+				}
+			}
+			iface.unitNs = unit
+		}
+		options = options[4+(length+3)&^3:]
+	}
+}
+
+func unitsToTime(ts uint64, unitNs float64) time.Time {
+	if unitNs == 1 {
+		return time.Unix(0, int64(ts))
+	}
+	if unitNs >= 1 && unitNs == float64(int64(unitNs)) {
+		per := uint64(1e9 / unitNs)
+		return time.Unix(int64(ts/per), int64(float64(ts%per)*unitNs))
+	}
+	return time.Unix(0, int64(float64(ts)*unitNs))
+}
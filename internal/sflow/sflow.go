@@ -0,0 +1,312 @@
+// Package sflow decodes sFlow version 5 datagrams: packet flow samples and
+// interface counter samples, standard (enterprise 0) formats only.
+package sflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Sample formats
+const (
+	formatFlowSample            = 1
+	formatCounterSample         = 2
+	formatExpandedFlowSample    = 3
+	formatExpandedCounterSample = 4
+)
+
+// Flow and counter record formats
+const (
+	recordRawHeader       = 1
+	recordIPv4            = 3
+	recordIPv6            = 4
+	recordGenericCounters = 1
+)
+
+// Header protocols of raw packet header records
+const (
+	HeaderEthernet = 1
+	HeaderIPv4     = 11
+	HeaderIPv6     = 12
+)
+
+var errShort = errors.New("short datagram")
+
+// Datagram is one decoded sFlow datagram
+type Datagram struct {
+	Agent          net.IP
+	SubAgentID     uint32
+	Sequence       uint32
+	Uptime         uint32 // milliseconds
+	FlowSamples    []FlowSample
+	CounterSamples []CounterSample
+}
+
+// FlowSample is one sampled packet. The sampling rate tells how many
+// packets it stands for.
+type FlowSample struct {
+	SourceIndex  uint32
+	SamplingRate uint32
+	Input        uint32
+	Output       uint32
+
+	// Raw packet header, if the agent sent one
+	HeaderProtocol uint32
+	FrameLength    uint32
+	Header         []byte
+
+	// Decoded IP fields, if the agent sent them instead of a header
+	IP *SampledIP
+}
+
+// SampledIP holds the fields of an IPv4 or IPv6 data record
+type SampledIP struct {
+	Length   uint32
+	Proto    uint8
+	SrcIP    net.IP
+	DstIP    net.IP
+	SrcPort  uint16
+	DstPort  uint16
+	TCPFlags uint8
+}
+
+// CounterSample holds the counters of one data source. Interface is nil
+// when the sample carried no generic interface counters.
+type CounterSample struct {
+	SourceIndex uint32
+	Interface   *InterfaceCounters
+}
+
+// InterfaceCounters is the generic interface counters record (RFC 2863
+// ifTable values)
+type InterfaceCounters struct {
+	IfIndex          uint32
+	IfType           uint32
+	IfSpeed          uint64
+	IfDirection      uint32
+	IfStatus         uint32 // bit 0 admin up, bit 1 oper up
+	InOctets         uint64
+	InUcastPkts      uint32
+	InMulticastPkts  uint32
+	InBroadcastPkts  uint32
+	InDiscards       uint32
+	InErrors         uint32
+	InUnknownProtos  uint32
+	OutOctets        uint64
+	OutUcastPkts     uint32
+	OutMulticastPkts uint32
+	OutBroadcastPkts uint32
+	OutDiscards      uint32
+	OutErrors        uint32
+	PromiscuousMode  uint32
+}
+
+// OperUp reports whether the interface is operationally up
+func (c *InterfaceCounters) OperUp() bool {
+	return c.IfStatus&2 != 0
+}
+
+// reader consumes XDR-encoded fields, remembering the first error
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = errShort
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) u64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// opaque reads a length-prefixed field padded to 4 bytes
+func (r *reader) opaque() []byte {
+	n := int(r.u32())
+	b := r.bytes(n)
+	r.bytes((4 - n%4) % 4)
+	return b
+}
+
+func (r *reader) ip() net.IP {
+	switch r.u32() {
+	case 1:
+		return net.IP(append([]byte(nil), r.bytes(4)...))
+	case 2:
+		return net.IP(append([]byte(nil), r.bytes(16)...))
+	default:
+		if r.err == nil {
+			r.err = errors.New("unknown agent address type")
+		}
+		return nil
+	}
+}
+
+// Decode parses an sFlow v5 datagram. Samples and records of formats it
+// does not know are skipped.
+func Decode(data []byte) (*Datagram, error) {
+	r := &reader{buf: data}
+	if version := r.u32(); r.err == nil && version != 5 {
+		return nil, fmt.Errorf("unsupported sFlow version %d", version)
+	}
+
+	d := &Datagram{Agent: r.ip()}
+	d.SubAgentID = r.u32()
+	d.Sequence = r.u32()
+	d.Uptime = r.u32()
+	count := r.u32()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for i := uint32(0); i < count; i++ {
+		format := r.u32()
+		body := r.opaque()
+		if r.err != nil {
+			return d, r.err
+		}
+		// Only standard sFlow formats are decoded
+		if format>>12 != 0 {
+			continue
+		}
+
+		sample := &reader{buf: body}
+		switch format & 0xfff {
+		case formatFlowSample, formatExpandedFlowSample:
+			fs := decodeFlowSample(sample, format&0xfff == formatExpandedFlowSample)
+			if sample.err != nil {
+				return d, fmt.Errorf("flow sample: %w", sample.err)
+			}
+			d.FlowSamples = append(d.FlowSamples, fs)
+		case formatCounterSample, formatExpandedCounterSample:
+			cs := decodeCounterSample(sample, format&0xfff == formatExpandedCounterSample)
+			if sample.err != nil {
+				return d, fmt.Errorf("counter sample: %w", sample.err)
+			}
+			d.CounterSamples = append(d.CounterSamples, cs)
+		}
+	}
+	return d, nil
+}
+
+func decodeFlowSample(r *reader, expanded bool) FlowSample {
+	fs := FlowSample{}
+	r.u32() // sequence number
+	if expanded {
+		r.u32() // source id type
+		fs.SourceIndex = r.u32()
+	} else {
+		fs.SourceIndex = r.u32() & 0xffffff
+	}
+	fs.SamplingRate = r.u32()
+	r.u32() // sample pool
+	r.u32() // drops
+	if expanded {
+		r.u32() // input format
+		fs.Input = r.u32()
+		r.u32() // output format
+		fs.Output = r.u32()
+	} else {
+		fs.Input = r.u32() & 0x3fffffff
+		fs.Output = r.u32() & 0x3fffffff
+	}
+
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		format := r.u32()
+		record := &reader{buf: r.opaque()}
+		if r.err != nil || format>>12 != 0 {
+			continue
+		}
+
+		switch format & 0xfff {
+		case recordRawHeader:
+			fs.HeaderProtocol = record.u32()
+			fs.FrameLength = record.u32()
+			record.u32() // bytes stripped
+			fs.Header = append([]byte(nil), record.opaque()...)
+		case recordIPv4, recordIPv6:
+			addrLen := 4
+			if format&0xfff == recordIPv6 {
+				addrLen = 16
+			}
+			ip := &SampledIP{Length: record.u32(), Proto: uint8(record.u32())}
+			ip.SrcIP = net.IP(append([]byte(nil), record.bytes(addrLen)...))
+			ip.DstIP = net.IP(append([]byte(nil), record.bytes(addrLen)...))
+			ip.SrcPort = uint16(record.u32())
+			ip.DstPort = uint16(record.u32())
+			ip.TCPFlags = uint8(record.u32())
+			if record.err == nil {
+				fs.IP = ip
+			}
+		}
+	}
+	return fs
+}
+
+func decodeCounterSample(r *reader, expanded bool) CounterSample {
+	cs := CounterSample{}
+	r.u32() // sequence number
+	if expanded {
+		r.u32() // source id type
+		cs.SourceIndex = r.u32()
+	} else {
+		cs.SourceIndex = r.u32() & 0xffffff
+	}
+
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		format := r.u32()
+		record := &reader{buf: r.opaque()}
+		if r.err != nil || format != recordGenericCounters {
+			continue
+		}
+
+		c := &InterfaceCounters{
+			IfIndex:          record.u32(),
+			IfType:           record.u32(),
+			IfSpeed:          record.u64(),
+			IfDirection:      record.u32(),
+			IfStatus:         record.u32(),
+			InOctets:         record.u64(),
+			InUcastPkts:      record.u32(),
+			InMulticastPkts:  record.u32(),
+			InBroadcastPkts:  record.u32(),
+			InDiscards:       record.u32(),
+			InErrors:         record.u32(),
+			InUnknownProtos:  record.u32(),
+			OutOctets:        record.u64(),
+			OutUcastPkts:     record.u32(),
+			OutMulticastPkts: record.u32(),
+			OutBroadcastPkts: record.u32(),
+			OutDiscards:      record.u32(),
+			OutErrors:        record.u32(),
+			PromiscuousMode:  record.u32(),
+		}
+		if record.err == nil {
+			cs.Interface = c
+		}
+	}
+	return cs
+}
@@ -0,0 +1,201 @@
+package sflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// be appends big-endian integers of the given widths to b
+func be(b []byte, values ...any) []byte {
+	for _, v := range values {
+		switch v := v.(type) {
+		case uint32:
+			b = binary.BigEndian.AppendUint32(b, v)
+		case uint64:
+			b = binary.BigEndian.AppendUint64(b, v)
+		case []byte:
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+// opaque wraps a body in an XDR length prefix and pads it to 4 bytes
+func opaque(body []byte) []byte {
+	b := be(nil, uint32(len(body)), body)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// record prefixes a sample or record body with its format
+func record(format uint32, body []byte) []byte {
+	return be(nil, format, opaque(body))
+}
+
+func datagram(samples ...[]byte) []byte {
+	d := be(nil, uint32(5), uint32(1), []byte{192, 0, 2, 10}, uint32(0), uint32(42), uint32(123456), uint32(len(samples)))
+	for _, s := range samples {
+		d = append(d, s...)
+	}
+	return d
+}
+
+// ipv4Record is a sampled IPv4 data record for a TCP packet
+func ipv4Record() []byte {
+	return record(recordIPv4, be(nil, uint32(1500), uint32(6),
+		[]byte{10, 0, 0, 5}, []byte{192, 0, 2, 1}, uint32(50000), uint32(443), uint32(0x18), uint32(0)))
+}
+
+// headerRecord is a raw packet header record holding the first 3 bytes of
+// a 64 byte Ethernet frame
+func headerRecord() []byte {
+	return record(recordRawHeader, be(nil, uint32(HeaderEthernet), uint32(64), uint32(4), opaque([]byte{1, 2, 3})))
+}
+
+func flowSample(records ...[]byte) []byte {
+	body := be(nil, uint32(7), uint32(3), uint32(1000), uint32(500000), uint32(0),
+		uint32(1), uint32(2), uint32(len(records)))
+	for _, r := range records {
+		body = append(body, r...)
+	}
+	return record(formatFlowSample, body)
+}
+
+func expandedFlowSample(records ...[]byte) []byte {
+	body := be(nil, uint32(7), uint32(0), uint32(70000), uint32(256), uint32(0), uint32(0),
+		uint32(0), uint32(70001), uint32(0), uint32(70002), uint32(len(records)))
+	for _, r := range records {
+		body = append(body, r...)
+	}
+	return record(formatExpandedFlowSample, body)
+}
+
+func genericCounters() []byte {
+	return record(recordGenericCounters, be(nil,
+		uint32(3), uint32(6), uint64(1e9), uint32(1), uint32(3),
+		uint64(1<<40), uint32(100), uint32(10), uint32(1), uint32(2), uint32(3), uint32(0),
+		uint64(1<<33), uint32(200), uint32(20), uint32(2), uint32(4), uint32(5), uint32(0)))
+}
+
+func counterSample(records ...[]byte) []byte {
+	body := be(nil, uint32(9), uint32(3), uint32(len(records)))
+	for _, r := range records {
+		body = append(body, r...)
+	}
+	return record(formatCounterSample, body)
+}
+
+var wantCounters = &InterfaceCounters{
+	IfIndex: 3, IfType: 6, IfSpeed: 1e9, IfDirection: 1, IfStatus: 3,
+	InOctets: 1 << 40, InUcastPkts: 100, InMulticastPkts: 10, InBroadcastPkts: 1, InDiscards: 2, InErrors: 3,
+	OutOctets: 1 << 33, OutUcastPkts: 200, OutMulticastPkts: 20, OutBroadcastPkts: 2, OutDiscards: 4, OutErrors: 5,
+}
+
+var wantIP = &SampledIP{
+	Length: 1500, Proto: 6, SrcIP: net.IP{10, 0, 0, 5}, DstIP: net.IP{192, 0, 2, 1},
+	SrcPort: 50000, DstPort: 443, TCPFlags: 0x18,
+}
+
+func TestDecode(t *testing.T) {
+	d, err := Decode(datagram(
+		flowSample(headerRecord(), ipv4Record()),
+		// Records and samples of other enterprises are skipped
+		flowSample(record(1<<12|recordIPv4, []byte{1, 2, 3, 4})),
+		record(9<<12|formatFlowSample, []byte{0, 0, 0, 0}),
+		expandedFlowSample(ipv4Record()),
+		counterSample(record(2, []byte{0, 0, 0, 0}), genericCounters()),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Agent.Equal(net.IP{192, 0, 2, 10}) || d.Sequence != 42 || d.Uptime != 123456 {
+		t.Errorf("header = agent %v, sequence %d, uptime %d", d.Agent, d.Sequence, d.Uptime)
+	}
+
+	want := []FlowSample{
+		{SourceIndex: 3, SamplingRate: 1000, Input: 1, Output: 2,
+			HeaderProtocol: HeaderEthernet, FrameLength: 64, Header: []byte{1, 2, 3}, IP: wantIP},
+		{SourceIndex: 3, SamplingRate: 1000, Input: 1, Output: 2},
+		{SourceIndex: 70000, SamplingRate: 256, Input: 70001, Output: 70002, IP: wantIP},
+	}
+	if !reflect.DeepEqual(d.FlowSamples, want) {
+		t.Errorf("flow samples = %+v\nwant %+v", d.FlowSamples, want)
+	}
+
+	if len(d.CounterSamples) != 1 || d.CounterSamples[0].SourceIndex != 3 {
+		t.Fatalf("counter samples = %+v", d.CounterSamples)
+	}
+	if got := d.CounterSamples[0].Interface; !reflect.DeepEqual(got, wantCounters) {
+		t.Errorf("counters = %+v\nwant %+v", got, wantCounters)
+	}
+	if !wantCounters.OperUp() {
+		t.Error("OperUp false for status 3")
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	full := datagram(flowSample(ipv4Record()), counterSample(genericCounters()))
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantErr    bool
+		wantIPs    int // flow samples with a decoded IP record
+		wantFlows  int
+		wantIfaces int // counter samples with decoded interface counters
+		wantCounts int
+	}{
+		{name: "header", data: full[:20], wantErr: true},
+		// The counter sample runs past the end; the flow sample before it
+		// is kept
+		{name: "second sample", data: full[:len(full)-8], wantErr: true, wantIPs: 1, wantFlows: 1},
+		// Records cut short inside their sample decode as nothing
+		{name: "short IP record", data: datagram(flowSample(record(recordIPv4, []byte{0, 0, 5, 220}))), wantFlows: 1},
+		{name: "short counters", data: datagram(counterSample(record(recordGenericCounters, make([]byte, 40)))), wantCounts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Decode(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode error = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, errShort) {
+				t.Errorf("error %v is not errShort", err)
+			}
+			if d == nil {
+				d = &Datagram{}
+			}
+			ips, ifaces := 0, 0
+			for _, fs := range d.FlowSamples {
+				if fs.IP != nil {
+					ips++
+				}
+			}
+			for _, cs := range d.CounterSamples {
+				if cs.Interface != nil {
+					ifaces++
+				}
+			}
+			if len(d.FlowSamples) != tt.wantFlows || ips != tt.wantIPs {
+				t.Errorf("%d flow samples, %d with IP records, want %d and %d", len(d.FlowSamples), ips, tt.wantFlows, tt.wantIPs)
+			}
+			if len(d.CounterSamples) != tt.wantCounts || ifaces != tt.wantIfaces {
+				t.Errorf("%d counter samples, %d with counters, want %d and %d", len(d.CounterSamples), ifaces, tt.wantCounts, tt.wantIfaces)
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	if _, err := Decode(be(nil, uint32(4), uint32(1), []byte{192, 0, 2, 10})); err == nil {
+		t.Error("decoded an sFlow v4 datagram")
+	}
+	if _, err := Decode(be(nil, uint32(5), uint32(3), []byte{192, 0, 2, 10})); err == nil {
+		t.Error("decoded an unknown agent address type")
+	}
+}
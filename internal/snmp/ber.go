@@ -0,0 +1,473 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Type is the BER tag of a variable binding value
+type Type byte
+
+const (
+	Integer          Type = 0x02
+	OctetString      Type = 0x04
+	Null             Type = 0x05
+	ObjectIdentifier Type = 0x06
+	IPAddress        Type = 0x40
+	Counter32        Type = 0x41
+	Gauge32          Type = 0x42
+	TimeTicks        Type = 0x43
+	Opaque           Type = 0x44
+	Counter64        Type = 0x46
+	NoSuchObject     Type = 0x80
+	NoSuchInstance   Type = 0x81
+	EndOfMibView     Type = 0x82
+)
+
+const tagSequence = 0x30
+
+var errTruncated = errors.New("truncated BER encoding")
+
+// OID is an object identifier
+type OID []uint32
+
+// ParseOID parses a dotted OID such as "1.3.6.1.2.1.1.3.0"; a leading dot
+// is allowed
+func ParseOID(s string) (OID, error) {
+	s = strings.TrimPrefix(s, ".")
+	if s == "" {
+		return nil, errors.New("empty OID")
+	}
+	parts := strings.Split(s, ".")
+	oid := make(OID, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid[i] = uint32(n)
+	}
+	return oid, nil
+}
+
+// MustParseOID is ParseOID for constant OIDs
+func MustParseOID(s string) OID {
+	oid, err := ParseOID(s)
+	if err != nil {
+		panic(err)
+	}
+	return oid
+}
+
+func (o OID) String() string {
+	parts := make([]string, len(o))
+	for i, n := range o {
+		parts[i] = strconv.FormatUint(uint64(n), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// HasPrefix reports whether o lies in the subtree rooted at prefix
+func (o OID) HasPrefix(prefix OID) bool {
+	if len(o) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if o[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare orders OIDs lexicographically, as agents walk them
+func (o OID) Compare(other OID) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		if o[i] != other[i] {
+			if o[i] < other[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(o) - len(other)
+}
+
+// Variable is a variable binding. Value holds an int64 for Integer, a
+// uint64 for counters, gauges and time ticks, a []byte for octet strings
+// and opaque values, a net.IP for IP addresses, an OID for object
+// identifiers and nil for Null and the exception types.
+type Variable struct {
+	OID   OID
+	Type  Type
+	Value any
+}
+
+// Uint64 returns a numeric value as an unsigned integer; negative and
+// non-numeric values yield 0
+func (v Variable) Uint64() uint64 {
+	switch value := v.Value.(type) {
+	case uint64:
+		return value
+	case int64:
+		if value > 0 {
+			return uint64(value)
+		}
+	}
+	return 0
+}
+
+// Int64 returns a numeric value as a signed integer
+func (v Variable) Int64() int64 {
+	switch value := v.Value.(type) {
+	case int64:
+		return value
+	case uint64:
+		return int64(value)
+	}
+	return 0
+}
+
+// String formats the value for display. Octet strings are returned as
+// text when they are valid UTF-8 without control characters, as hex
+// otherwise.
+func (v Variable) String() string {
+	switch value := v.Value.(type) {
+	case nil:
+		switch v.Type {
+		case NoSuchObject:
+			return "noSuchObject"
+		case NoSuchInstance:
+			return "noSuchInstance"
+		case EndOfMibView:
+			return "endOfMibView"
+		}
+		return ""
+	case []byte:
+		if printable(value) {
+			return string(value)
+		}
+		hex := make([]string, len(value))
+		for i, b := range value {
+			hex[i] = fmt.Sprintf("%02x", b)
+		}
+		return strings.Join(hex, ":")
+	case OID:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// Exception reports whether the variable is a noSuchObject,
+// noSuchInstance or endOfMibView marker rather than a value
+func (v Variable) Exception() bool {
+	return v.Type == NoSuchObject || v.Type == NoSuchInstance || v.Type == EndOfMibView
+}
+
+// Encoding
+
+func appendLength(dst []byte, n int) []byte {
+	if n < 0x80 {
+		return append(dst, byte(n))
+	}
+	var buf [4]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = byte(n)
+		n >>= 8
+	}
+	dst = append(dst, 0x80|byte(len(buf)-i))
+	return append(dst, buf[i:]...)
+}
+
+func appendTLV(dst []byte, tag byte, content []byte) []byte {
+	dst = append(dst, tag)
+	dst = appendLength(dst, len(content))
+	return append(dst, content...)
+}
+
+func appendInt(dst []byte, tag byte, v int64) []byte {
+	// Minimal two's complement: drop leading bytes that only repeat the
+	// sign bit
+	n := 8
+	for n > 1 {
+		top := byte(v >> (8 * (n - 1)))
+		next := byte(v >> (8 * (n - 2)))
+		if (top == 0 && next&0x80 == 0) || (top == 0xff && next&0x80 != 0) {
+			n--
+			continue
+		}
+		break
+	}
+	content := make([]byte, n)
+	for i := 0; i < n; i++ {
+		content[i] = byte(v >> (8 * (n - 1 - i)))
+	}
+	return appendTLV(dst, tag, content)
+}
+
+func appendUint(dst []byte, tag byte, v uint64) []byte {
+	var buf [9]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = byte(v)
+		v >>= 8
+		if v == 0 {
+			break
+		}
+	}
+	// Keep the value positive
+	if buf[i]&0x80 != 0 {
+		i--
+		buf[i] = 0
+	}
+	return appendTLV(dst, tag, buf[i:])
+}
+
+func appendOID(dst []byte, oid OID) []byte {
+	if len(oid) < 2 {
+		padded := make(OID, 2)
+		copy(padded, oid)
+		oid = padded
+	}
+	content := appendSubID(nil, oid[0]*40+oid[1])
+	for _, n := range oid[2:] {
+		content = appendSubID(content, n)
+	}
+	return appendTLV(dst, byte(ObjectIdentifier), content)
+}
+
+func appendSubID(dst []byte, n uint32) []byte {
+	var buf [5]byte
+	i := len(buf) - 1
+	buf[i] = byte(n & 0x7f)
+	for n >>= 7; n > 0; n >>= 7 {
+		i--
+		buf[i] = 0x80 | byte(n&0x7f)
+	}
+	return append(dst, buf[i:]...)
+}
+
+func appendVariable(dst []byte, v Variable) ([]byte, error) {
+	content := appendOID(nil, v.OID)
+	switch v.Type {
+	case Integer:
+		content = appendInt(content, byte(v.Type), v.Int64())
+	case Counter32, Gauge32, TimeTicks, Counter64:
+		content = appendUint(content, byte(v.Type), v.Uint64())
+	case OctetString, Opaque:
+		value, _ := v.Value.([]byte)
+		if s, ok := v.Value.(string); ok {
+			value = []byte(s)
+		}
+		content = appendTLV(content, byte(v.Type), value)
+	case IPAddress:
+		ip, _ := v.Value.(net.IP)
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return nil, fmt.Errorf("%s: IpAddress value must be IPv4", v.OID)
+		}
+		content = appendTLV(content, byte(v.Type), ip4)
+	case ObjectIdentifier:
+		oid, _ := v.Value.(OID)
+		content = appendOID(content, oid)
+	case Null, NoSuchObject, NoSuchInstance, EndOfMibView:
+		content = appendTLV(content, byte(v.Type), nil)
+	default:
+		return nil, fmt.Errorf("%s: cannot encode type 0x%02x", v.OID, byte(v.Type))
+	}
+	return appendTLV(dst, tagSequence, content), nil
+}
+
+// Decoding
+
+// decoder reads BER elements. off is the position of buf within the whole
+// message, needed to locate the authentication parameters.
+type decoder struct {
+	buf []byte
+	off int
+}
+
+func (d *decoder) empty() bool {
+	return len(d.buf) == 0
+}
+
+// next reads one element and returns its tag and content
+func (d *decoder) next() (byte, *decoder, error) {
+	if len(d.buf) < 2 {
+		return 0, nil, errTruncated
+	}
+	tag := d.buf[0]
+	length := int(d.buf[1])
+	header := 2
+	if length&0x80 != 0 {
+		octets := length & 0x7f
+		if octets == 0 || octets > 4 || len(d.buf) < 2+octets {
+			return 0, nil, errors.New("unsupported BER length")
+		}
+		length = 0
+		for _, b := range d.buf[2 : 2+octets] {
+			length = length<<8 | int(b)
+		}
+		header += octets
+	}
+	if length < 0 || len(d.buf)-header < length {
+		return 0, nil, errTruncated
+	}
+	content := &decoder{buf: d.buf[header : header+length], off: d.off + header}
+	d.buf = d.buf[header+length:]
+	d.off += header + length
+	return tag, content, nil
+}
+
+// expect reads one element that must have the given tag
+func (d *decoder) expect(tag byte) (*decoder, error) {
+	got, content, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	if got != tag {
+		return nil, fmt.Errorf("expected BER tag 0x%02x, got 0x%02x", tag, got)
+	}
+	return content, nil
+}
+
+func (d *decoder) readInt() (int64, error) {
+	content, err := d.expect(byte(Integer))
+	if err != nil {
+		return 0, err
+	}
+	return parseInt(content.buf)
+}
+
+func (d *decoder) readOctets() ([]byte, error) {
+	content, err := d.expect(byte(OctetString))
+	if err != nil {
+		return nil, err
+	}
+	return content.buf, nil
+}
+
+func parseInt(b []byte) (int64, error) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, errors.New("invalid BER integer")
+	}
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v, nil
+}
+
+func parseUint(b []byte) (uint64, error) {
+	if len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	if len(b) > 8 {
+		return 0, errors.New("invalid BER unsigned integer")
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func parseOID(b []byte) (OID, error) {
+	if len(b) == 0 {
+		return nil, errors.New("empty OID")
+	}
+	var oid OID
+	var n uint64
+	for i, c := range b {
+		n = n<<7 | uint64(c&0x7f)
+		if n > 0xffffffff {
+			return nil, errors.New("OID sub-identifier out of range")
+		}
+		if c&0x80 != 0 {
+			if i == len(b)-1 {
+				return nil, errTruncated
+			}
+			continue
+		}
+		if oid == nil {
+			// The first sub-identifier packs the first two arcs
+			switch {
+			case n < 40:
+				oid = OID{0, uint32(n)}
+			case n < 80:
+				oid = OID{1, uint32(n - 40)}
+			default:
+				oid = OID{2, uint32(n - 80)}
+			}
+		} else {
+			oid = append(oid, uint32(n))
+		}
+		n = 0
+	}
+	return oid, nil
+}
+
+func (d *decoder) readOID() (OID, error) {
+	content, err := d.expect(byte(ObjectIdentifier))
+	if err != nil {
+		return nil, err
+	}
+	return parseOID(content.buf)
+}
+
+func (d *decoder) readVariable() (Variable, error) {
+	seq, err := d.expect(tagSequence)
+	if err != nil {
+		return Variable{}, err
+	}
+	oid, err := seq.readOID()
+	if err != nil {
+		return Variable{}, err
+	}
+	tag, content, err := seq.next()
+	if err != nil {
+		return Variable{}, err
+	}
+
+	v := Variable{OID: oid, Type: Type(tag)}
+	switch v.Type {
+	case Integer:
+		v.Value, err = parseInt(content.buf)
+	case Counter32, Gauge32, TimeTicks, Counter64:
+		v.Value, err = parseUint(content.buf)
+	case OctetString, Opaque:
+		v.Value = append([]byte(nil), content.buf...)
+	case IPAddress:
+		if len(content.buf) != 4 {
+			return v, errors.New("invalid IpAddress length")
+		}
+		v.Value = net.IP(append([]byte(nil), content.buf...))
+	case ObjectIdentifier:
+		v.Value, err = parseOID(content.buf)
+	case Null, NoSuchObject, NoSuchInstance, EndOfMibView:
+	default:
+		// Unknown application types are kept as raw bytes
+		v.Value = append([]byte(nil), content.buf...)
+	}
+	return v, err
+}
@@ -0,0 +1,294 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// usmStats report OIDs (RFC 3414) and what they mean
+var usmReports = map[string]string{
+	"1.3.6.1.6.3.15.1.1.1.0": "unsupported security level",
+	"1.3.6.1.6.3.15.1.1.2.0": "not in time window",
+	"1.3.6.1.6.3.15.1.1.3.0": "unknown user name",
+	"1.3.6.1.6.3.15.1.1.4.0": "unknown engine ID",
+	"1.3.6.1.6.3.15.1.1.5.0": "wrong digest (check the auth password)",
+	"1.3.6.1.6.3.15.1.1.6.0": "decryption error (check the priv password)",
+}
+
+const reportNotInTimeWindow = "1.3.6.1.6.3.15.1.1.2.0"
+
+// Client polls one agent. Set the exported fields, then call Connect.
+type Client struct {
+	Address        string // host or host:port, port 161 by default
+	Version        Version
+	Community      string // v1 and v2c
+	User           User   // v3
+	Timeout        time.Duration
+	Retries        int
+	MaxRepetitions int // GetBulk size, 10 by default
+
+	conn   net.Conn
+	nextID int32
+
+	// Authoritative engine of a v3 agent
+	engineID    []byte
+	engineBoots int32
+	engineTime  int32
+	syncedAt    time.Time
+	keys        *SecurityKeys
+}
+
+// Connect opens the UDP socket and, for v3, discovers the agent's engine
+// and localizes the user's keys to it
+func (c *Client) Connect() error {
+	address := c.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "161")
+	}
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	if c.Timeout == 0 {
+		c.Timeout = 2 * time.Second
+	}
+	if c.MaxRepetitions == 0 {
+		c.MaxRepetitions = 10
+	}
+
+	if c.Version == Version3 {
+		if err := c.discover(); err != nil {
+			conn.Close()
+			return fmt.Errorf("engine discovery: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// discover learns the engine ID, boots and time from the report an agent
+// sends in reply to an unauthenticated empty request
+func (c *Client) discover() error {
+	c.keys = nil
+	if _, err := c.exchange(PDU{Type: GetRequest}); err != nil {
+		return err
+	}
+	if len(c.engineID) == 0 {
+		return errors.New("agent did not report its engine ID")
+	}
+
+	keys, err := c.User.Localize(c.engineID)
+	if err != nil {
+		return err
+	}
+	c.keys = keys
+	return nil
+}
+
+// Get fetches the given OIDs
+func (c *Client) Get(oids ...OID) ([]Variable, error) {
+	pdu := PDU{Type: GetRequest}
+	for _, oid := range oids {
+		pdu.Variables = append(pdu.Variables, Variable{OID: oid, Type: Null})
+	}
+	response, err := c.request(pdu)
+	if err != nil {
+		return nil, err
+	}
+	return response.Variables, nil
+}
+
+// Walk calls fn for every variable in the subtree under root, in order,
+// using GetBulk (GetNext for v1)
+func (c *Client) Walk(root OID, fn func(Variable) error) error {
+	current := root
+	for {
+		pdu := PDU{Type: GetBulkRequest, MaxRepetitions: c.MaxRepetitions}
+		if c.Version == Version1 {
+			pdu = PDU{Type: GetNextRequest}
+		}
+		pdu.Variables = []Variable{{OID: current, Type: Null}}
+
+		response, err := c.request(pdu)
+		if err != nil {
+			// v1 agents signal the end of the MIB with noSuchName
+			if c.Version == Version1 && response.ErrorStatus == 2 {
+				return nil
+			}
+			return err
+		}
+		if len(response.Variables) == 0 {
+			return nil
+		}
+		for _, v := range response.Variables {
+			if v.Type == EndOfMibView || !v.OID.HasPrefix(root) {
+				return nil
+			}
+			if v.OID.Compare(current) <= 0 {
+				return fmt.Errorf("agent returned %s after %s, out of order", v.OID, current)
+			}
+			if err := fn(v); err != nil {
+				return err
+			}
+			current = v.OID
+		}
+	}
+}
+
+// request sends a PDU and checks the response for errors. The response is
+// returned along with PDU errors so callers can inspect the status.
+func (c *Client) request(pdu PDU) (PDU, error) {
+	response, err := c.exchange(pdu)
+	if err == nil && response.Type == Report && c.Version == Version3 {
+		oid := ""
+		if len(response.Variables) > 0 {
+			oid = response.Variables[0].OID.String()
+		}
+		// The agent rebooted or our clock drifted; the report carried its
+		// current boots and time, so try once more
+		if oid == reportNotInTimeWindow {
+			response, err = c.exchange(pdu)
+		}
+	}
+	if err != nil {
+		return response, err
+	}
+
+	if response.Type == Report {
+		oid := ""
+		if len(response.Variables) > 0 {
+			oid = response.Variables[0].OID.String()
+		}
+		if reason, known := usmReports[oid]; known {
+			return response, errors.New(reason)
+		}
+		return response, fmt.Errorf("agent sent report %s", oid)
+	}
+	if response.ErrorStatus != 0 {
+		culprit := ""
+		if i := response.ErrorIndex - 1; i >= 0 && i < len(pdu.Variables) {
+			culprit = " (" + pdu.Variables[i].OID.String() + ")"
+		}
+		return response, fmt.Errorf("agent returned %s%s", ErrorStatusName(response.ErrorStatus), culprit)
+	}
+	return response, nil
+}
+
+// exchange sends a PDU and waits for the matching response, retrying on
+// timeouts
+func (c *Client) exchange(pdu PDU) (PDU, error) {
+	if c.conn == nil {
+		return PDU{}, errors.New("not connected")
+	}
+
+	buf := make([]byte, 65536)
+	var lastErr error
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		c.nextID++
+		if c.nextID <= 0 {
+			c.nextID = 1
+		}
+		pdu.RequestID = c.nextID
+
+		data, err := c.message(pdu).Marshal(c.keys)
+		if err != nil {
+			return PDU{}, err
+		}
+		if _, err := c.conn.Write(data); err != nil {
+			return PDU{}, err
+		}
+
+		c.conn.SetReadDeadline(time.Now().Add(c.Timeout))
+		for {
+			n, err := c.conn.Read(buf)
+			if err != nil {
+				lastErr = err
+				break
+			}
+			response, ok := c.accept(buf[:n], pdu.RequestID)
+			if ok {
+				return response, nil
+			}
+		}
+
+		var netErr net.Error
+		if !errors.As(lastErr, &netErr) || !netErr.Timeout() {
+			return PDU{}, lastErr
+		}
+	}
+	return PDU{}, fmt.Errorf("no response from %s", c.Address)
+}
+
+// message wraps a PDU for the client's version
+func (c *Client) message(pdu PDU) *Message {
+	if c.Version != Version3 {
+		return &Message{Version: c.Version, Community: c.Community, PDU: pdu}
+	}
+
+	m := &Message{
+		Version:         Version3,
+		MessageID:       pdu.RequestID,
+		Reportable:      true,
+		ContextEngineID: c.engineID,
+		PDU:             pdu,
+		Security:        SecurityParameters{EngineID: c.engineID},
+	}
+	if c.keys != nil {
+		m.Security.UserName = c.User.Name
+		m.Security.EngineBoots = c.engineBoots
+		m.Security.EngineTime = c.engineTime + int32(time.Since(c.syncedAt).Seconds())
+	}
+	return m
+}
+
+// accept decodes a received datagram and reports whether it answers the
+// request with the given ID. Stray and forged datagrams are ignored.
+func (c *Client) accept(data []byte, id int32) (PDU, bool) {
+	m, err := Unmarshal(data)
+	if err != nil || m.Version != c.Version {
+		return PDU{}, false
+	}
+
+	if c.Version != Version3 {
+		if m.PDU.RequestID != id || m.PDU.Type != GetResponse {
+			return PDU{}, false
+		}
+		return m.PDU, true
+	}
+
+	if m.MessageID != id {
+		return PDU{}, false
+	}
+	if err := m.Open(c.keys); err != nil {
+		return PDU{}, false
+	}
+	// Unauthenticated reports are accepted during discovery and for
+	// errors the agent cannot authenticate, such as a wrong password;
+	// responses must carry the user's security level
+	switch m.PDU.Type {
+	case Report:
+	case GetResponse:
+		if c.keys != nil && c.keys.auth.hash != nil && !m.Authenticated() {
+			return PDU{}, false
+		}
+	default:
+		return PDU{}, false
+	}
+
+	if len(m.Security.EngineID) > 0 && (m.PDU.Type == Report || m.Authenticated()) {
+		c.engineID = m.Security.EngineID
+		c.engineBoots = m.Security.EngineBoots
+		c.engineTime = m.Security.EngineTime
+		c.syncedAt = time.Now()
+	}
+	return m.PDU, true
+}
@@ -0,0 +1,260 @@
+package snmp
+
+import (
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// agent is a minimal SNMP agent serving a fixed, sorted MIB view
+type agent struct {
+	conn      net.PacketConn
+	community string
+	user      User
+	engineID  []byte
+	variables []Variable
+	requests  atomic.Int32
+}
+
+func startAgent(t *testing.T, a *agent) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.conn = conn
+	t.Cleanup(func() { conn.Close() })
+	go a.serve()
+	return conn.LocalAddr().String()
+}
+
+func (a *agent) serve() {
+	var keys *SecurityKeys
+	if a.engineID != nil {
+		keys, _ = a.user.Localize(a.engineID)
+	}
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		m, err := Unmarshal(buf[:n])
+		if err != nil {
+			continue
+		}
+		a.requests.Add(1)
+
+		var reply *Message
+		var replyKeys *SecurityKeys
+		switch {
+		case m.Version != Version3:
+			if m.Community != a.community {
+				continue
+			}
+			reply = &Message{Version: m.Version, Community: m.Community, PDU: a.respond(m.PDU, m.Version)}
+		case m.Security.UserName == "":
+			// Discovery: report the engine so the client can localize keys
+			reply = a.v3Reply(m, PDU{Type: Report, RequestID: m.PDU.RequestID, Variables: []Variable{
+				{OID: MustParseOID("1.3.6.1.6.3.15.1.1.4.0"), Type: Counter32, Value: uint64(1)},
+			}})
+		default:
+			if m.Open(keys) != nil {
+				continue
+			}
+			reply, replyKeys = a.v3Reply(m, a.respond(m.PDU, m.Version)), keys
+		}
+		data, err := reply.Marshal(replyKeys)
+		if err != nil {
+			continue
+		}
+		a.conn.WriteTo(data, addr)
+	}
+}
+
+func (a *agent) v3Reply(m *Message, pdu PDU) *Message {
+	return &Message{
+		Version:         Version3,
+		MessageID:       m.MessageID,
+		ContextEngineID: a.engineID,
+		PDU:             pdu,
+		Security:        SecurityParameters{EngineID: a.engineID, EngineBoots: 1, EngineTime: 100, UserName: m.Security.UserName},
+	}
+}
+
+// respond answers Get, GetNext and GetBulk requests from the MIB view
+func (a *agent) respond(request PDU, version Version) PDU {
+	response := PDU{Type: GetResponse, RequestID: request.RequestID}
+	if len(request.Variables) == 0 {
+		return response
+	}
+	oid := request.Variables[0].OID
+
+	switch request.Type {
+	case GetRequest:
+		for _, v := range request.Variables {
+			found := Variable{OID: v.OID, Type: NoSuchObject}
+			for _, have := range a.variables {
+				if have.OID.Compare(v.OID) == 0 {
+					found = have
+				}
+			}
+			response.Variables = append(response.Variables, found)
+		}
+	case GetNextRequest, GetBulkRequest:
+		count := 1
+		if request.Type == GetBulkRequest {
+			count = request.MaxRepetitions
+		}
+		for _, v := range a.variables {
+			if len(response.Variables) == count {
+				break
+			}
+			if v.OID.Compare(oid) > 0 {
+				response.Variables = append(response.Variables, v)
+			}
+		}
+		if len(response.Variables) < count {
+			if version == Version1 {
+				return PDU{Type: GetResponse, RequestID: request.RequestID, ErrorStatus: 2, ErrorIndex: 1, Variables: request.Variables}
+			}
+			response.Variables = append(response.Variables, Variable{OID: oid, Type: EndOfMibView})
+		}
+	}
+	return response
+}
+
+// ifTable holds two interface rows followed by an unrelated subtree
+var ifTable = []Variable{
+	{OID: MustParseOID("1.3.6.1.2.1.1.5.0"), Type: OctetString, Value: []byte("agent")},
+	{OID: MustParseOID("1.3.6.1.2.1.2.2.1.1.1"), Type: Integer, Value: int64(1)},
+	{OID: MustParseOID("1.3.6.1.2.1.2.2.1.1.2"), Type: Integer, Value: int64(2)},
+	{OID: MustParseOID("1.3.6.1.2.1.2.2.1.2.1"), Type: OctetString, Value: []byte("eth0")},
+	{OID: MustParseOID("1.3.6.1.2.1.2.2.1.2.2"), Type: OctetString, Value: []byte("eth1")},
+	{OID: MustParseOID("1.3.6.1.2.1.2.2.1.10.1"), Type: Counter32, Value: uint64(1000)},
+	{OID: MustParseOID("1.3.6.1.2.1.2.2.1.10.2"), Type: Counter32, Value: uint64(2000)},
+	{OID: MustParseOID("1.3.6.1.2.1.4.1.0"), Type: Integer, Value: int64(1)},
+}
+
+func TestClientWalk(t *testing.T) {
+	tests := []struct {
+		name   string
+		agent  *agent
+		client Client
+		root   string
+		want   []Variable
+	}{
+		{
+			name:   "v2c GetBulk",
+			agent:  &agent{community: "public"},
+			client: Client{Version: Version2c, Community: "public", MaxRepetitions: 4},
+			root:   "1.3.6.1.2.1.2.2",
+			want:   ifTable[1:7],
+		},
+		{
+			name:   "v2c to the end of the MIB",
+			agent:  &agent{community: "public"},
+			client: Client{Version: Version2c, Community: "public", MaxRepetitions: 3},
+			root:   "1.3.6.1.2.1.4",
+			want:   ifTable[7:],
+		},
+		{
+			name:   "v1 GetNext ends with noSuchName",
+			agent:  &agent{community: "public"},
+			client: Client{Version: Version1, Community: "public"},
+			root:   "1.3.6.1.2.1",
+			want:   ifTable,
+		},
+		{
+			name: "v3 authPriv",
+			agent: &agent{
+				user:     User{Name: "monitor", AuthProtocol: AuthSHA, AuthPassword: "authpassword", PrivProtocol: PrivAES, PrivPassword: "privpassword"},
+				engineID: []byte{0x80, 0, 0x1f, 0x88, 0x04, 'a', 'g', 'e', 'n', 't'},
+			},
+			client: Client{Version: Version3, User: User{Name: "monitor", AuthProtocol: AuthSHA, AuthPassword: "authpassword", PrivProtocol: PrivAES, PrivPassword: "privpassword"}},
+			root:   "1.3.6.1.2.1.2.2.1.2",
+			want:   ifTable[3:5],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.agent.variables = ifTable
+			client := tt.client
+			client.Address = startAgent(t, tt.agent)
+			client.Timeout = time.Second
+			if err := client.Connect(); err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			var got []Variable
+			err := client.Walk(MustParseOID(tt.root), func(v Variable) error {
+				got = append(got, v)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Walk returned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientGet(t *testing.T) {
+	a := &agent{community: "private", variables: ifTable}
+	client := Client{Address: startAgent(t, a), Version: Version2c, Community: "private", Timeout: time.Second}
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	got, err := client.Get(MustParseOID("1.3.6.1.2.1.1.5.0"), MustParseOID("1.3.6.1.2.1.1.6.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].String() != "agent" || got[1].Type != NoSuchObject {
+		t.Errorf("Get returned %v", got)
+	}
+}
+
+func TestClientWrongCredentials(t *testing.T) {
+	t.Run("community", func(t *testing.T) {
+		a := &agent{community: "public", variables: ifTable}
+		client := Client{Address: startAgent(t, a), Version: Version2c, Community: "wrong", Timeout: 100 * time.Millisecond, Retries: 1}
+		if err := client.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		if _, err := client.Get(MustParseOID("1.3.6.1.2.1.1.5.0")); err == nil {
+			t.Fatal("Get succeeded with the wrong community")
+		}
+		if n := a.requests.Load(); n != 2 {
+			t.Errorf("agent saw %d requests, want 2 with one retry", n)
+		}
+	})
+
+	t.Run("v3 password", func(t *testing.T) {
+		a := &agent{
+			user:      User{Name: "monitor", AuthProtocol: AuthSHA, AuthPassword: "authpassword"},
+			engineID:  []byte("engine"),
+			variables: ifTable,
+		}
+		client := Client{
+			Address: startAgent(t, a),
+			Version: Version3,
+			User:    User{Name: "monitor", AuthProtocol: AuthSHA, AuthPassword: "wrongpassword"},
+			Timeout: 100 * time.Millisecond,
+		}
+		if err := client.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		if _, err := client.Get(MustParseOID("1.3.6.1.2.1.1.5.0")); err == nil {
+			t.Fatal("Get succeeded with the wrong password")
+		}
+	})
+}
@@ -0,0 +1,479 @@
+// Package snmp implements the parts of SNMP v1, v2c and v3 (USM) needed to
+// poll agents and receive notifications: a BER codec, message encoding with
+// authentication and privacy, and a polling client.
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Version is the SNMP message version field
+type Version int
+
+const (
+	Version1  Version = 0
+	Version2c Version = 1
+	Version3  Version = 3
+)
+
+func (v Version) String() string {
+	switch v {
+	case Version1:
+		return "1"
+	case Version2c:
+		return "2c"
+	case Version3:
+		return "3"
+	}
+	return fmt.Sprintf("unknown(%d)", int(v))
+}
+
+// PDUType is the BER tag of a protocol data unit
+type PDUType byte
+
+const (
+	GetRequest     PDUType = 0xa0
+	GetNextRequest PDUType = 0xa1
+	GetResponse    PDUType = 0xa2
+	SetRequest     PDUType = 0xa3
+	TrapV1         PDUType = 0xa4
+	GetBulkRequest PDUType = 0xa5
+	InformRequest  PDUType = 0xa6
+	TrapV2         PDUType = 0xa7
+	Report         PDUType = 0xa8
+)
+
+// PDU is a protocol data unit. For GetBulkRequest, NonRepeaters and
+// MaxRepetitions take the place of the error fields; an SNMPv1 TrapV1
+// carries the trap fields instead of a request ID and error fields.
+type PDU struct {
+	Type           PDUType
+	RequestID      int32
+	ErrorStatus    int
+	ErrorIndex     int
+	NonRepeaters   int
+	MaxRepetitions int
+	Variables      []Variable
+
+	Enterprise   OID
+	AgentAddress net.IP
+	GenericTrap  int
+	SpecificTrap int
+	Timestamp    uint32 // sysUpTime of the agent, in hundredths of a second
+}
+
+// v3 message flags
+const (
+	flagAuth       = 0x01
+	flagPriv       = 0x02
+	flagReportable = 0x04
+)
+
+const securityModelUSM = 3
+
+// SecurityParameters are the USM fields of an SNMPv3 message
+type SecurityParameters struct {
+	EngineID    []byte
+	EngineBoots int32
+	EngineTime  int32
+	UserName    string
+	AuthParams  []byte
+	PrivParams  []byte
+}
+
+// Message is an SNMP message. Community applies to v1 and v2c; the
+// remaining fields to v3.
+type Message struct {
+	Version   Version
+	Community string
+
+	MessageID       int32
+	MaxSize         int32
+	Reportable      bool
+	Security        SecurityParameters
+	ContextEngineID []byte
+	ContextName     string
+
+	PDU PDU
+
+	raw       []byte // received message, for authentication
+	authAt    int    // offset of the authentication parameters in raw
+	flags     byte
+	encrypted []byte // encrypted scoped PDU awaiting Open
+}
+
+// Authenticated reports whether a received v3 message carried an
+// authentication code. It is only trustworthy after Open succeeded.
+func (m *Message) Authenticated() bool {
+	return m.flags&flagAuth != 0
+}
+
+// Marshal encodes the message. keys authenticate and encrypt v3 messages;
+// nil sends them without security (used for engine discovery).
+func (m *Message) Marshal(keys *SecurityKeys) ([]byte, error) {
+	pdu, err := marshalPDU(m.PDU)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Version != Version3 {
+		var body []byte
+		body = appendInt(body, byte(Integer), int64(m.Version))
+		body = appendTLV(body, byte(OctetString), []byte(m.Community))
+		body = append(body, pdu...)
+		return appendTLV(nil, tagSequence, body), nil
+	}
+
+	var scoped []byte
+	scoped = appendTLV(scoped, byte(OctetString), m.ContextEngineID)
+	scoped = appendTLV(scoped, byte(OctetString), []byte(m.ContextName))
+	scoped = appendTLV(nil, tagSequence, append(scoped, pdu...))
+
+	sec := m.Security
+	flags := byte(0)
+	if m.Reportable {
+		flags |= flagReportable
+	}
+	if keys != nil && keys.auth.hash != nil {
+		flags |= flagAuth
+		sec.AuthParams = make([]byte, keys.auth.macLen)
+		if keys.privProto != PrivNone {
+			flags |= flagPriv
+			ciphertext, salt, err := keys.encrypt(scoped, sec.EngineBoots, sec.EngineTime)
+			if err != nil {
+				return nil, err
+			}
+			sec.PrivParams = salt
+			scoped = appendTLV(nil, byte(OctetString), ciphertext)
+		}
+	}
+
+	var header []byte
+	header = appendInt(header, byte(Integer), int64(m.MessageID))
+	maxSize := m.MaxSize
+	if maxSize == 0 {
+		maxSize = 65507
+	}
+	header = appendInt(header, byte(Integer), int64(maxSize))
+	header = appendTLV(header, byte(OctetString), []byte{flags})
+	header = appendInt(header, byte(Integer), securityModelUSM)
+
+	var usm []byte
+	usm = appendTLV(usm, byte(OctetString), sec.EngineID)
+	usm = appendInt(usm, byte(Integer), int64(sec.EngineBoots))
+	usm = appendInt(usm, byte(Integer), int64(sec.EngineTime))
+	usm = appendTLV(usm, byte(OctetString), []byte(sec.UserName))
+	usm = appendTLV(usm, byte(OctetString), sec.AuthParams)
+	authEnd := len(usm) // the authentication parameters end here
+	usm = appendTLV(usm, byte(OctetString), sec.PrivParams)
+	usmSeq := appendTLV(nil, tagSequence, usm)
+	usmOctets := appendTLV(nil, byte(OctetString), usmSeq)
+	authEnd += len(usmOctets) - len(usm)
+
+	var body []byte
+	body = appendInt(body, byte(Integer), int64(Version3))
+	body = appendTLV(body, tagSequence, header)
+	authEnd += len(body)
+	body = append(body, usmOctets...)
+	body = append(body, scoped...)
+	data := appendTLV(nil, tagSequence, body)
+	authEnd += len(data) - len(body)
+
+	if flags&flagAuth != 0 {
+		mac := keys.auth.sign(keys.authKey, data)
+		copy(data[authEnd-len(mac):authEnd], mac)
+	}
+	return data, nil
+}
+
+func marshalPDU(pdu PDU) ([]byte, error) {
+	var vars []byte
+	for _, v := range pdu.Variables {
+		var err error
+		if vars, err = appendVariable(vars, v); err != nil {
+			return nil, err
+		}
+	}
+
+	var body []byte
+	if pdu.Type == TrapV1 {
+		agent := pdu.AgentAddress.To4()
+		if agent == nil {
+			agent = net.IPv4zero.To4()
+		}
+		body = appendOID(body, pdu.Enterprise)
+		body = appendTLV(body, byte(IPAddress), agent)
+		body = appendInt(body, byte(Integer), int64(pdu.GenericTrap))
+		body = appendInt(body, byte(Integer), int64(pdu.SpecificTrap))
+		body = appendUint(body, byte(TimeTicks), uint64(pdu.Timestamp))
+		body = appendTLV(body, tagSequence, vars)
+		return appendTLV(nil, byte(pdu.Type), body), nil
+	}
+
+	errStatus, errIndex := pdu.ErrorStatus, pdu.ErrorIndex
+	if pdu.Type == GetBulkRequest {
+		errStatus, errIndex = pdu.NonRepeaters, pdu.MaxRepetitions
+	}
+	body = appendInt(body, byte(Integer), int64(pdu.RequestID))
+	body = appendInt(body, byte(Integer), int64(errStatus))
+	body = appendInt(body, byte(Integer), int64(errIndex))
+	body = appendTLV(body, tagSequence, vars)
+	return appendTLV(nil, byte(pdu.Type), body), nil
+}
+
+// Unmarshal decodes a message. For v3 messages only the header and the
+// security parameters are decoded; Open checks authentication and decodes
+// the PDU.
+func Unmarshal(data []byte) (*Message, error) {
+	top := &decoder{buf: data}
+	seq, err := top.expect(tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	version, err := seq.readInt()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Message{Version: Version(version), raw: data}
+	switch m.Version {
+	case Version1, Version2c:
+		community, err := seq.readOctets()
+		if err != nil {
+			return nil, err
+		}
+		m.Community = string(community)
+		m.PDU, err = unmarshalPDU(seq)
+		return m, err
+	case Version3:
+		return m, m.unmarshalV3(seq)
+	default:
+		return nil, fmt.Errorf("unsupported SNMP version %d", version)
+	}
+}
+
+func (m *Message) unmarshalV3(seq *decoder) error {
+	header, err := seq.expect(tagSequence)
+	if err != nil {
+		return err
+	}
+	id, err := header.readInt()
+	if err != nil {
+		return err
+	}
+	maxSize, err := header.readInt()
+	if err != nil {
+		return err
+	}
+	flags, err := header.readOctets()
+	if err != nil || len(flags) != 1 {
+		return errors.New("invalid msgFlags")
+	}
+	model, err := header.readInt()
+	if err != nil {
+		return err
+	}
+	if model != securityModelUSM {
+		return fmt.Errorf("unsupported security model %d", model)
+	}
+	m.MessageID, m.MaxSize = int32(id), int32(maxSize)
+	m.flags = flags[0]
+	m.Reportable = m.flags&flagReportable != 0
+
+	usmOctets, err := seq.expect(byte(OctetString))
+	if err != nil {
+		return err
+	}
+	usm, err := usmOctets.expect(tagSequence)
+	if err != nil {
+		return err
+	}
+	sec := &m.Security
+	if sec.EngineID, err = usm.readOctets(); err != nil {
+		return err
+	}
+	boots, err := usm.readInt()
+	if err != nil {
+		return err
+	}
+	engineTime, err := usm.readInt()
+	if err != nil {
+		return err
+	}
+	sec.EngineBoots, sec.EngineTime = int32(boots), int32(engineTime)
+	user, err := usm.readOctets()
+	if err != nil {
+		return err
+	}
+	sec.UserName = string(user)
+	auth, err := usm.expect(byte(OctetString))
+	if err != nil {
+		return err
+	}
+	sec.AuthParams, m.authAt = auth.buf, auth.off
+	if sec.PrivParams, err = usm.readOctets(); err != nil {
+		return err
+	}
+
+	if m.flags&flagPriv != 0 {
+		if m.encrypted, err = seq.readOctets(); err != nil {
+			return err
+		}
+		return nil
+	}
+	return m.unmarshalScoped(seq)
+}
+
+func (m *Message) unmarshalScoped(d *decoder) error {
+	scoped, err := d.expect(tagSequence)
+	if err != nil {
+		return err
+	}
+	if m.ContextEngineID, err = scoped.readOctets(); err != nil {
+		return err
+	}
+	name, err := scoped.readOctets()
+	if err != nil {
+		return err
+	}
+	m.ContextName = string(name)
+	m.PDU, err = unmarshalPDU(scoped)
+	return err
+}
+
+// Open verifies the authentication code of a received v3 message and
+// decrypts its PDU. keys must be localized to the authoritative engine of
+// the message.
+func (m *Message) Open(keys *SecurityKeys) error {
+	if m.Version != Version3 {
+		return nil
+	}
+	if m.flags&flagAuth != 0 {
+		if keys == nil || keys.auth.hash == nil {
+			return errors.New("authenticated message but no authentication key")
+		}
+		if len(m.Security.AuthParams) != keys.auth.macLen {
+			return errors.New("wrong authentication parameter length")
+		}
+		if !keys.auth.verify(keys.authKey, m.raw, m.authAt) {
+			return errors.New("authentication failed")
+		}
+	}
+	if m.flags&flagPriv != 0 {
+		if keys == nil || keys.privProto == PrivNone {
+			return errors.New("encrypted message but no privacy key")
+		}
+		plaintext, err := keys.decrypt(m.encrypted, m.Security)
+		if err != nil {
+			return err
+		}
+		m.encrypted = nil
+		return m.unmarshalScoped(&decoder{buf: plaintext})
+	}
+	return nil
+}
+
+func unmarshalPDU(d *decoder) (PDU, error) {
+	tag, body, err := d.next()
+	if err != nil {
+		return PDU{}, err
+	}
+	pdu := PDU{Type: PDUType(tag)}
+	if tag < 0xa0 || tag > 0xa8 {
+		return pdu, fmt.Errorf("unknown PDU type 0x%02x", tag)
+	}
+	if pdu.Type == TrapV1 {
+		if err := unmarshalTrapV1(body, &pdu); err != nil {
+			return pdu, err
+		}
+		return pdu, unmarshalVariables(body, &pdu)
+	}
+
+	id, err := body.readInt()
+	if err != nil {
+		return pdu, err
+	}
+	status, err := body.readInt()
+	if err != nil {
+		return pdu, err
+	}
+	index, err := body.readInt()
+	if err != nil {
+		return pdu, err
+	}
+	pdu.RequestID = int32(id)
+	if pdu.Type == GetBulkRequest {
+		pdu.NonRepeaters, pdu.MaxRepetitions = int(status), int(index)
+	} else {
+		pdu.ErrorStatus, pdu.ErrorIndex = int(status), int(index)
+	}
+	return pdu, unmarshalVariables(body, &pdu)
+}
+
+// unmarshalTrapV1 reads the fields that precede the variable bindings of
+// an SNMPv1 Trap-PDU
+func unmarshalTrapV1(body *decoder, pdu *PDU) error {
+	var err error
+	if pdu.Enterprise, err = body.readOID(); err != nil {
+		return err
+	}
+	agent, err := body.expect(byte(IPAddress))
+	if err != nil {
+		return err
+	}
+	if len(agent.buf) != 4 {
+		return errors.New("invalid agent address")
+	}
+	pdu.AgentAddress = net.IP(append([]byte(nil), agent.buf...))
+	generic, err := body.readInt()
+	if err != nil {
+		return err
+	}
+	specific, err := body.readInt()
+	if err != nil {
+		return err
+	}
+	timestamp, err := body.expect(byte(TimeTicks))
+	if err != nil {
+		return err
+	}
+	ticks, err := parseUint(timestamp.buf)
+	if err != nil {
+		return err
+	}
+	pdu.GenericTrap, pdu.SpecificTrap, pdu.Timestamp = int(generic), int(specific), uint32(ticks)
+	return nil
+}
+
+func unmarshalVariables(body *decoder, pdu *PDU) error {
+	vars, err := body.expect(tagSequence)
+	if err != nil {
+		return err
+	}
+	for !vars.empty() {
+		v, err := vars.readVariable()
+		if err != nil {
+			return err
+		}
+		pdu.Variables = append(pdu.Variables, v)
+	}
+	return nil
+}
+
+// errorStatusNames are the PDU error-status values of RFC 3416
+var errorStatusNames = []string{
+	"noError", "tooBig", "noSuchName", "badValue", "readOnly", "genErr",
+	"noAccess", "wrongType", "wrongLength", "wrongEncoding", "wrongValue",
+	"noCreation", "inconsistentValue", "resourceUnavailable", "commitFailed",
+	"undoFailed", "authorizationError", "notWritable", "inconsistentName",
+}
+
+// ErrorStatusName names a PDU error-status value
+func ErrorStatusName(status int) string {
+	if status >= 0 && status < len(errorStatusNames) {
+		return errorStatusNames[status]
+	}
+	return fmt.Sprintf("error %d", status)
+}
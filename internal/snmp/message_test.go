@@ -0,0 +1,173 @@
+package snmp
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+var testVariables = []Variable{
+	{OID: MustParseOID("1.3.6.1.2.1.1.1.0"), Type: OctetString, Value: []byte("router")},
+	{OID: MustParseOID("1.3.6.1.2.1.1.2.0"), Type: ObjectIdentifier, Value: MustParseOID("1.3.6.1.4.1.9.1.1")},
+	{OID: MustParseOID("1.3.6.1.2.1.1.3.0"), Type: TimeTicks, Value: uint64(4294967295)},
+	{OID: MustParseOID("1.3.6.1.2.1.2.2.1.8.1"), Type: Integer, Value: int64(-129)},
+	{OID: MustParseOID("1.3.6.1.2.1.2.2.1.10.1"), Type: Counter32, Value: uint64(128)},
+	{OID: MustParseOID("1.3.6.1.2.1.2.2.1.5.1"), Type: Gauge32, Value: uint64(1000000000)},
+	{OID: MustParseOID("1.3.6.1.2.1.31.1.1.1.6.1"), Type: Counter64, Value: uint64(1<<64 - 1)},
+	{OID: MustParseOID("1.3.6.1.2.1.4.20.1.1.192.0.2.1"), Type: IPAddress, Value: net.IP{192, 0, 2, 1}},
+	{OID: MustParseOID("1.3.6.1.2.1.1.9.0"), Type: Null},
+	{OID: MustParseOID("1.3.6.1.2.1.99"), Type: EndOfMibView},
+}
+
+func TestMessageRoundTripCommunity(t *testing.T) {
+	for _, version := range []Version{Version1, Version2c} {
+		t.Run(version.String(), func(t *testing.T) {
+			pdu := PDU{Type: GetResponse, RequestID: 1 << 30, ErrorStatus: 2, ErrorIndex: 3, Variables: testVariables}
+			data, err := (&Message{Version: version, Community: "public", PDU: pdu}).Marshal(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := Unmarshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Version != version || m.Community != "public" {
+				t.Errorf("version %s community %q", m.Version, m.Community)
+			}
+			if !reflect.DeepEqual(m.PDU, pdu) {
+				t.Errorf("PDU = %+v, want %+v", m.PDU, pdu)
+			}
+		})
+	}
+}
+
+func TestMessageRoundTripGetBulk(t *testing.T) {
+	pdu := PDU{Type: GetBulkRequest, RequestID: 9, NonRepeaters: 1, MaxRepetitions: 25,
+		Variables: []Variable{{OID: MustParseOID("1.3.6.1.2.1.2.2"), Type: Null}}}
+	data, err := (&Message{Version: Version2c, Community: "c", PDU: pdu}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.PDU, pdu) {
+		t.Errorf("PDU = %+v, want %+v", m.PDU, pdu)
+	}
+}
+
+func TestMessageRoundTripTrapV1(t *testing.T) {
+	pdu := PDU{Type: TrapV1, Enterprise: MustParseOID("1.3.6.1.4.1.8072"), AgentAddress: net.IP{192, 0, 2, 7},
+		GenericTrap: 6, SpecificTrap: 42, Timestamp: 12345, Variables: testVariables[:2]}
+	data, err := (&Message{Version: Version1, Community: "traps", PDU: pdu}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.PDU, pdu) {
+		t.Errorf("PDU = %+v, want %+v", m.PDU, pdu)
+	}
+}
+
+func TestMessageRoundTripUSM(t *testing.T) {
+	engineID := []byte{0x80, 0, 0x1f, 0x88, 0x04, 't', 'e', 's', 't'}
+	tests := []struct {
+		name string
+		user User
+	}{
+		{"noAuthNoPriv", User{Name: "nobody"}},
+		{"authNoPriv MD5", User{Name: "auth", AuthProtocol: AuthMD5, AuthPassword: "authpassword"}},
+		{"authPriv SHA AES", User{Name: "aes", AuthProtocol: AuthSHA, AuthPassword: "authpassword", PrivProtocol: PrivAES, PrivPassword: "privpassword"}},
+		{"authPriv SHA256 DES", User{Name: "des", AuthProtocol: AuthSHA256, AuthPassword: "authpassword", PrivProtocol: PrivDES, PrivPassword: "privpassword"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := tt.user.Localize(engineID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pdu := PDU{Type: GetResponse, RequestID: 77, Variables: testVariables}
+			sent := &Message{
+				Version:         Version3,
+				MessageID:       77,
+				Reportable:      true,
+				ContextEngineID: engineID,
+				ContextName:     "ctx",
+				PDU:             pdu,
+				Security:        SecurityParameters{EngineID: engineID, EngineBoots: 3, EngineTime: 86400, UserName: tt.user.Name},
+			}
+			data, err := sent.Marshal(keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := Unmarshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Open(keys); err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			secure := tt.user.AuthProtocol != AuthNone
+			if m.Authenticated() != secure {
+				t.Errorf("Authenticated() = %v, want %v", m.Authenticated(), secure)
+			}
+			if m.MessageID != 77 || !m.Reportable || m.ContextName != "ctx" || string(m.ContextEngineID) != string(engineID) {
+				t.Errorf("header = id %d reportable %v context %q %x", m.MessageID, m.Reportable, m.ContextName, m.ContextEngineID)
+			}
+			sec := m.Security
+			if string(sec.EngineID) != string(engineID) || sec.EngineBoots != 3 || sec.EngineTime != 86400 || sec.UserName != tt.user.Name {
+				t.Errorf("security parameters = %+v", sec)
+			}
+			if !reflect.DeepEqual(m.PDU, pdu) {
+				t.Errorf("PDU = %+v, want %+v", m.PDU, pdu)
+			}
+			if !secure {
+				return
+			}
+
+			// A flipped bit anywhere fails authentication
+			tampered := append([]byte(nil), data...)
+			tampered[len(tampered)-1] ^= 1
+			if m, err := Unmarshal(tampered); err == nil {
+				if err := m.Open(keys); err == nil {
+					t.Error("Open accepted a tampered message")
+				}
+			}
+
+			// So do keys localized to another engine, or no keys at all
+			other, _ := tt.user.Localize([]byte("another engine"))
+			for _, wrong := range []*SecurityKeys{other, nil} {
+				m, _ := Unmarshal(data)
+				if err := m.Open(wrong); err == nil {
+					t.Errorf("Open with keys %v succeeded", wrong)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	good, _ := (&Message{Version: Version2c, Community: "public", PDU: PDU{Type: GetResponse, Variables: testVariables}}).Marshal(nil)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a sequence", []byte{0x02, 0x01, 0x00}},
+		{"truncated", good[:len(good)-3]},
+		{"unknown version", []byte{0x30, 0x03, 0x02, 0x01, 0x02}},
+		{"length beyond data", []byte{0x30, 0x84, 0x7f, 0xff, 0xff, 0xff, 0x02}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Unmarshal(tt.data); err == nil {
+				t.Error("Unmarshal succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package snmp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// standardNames are the objects and traps named without loading any MIB
+var standardNames = map[string]string{
+	"1.3.6.1.2.1.1.1":         "sysDescr",
+	"1.3.6.1.2.1.1.2":         "sysObjectID",
+	"1.3.6.1.2.1.1.3":         "sysUpTime",
+	"1.3.6.1.2.1.1.4":         "sysContact",
+	"1.3.6.1.2.1.1.5":         "sysName",
+	"1.3.6.1.2.1.1.6":         "sysLocation",
+	"1.3.6.1.2.1.2.2.1.1":     "ifIndex",
+	"1.3.6.1.2.1.2.2.1.2":     "ifDescr",
+	"1.3.6.1.2.1.2.2.1.3":     "ifType",
+	"1.3.6.1.2.1.2.2.1.7":     "ifAdminStatus",
+	"1.3.6.1.2.1.2.2.1.8":     "ifOperStatus",
+	"1.3.6.1.2.1.31.1.1.1.1":  "ifName",
+	"1.3.6.1.2.1.31.1.1.1.18": "ifAlias",
+	"1.3.6.1.6.3.1.1.4.1":     "snmpTrapOID",
+	"1.3.6.1.6.3.1.1.4.3":     "snmpTrapEnterprise",
+	"1.3.6.1.6.3.1.1.5.1":     "coldStart",
+	"1.3.6.1.6.3.1.1.5.2":     "warmStart",
+	"1.3.6.1.6.3.1.1.5.3":     "linkDown",
+	"1.3.6.1.6.3.1.1.5.4":     "linkUp",
+	"1.3.6.1.6.3.1.1.5.5":     "authenticationFailure",
+	"1.3.6.1.6.3.1.1.5.6":     "egpNeighborLoss",
+}
+
+// MIB maps OIDs to object names. It only holds names, not syntax.
+type MIB struct {
+	names map[string]string // dotted OID to name
+	oids  map[string]OID    // name to OID
+}
+
+// NewMIB returns a MIB holding the standard system, interface and trap
+// names
+func NewMIB() *MIB {
+	m := &MIB{names: make(map[string]string), oids: make(map[string]OID)}
+	for oid, name := range standardNames {
+		m.Add(name, MustParseOID(oid))
+	}
+	return m
+}
+
+// Add names an OID
+func (m *MIB) Add(name string, oid OID) {
+	m.names[oid.String()] = name
+	m.oids[name] = oid
+}
+
+// LoadFile reads a name map with one "name OID" pair per line, in either
+// order. Quotes are stripped, so the output of "snmptranslate -Tz" can be
+// used directly; lines starting with # are comments.
+func (m *MIB) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(strings.ReplaceAll(text, `"`, " "))
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: expected a name and an OID", path, line)
+		}
+		name, value := fields[0], fields[1]
+		oid, err := ParseOID(value)
+		if err != nil {
+			name, value = value, name
+			if oid, err = ParseOID(value); err != nil {
+				return fmt.Errorf("%s:%d: no numeric OID", path, line)
+			}
+		}
+		m.Add(name, oid)
+	}
+	return scanner.Err()
+}
+
+// Name returns the name of the closest named ancestor of oid followed by
+// the remaining sub-identifiers, e.g. "ifOperStatus.3". OIDs outside the
+// map are returned in dotted form.
+func (m *MIB) Name(oid OID) string {
+	for n := len(oid); n > 0; n-- {
+		name, exists := m.names[oid[:n].String()]
+		if !exists {
+			continue
+		}
+		if n == len(oid) {
+			return name
+		}
+		return name + "." + oid[n:].String()
+	}
+	return oid.String()
+}
+
+// Lookup resolves a name or a dotted OID
+func (m *MIB) Lookup(name string) (OID, bool) {
+	if oid, exists := m.oids[name]; exists {
+		return oid, true
+	}
+	oid, err := ParseOID(name)
+	return oid, err == nil
+}
@@ -0,0 +1,62 @@
+package snmp
+
+import (
+	"fmt"
+	"net"
+)
+
+var (
+	OIDSysUpTime          = MustParseOID("1.3.6.1.2.1.1.3.0")
+	OIDSnmpTrapOID        = MustParseOID("1.3.6.1.6.3.1.1.4.1.0")
+	OIDSnmpTrapEnterprise = MustParseOID("1.3.6.1.6.3.1.1.4.3.0")
+
+	// snmpTraps holds the generic traps: coldStart(1) to egpNeighborLoss(6)
+	snmpTraps = MustParseOID("1.3.6.1.6.3.1.1.5")
+)
+
+// Notification is a trap or inform in SNMPv2 form
+type Notification struct {
+	TrapOID      OID
+	Uptime       uint32     // hundredths of a second
+	AgentAddress net.IP     // only carried by SNMPv1 traps
+	Variables    []Variable // without sysUpTime.0 and snmpTrapOID.0
+}
+
+// NotificationFromPDU extracts the notification of a TrapV1, TrapV2 or
+// InformRequest PDU. SNMPv1 traps are translated as described in RFC 3584
+// section 3.1.
+func NotificationFromPDU(pdu PDU) (*Notification, error) {
+	switch pdu.Type {
+	case TrapV1:
+		n := &Notification{
+			Uptime:       pdu.Timestamp,
+			AgentAddress: pdu.AgentAddress,
+			Variables:    append([]Variable(nil), pdu.Variables...),
+		}
+		if pdu.GenericTrap >= 0 && pdu.GenericTrap < 6 {
+			n.TrapOID = append(append(OID(nil), snmpTraps...), uint32(pdu.GenericTrap+1))
+		} else {
+			n.TrapOID = append(append(OID(nil), pdu.Enterprise...), 0, uint32(pdu.SpecificTrap))
+		}
+		n.Variables = append(n.Variables, Variable{OID: OIDSnmpTrapEnterprise, Type: ObjectIdentifier, Value: pdu.Enterprise})
+		return n, nil
+
+	case TrapV2, InformRequest:
+		n := &Notification{}
+		for i, v := range pdu.Variables {
+			switch {
+			case i == 0 && v.OID.Compare(OIDSysUpTime) == 0:
+				n.Uptime = uint32(v.Uint64())
+			case i <= 1 && v.OID.Compare(OIDSnmpTrapOID) == 0:
+				n.TrapOID, _ = v.Value.(OID)
+			default:
+				n.Variables = append(n.Variables, v)
+			}
+		}
+		if n.TrapOID == nil {
+			return nil, fmt.Errorf("notification without snmpTrapOID.0")
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("PDU type 0x%02x is not a notification", byte(pdu.Type))
+}
@@ -0,0 +1,268 @@
+package snmp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"sync/atomic"
+)
+
+// AuthProtocol selects the USM authentication protocol
+type AuthProtocol string
+
+const (
+	AuthNone   AuthProtocol = ""
+	AuthMD5    AuthProtocol = "MD5"    // HMAC-MD5-96
+	AuthSHA    AuthProtocol = "SHA"    // HMAC-SHA-96
+	AuthSHA256 AuthProtocol = "SHA256" // HMAC-SHA-256-192
+)
+
+// PrivProtocol selects the USM privacy protocol
+type PrivProtocol string
+
+const (
+	PrivNone PrivProtocol = ""
+	PrivDES  PrivProtocol = "DES" // CBC-DES
+	PrivAES  PrivProtocol = "AES" // CFB128-AES-128
+)
+
+// User holds the credentials of an SNMPv3 user
+type User struct {
+	Name         string       `json:"user"`
+	AuthProtocol AuthProtocol `json:"auth_protocol"`
+	AuthPassword string       `json:"auth_password"`
+	PrivProtocol PrivProtocol `json:"priv_protocol"`
+	PrivPassword string       `json:"priv_password"`
+}
+
+type authAlgorithm struct {
+	hash   func() hash.Hash
+	macLen int
+}
+
+func (a authAlgorithm) sign(key, data []byte) []byte {
+	mac := hmac.New(a.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)[:a.macLen]
+}
+
+// verify checks the MAC stored at offset at of a received message; the MAC
+// is computed with that field zeroed
+func (a authAlgorithm) verify(key, data []byte, at int) bool {
+	if at < 0 || at+a.macLen > len(data) {
+		return false
+	}
+	received := data[at : at+a.macLen]
+	zeroed := append([]byte(nil), data...)
+	clear(zeroed[at : at+a.macLen])
+	return hmac.Equal(received, a.sign(key, zeroed))
+}
+
+func authAlgorithmFor(proto AuthProtocol) (authAlgorithm, error) {
+	switch AuthProtocol(strings.ToUpper(string(proto))) {
+	case AuthNone:
+		return authAlgorithm{}, nil
+	case AuthMD5:
+		return authAlgorithm{md5.New, 12}, nil
+	case AuthSHA, "SHA1":
+		return authAlgorithm{sha1.New, 12}, nil
+	case AuthSHA256:
+		return authAlgorithm{sha256.New, 24}, nil
+	}
+	return authAlgorithm{}, fmt.Errorf("unsupported authentication protocol %q", proto)
+}
+
+// SecurityKeys are a user's keys localized to one authoritative engine
+type SecurityKeys struct {
+	auth      authAlgorithm
+	authKey   []byte
+	privProto PrivProtocol
+	privKey   []byte
+	salt      atomic.Uint64
+}
+
+// Localize derives the user's keys for the engine with the given ID, as
+// described in RFC 3414 section 2.6
+func (u User) Localize(engineID []byte) (*SecurityKeys, error) {
+	master, err := u.MasterKeys()
+	if err != nil {
+		return nil, err
+	}
+	return master.Localize(engineID), nil
+}
+
+// MasterKeys are a user's keys derived from the passwords alone (Ku in
+// RFC 3414). Deriving them is the expensive part; localizing them to an
+// engine takes a single hash.
+type MasterKeys struct {
+	auth      authAlgorithm
+	authKey   []byte
+	privProto PrivProtocol
+	privKey   []byte
+}
+
+// MasterKeys checks the user's protocols and derives the keys of its
+// passwords
+func (u User) MasterKeys() (*MasterKeys, error) {
+	auth, err := authAlgorithmFor(u.AuthProtocol)
+	if err != nil {
+		return nil, err
+	}
+	privProto := PrivProtocol(strings.ToUpper(string(u.PrivProtocol)))
+	if privProto != PrivNone && privProto != PrivDES && privProto != PrivAES {
+		return nil, fmt.Errorf("unsupported privacy protocol %q", u.PrivProtocol)
+	}
+	if auth.hash == nil {
+		if privProto != PrivNone {
+			return nil, errors.New("privacy requires an authentication protocol")
+		}
+		return &MasterKeys{}, nil
+	}
+
+	master := &MasterKeys{auth: auth, privProto: privProto}
+	if master.authKey, err = passwordKey(auth.hash, u.AuthPassword); err != nil {
+		return nil, fmt.Errorf("auth password: %w", err)
+	}
+	if privProto != PrivNone {
+		if master.privKey, err = passwordKey(auth.hash, u.PrivPassword); err != nil {
+			return nil, fmt.Errorf("priv password: %w", err)
+		}
+		if len(master.privKey) < 16 {
+			return nil, errors.New("privacy key too short")
+		}
+	}
+	return master, nil
+}
+
+// Localize binds the keys to the engine with the given ID
+func (m *MasterKeys) Localize(engineID []byte) *SecurityKeys {
+	keys := &SecurityKeys{auth: m.auth, privProto: m.privProto}
+	if m.auth.hash == nil {
+		return keys
+	}
+	keys.authKey = localizedKey(m.auth.hash, m.authKey, engineID)
+	if m.privProto != PrivNone {
+		keys.privKey = localizedKey(m.auth.hash, m.privKey, engineID)
+	}
+
+	var seed [8]byte
+	rand.Read(seed[:])
+	keys.salt.Store(binary.BigEndian.Uint64(seed[:]))
+	return keys
+}
+
+// passwordKey hashes a megabyte of the repeated password
+func passwordKey(newHash func() hash.Hash, password string) ([]byte, error) {
+	if len(password) < 8 {
+		return nil, errors.New("must be at least 8 characters")
+	}
+	h := newHash()
+	chunk := make([]byte, 64)
+	for i := 0; i < 1048576; i += len(chunk) {
+		for j := range chunk {
+			chunk[j] = password[(i+j)%len(password)]
+		}
+		h.Write(chunk)
+	}
+	return h.Sum(nil), nil
+}
+
+// localizedKey binds a password key to an engine ID
+func localizedKey(newHash func() hash.Hash, ku, engineID []byte) []byte {
+	h := newHash()
+	h.Write(ku)
+	h.Write(engineID)
+	h.Write(ku)
+	return h.Sum(nil)
+}
+
+// encrypt encrypts a scoped PDU and returns the ciphertext and the salt to
+// send as privacy parameters
+func (k *SecurityKeys) encrypt(plaintext []byte, boots, engineTime int32) ([]byte, []byte, error) {
+	counter := k.salt.Add(1)
+	salt := make([]byte, 8)
+
+	switch k.privProto {
+	case PrivDES:
+		binary.BigEndian.PutUint32(salt[0:4], uint32(boots))
+		binary.BigEndian.PutUint32(salt[4:8], uint32(counter))
+		block, err := des.NewCipher(k.privKey[:8])
+		if err != nil {
+			return nil, nil, err
+		}
+		iv := desIV(k.privKey, salt)
+		padded := append([]byte(nil), plaintext...)
+		if rem := len(padded) % des.BlockSize; rem != 0 {
+			padded = append(padded, make([]byte, des.BlockSize-rem)...)
+		}
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+		return padded, salt, nil
+	case PrivAES:
+		binary.BigEndian.PutUint64(salt, counter)
+		block, err := aes.NewCipher(k.privKey[:16])
+		if err != nil {
+			return nil, nil, err
+		}
+		ciphertext := make([]byte, len(plaintext))
+		cipher.NewCFBEncrypter(block, aesIV(boots, engineTime, salt)).XORKeyStream(ciphertext, plaintext)
+		return ciphertext, salt, nil
+	}
+	return nil, nil, errors.New("no privacy protocol")
+}
+
+func (k *SecurityKeys) decrypt(ciphertext []byte, sec SecurityParameters) ([]byte, error) {
+	if len(sec.PrivParams) != 8 {
+		return nil, errors.New("invalid privacy parameters")
+	}
+
+	switch k.privProto {
+	case PrivDES:
+		if len(ciphertext)%des.BlockSize != 0 {
+			return nil, errors.New("DES ciphertext is not a whole number of blocks")
+		}
+		block, err := des.NewCipher(k.privKey[:8])
+		if err != nil {
+			return nil, err
+		}
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCBCDecrypter(block, desIV(k.privKey, sec.PrivParams)).CryptBlocks(plaintext, ciphertext)
+		return plaintext, nil
+	case PrivAES:
+		block, err := aes.NewCipher(k.privKey[:16])
+		if err != nil {
+			return nil, err
+		}
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCFBDecrypter(block, aesIV(sec.EngineBoots, sec.EngineTime, sec.PrivParams)).XORKeyStream(plaintext, ciphertext)
+		return plaintext, nil
+	}
+	return nil, errors.New("no privacy protocol")
+}
+
+// desIV XORs the salt into the pre-IV, the second half of the DES key
+func desIV(key, salt []byte) []byte {
+	iv := make([]byte, 8)
+	for i := range iv {
+		iv[i] = key[8+i] ^ salt[i]
+	}
+	return iv
+}
+
+// aesIV concatenates the engine boots, engine time and salt (RFC 3826)
+func aesIV(boots, engineTime int32, salt []byte) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint32(iv[0:4], uint32(boots))
+	binary.BigEndian.PutUint32(iv[4:8], uint32(engineTime))
+	copy(iv[8:], salt)
+	return iv
+}
@@ -0,0 +1,64 @@
+package snmp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// The key localization samples of RFC 3414 appendix A.3
+func TestLocalize(t *testing.T) {
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+	tests := []struct {
+		proto AuthProtocol
+		want  string
+	}{
+		{AuthMD5, "526f5eed9fcce26f8964c2930787d82b"},
+		{AuthSHA, "6695febc9288e36282235fc7151f128497b38f3f"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.proto), func(t *testing.T) {
+			user := User{Name: "u", AuthProtocol: tt.proto, AuthPassword: "maplesyrup", PrivProtocol: PrivDES, PrivPassword: "maplesyrup"}
+			keys, err := user.Localize(engineID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := hex.DecodeString(tt.want)
+			if !bytes.Equal(keys.authKey, want) {
+				t.Errorf("auth key = %x, want %x", keys.authKey, want)
+			}
+			if !bytes.Equal(keys.privKey, want) {
+				t.Errorf("priv key = %x, want %x", keys.privKey, want)
+			}
+
+			// Localizing cached master keys gives the same keys
+			master, err := user.MasterKeys()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again := master.Localize(engineID); !bytes.Equal(again.authKey, want) {
+				t.Errorf("master keys localized to %x, want %x", again.authKey, want)
+			}
+		})
+	}
+}
+
+func TestMasterKeysErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+	}{
+		{"short password", User{AuthProtocol: AuthSHA, AuthPassword: "short"}},
+		{"short priv password", User{AuthProtocol: AuthSHA, AuthPassword: "longenough", PrivProtocol: PrivAES, PrivPassword: "short"}},
+		{"privacy without auth", User{PrivProtocol: PrivAES, PrivPassword: "longenough"}},
+		{"unknown auth", User{AuthProtocol: "SHA512", AuthPassword: "longenough"}},
+		{"unknown priv", User{AuthProtocol: AuthSHA, AuthPassword: "longenough", PrivProtocol: "3DES", PrivPassword: "longenough"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.user.MasterKeys(); err == nil {
+				t.Error("MasterKeys succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"slices"
+	"strconv"
+	"time"
+)
+
+const MaxAlerts = 200
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert is a condition raised by a collector. An alert stays active until
+// the condition that raised it is resolved under the same key.
+type Alert struct {
+	ID         int        `json:"id"`
+	Key        string     `json:"key"`
+	Source     string     `json:"source"`
+	Target     string     `json:"target"`
+	Severity   string     `json:"severity"`
+	Message    string     `json:"message"`
+	Active     bool       `json:"active"`
+	FiredAt    time.Time  `json:"fired_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// RaiseAlert fires the alert identified by key, or refreshes its message
+// if it is already active.
+func (s *Store) RaiseAlert(key, source, target, severity, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.raiseAlertLocked(key, source, target, severity, message)
+}
+
+// ResolveAlert marks the active alert identified by key as resolved.
+func (s *Store) ResolveAlert(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resolveAlertLocked(key)
+}
+
+func (s *Store) raiseAlertLocked(key, source, target, severity, message string) {
+	now := time.Now()
+
+	if alert := s.activeAlertLocked(key); alert != nil {
+		alert.Severity = severity
+		alert.Message = message
+		alert.UpdatedAt = now
+		return
+	}
+
+	s.nextAlertID++
+	s.Alerts = append(s.Alerts, &Alert{
+		ID:        s.nextAlertID,
+		Key:       key,
+		Source:    source,
+		Target:    target,
+		Severity:  severity,
+		Message:   message,
+		Active:    true,
+		FiredAt:   now,
+		UpdatedAt: now,
+	})
+	if len(s.Alerts) > MaxAlerts {
+		// Active alerts must stay findable until they are resolved, so the
+		// oldest resolved alert goes first
+		i := slices.IndexFunc(s.Alerts, func(a *Alert) bool { return !a.Active })
+		if i < 0 {
+			i = 0
+		}
+		s.Alerts = slices.Delete(s.Alerts, i, i+1)
+	}
+	s.addEventLocked(Event{
+		Timestamp: now,
+		Type:      EventAlertFired,
+		Source:    source,
+		Target:    target,
+		Severity:  severity,
+		Message:   message,
+		Fields:    map[string]string{"alert_key": key, "alert_id": strconv.Itoa(s.nextAlertID)},
+	})
+}
+
+func (s *Store) resolveAlertLocked(key string) {
+	if alert := s.activeAlertLocked(key); alert != nil {
+		now := time.Now()
+		alert.Active = false
+		alert.UpdatedAt = now
+		alert.ResolvedAt = &now
+		s.addEventLocked(Event{
+			Timestamp: now,
+			Type:      EventAlertResolved,
+			Source:    alert.Source,
+			Target:    alert.Target,
+			Severity:  SeverityInfo,
+			Message:   "Resolved: " + alert.Message,
+			Fields: map[string]string{
+				"alert_key": key,
+				"alert_id":  strconv.Itoa(alert.ID),
+				"duration":  now.Sub(alert.FiredAt).Round(time.Second).String(),
+			},
+		})
+	}
+}
+
+func (s *Store) activeAlertLocked(key string) *Alert {
+	for i := len(s.Alerts) - 1; i >= 0; i-- {
+		if s.Alerts[i].Key == key && s.Alerts[i].Active {
+			return s.Alerts[i]
+		}
+	}
+	return nil
+}
+
+// GetAlerts returns copies of the stored alerts, newest first. When
+// activeOnly is set, resolved alerts are left out.
+func (s *Store) GetAlerts(activeOnly bool) []Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Alert, 0, len(s.Alerts))
+	for i := len(s.Alerts) - 1; i >= 0; i-- {
+		if activeOnly && !s.Alerts[i].Active {
+			continue
+		}
+		result = append(result, *s.Alerts[i])
+	}
+	return result
+}
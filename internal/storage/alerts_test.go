@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestAlertTrimKeepsActiveAlerts(t *testing.T) {
+	s := NewStore()
+	s.RaiseAlert("ping:down:192.0.2.1", "ping", "192.0.2.1", SeverityCritical, "down")
+	for i := 0; i < MaxAlerts; i++ {
+		key := "http:check" + strconv.Itoa(i)
+		s.RaiseAlert(key, "http", "check", SeverityWarning, "failed")
+		s.ResolveAlert(key)
+	}
+	if len(s.Alerts) != MaxAlerts {
+		t.Fatalf("%d alerts stored, want %d", len(s.Alerts), MaxAlerts)
+	}
+
+	// The oldest alert is still active, so raising it again is not a new alert
+	s.RaiseAlert("ping:down:192.0.2.1", "ping", "192.0.2.1", SeverityCritical, "still down")
+	if active := s.GetAlerts(true); len(active) != 1 || active[0].ID != 1 || active[0].Message != "still down" {
+		t.Fatalf("active alerts = %+v, want the first alert refreshed", active)
+	}
+	s.ResolveAlert("ping:down:192.0.2.1")
+	if active := s.GetAlerts(true); len(active) != 0 {
+		t.Errorf("active alerts after resolving = %+v", active)
+	}
+}
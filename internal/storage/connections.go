@@ -0,0 +1,67 @@
+package storage
+
+import "time"
+
+// Connection is one TCP or UDP socket from the kernel socket tables
+type Connection struct {
+	Proto         string `json:"proto"`
+	LocalAddress  string `json:"local_address"`
+	LocalPort     int    `json:"local_port"`
+	RemoteAddress string `json:"remote_address"`
+	RemotePort    int    `json:"remote_port"`
+	State         string `json:"state"`
+	TxQueue       uint64 `json:"tx_queue"`
+	RxQueue       uint64 `json:"rx_queue"`
+	UID           int    `json:"uid"`
+	PID           int    `json:"pid,omitempty"` // 0 when the owner is not visible
+	Process       string `json:"process,omitempty"`
+}
+
+// ConnectionStatePoint counts the sockets in each state at one collection
+type ConnectionStatePoint struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Total     int            `json:"total"`
+	States    map[string]int `json:"states"`
+}
+
+// SetConnections replaces the connection table and appends its per-state
+// counts to the history
+func (s *Store) SetConnections(connections []Connection, timestamp time.Time) {
+	point := ConnectionStatePoint{
+		Timestamp: timestamp,
+		Total:     len(connections),
+		States:    make(map[string]int),
+	}
+	for _, conn := range connections {
+		point.States[conn.State]++
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Connections = connections
+	s.ConnectionsUpdated = timestamp
+	s.ConnectionHistory = append(s.ConnectionHistory, point)
+	if len(s.ConnectionHistory) > MaxHistoryPoints {
+		s.ConnectionHistory = s.ConnectionHistory[1:]
+	}
+	s.LastUpdated = timestamp
+}
+
+// GetConnections returns the latest connection table and when it was read
+func (s *Store) GetConnections() ([]Connection, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Connections, s.ConnectionsUpdated
+}
+
+// GetConnectionHistory returns the per-state connection counts over time
+func (s *Store) GetConnectionHistory() []ConnectionStatePoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]ConnectionStatePoint, len(s.ConnectionHistory))
+	copy(result, s.ConnectionHistory)
+	return result
+}
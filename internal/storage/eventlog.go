@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// maxEventLogSize is the size at which the event log file is rotated to
+	// "<path>.1", replacing the previous rotation
+	maxEventLogSize = 16 << 20
+	// eventLogQueue bounds the events waiting to be written; as many as
+	// are held in memory
+	eventLogQueue = MaxEvents
+)
+
+// eventLog persists events as JSON lines. Events are queued by the store
+// and written by a goroutine, so the store never waits on the disk.
+type eventLog struct {
+	path    string
+	queue   chan Event
+	dropped atomic.Int64 // events not queued since the last write
+
+	mu      sync.Mutex // held while writing, rotating or reading the files
+	file    *os.File
+	size    int64
+	failing bool
+}
+
+// OpenEventLog persists events to a JSON lines file at path, loading the
+// most recent ones it already holds so the history survives restarts. It
+// must be called before any events are added.
+func (s *Store) OpenEventLog(path string) error {
+	l := &eventLog{path: path, queue: make(chan Event, eventLogQueue)}
+
+	var events []Event
+	err := l.scan(func(e Event) {
+		events = append(events, e)
+		if len(events) >= 2*MaxEvents {
+			events = append([]Event(nil), events[len(events)-MaxEvents:]...)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(events) > MaxEvents {
+		events = events[len(events)-MaxEvents:]
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.Events = events
+	if len(events) > 0 {
+		s.nextEventID = events[len(events)-1].ID
+	}
+	s.eventLog = l
+	s.mu.Unlock()
+
+	go l.run()
+	return nil
+}
+
+func (l *eventLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// append queues an event for writing. It is called with the store lock
+// held, so when the disk falls behind the event is dropped from the file
+// rather than waited for.
+func (l *eventLog) append(e Event) {
+	select {
+	case l.queue <- e:
+	default:
+		l.dropped.Add(1)
+	}
+}
+
+func (l *eventLog) run() {
+	for e := range l.queue {
+		if n := l.dropped.Swap(0); n > 0 {
+			log.Printf("Event log %s fell behind; %d events were not written", l.path, n)
+		}
+		l.mu.Lock()
+		err := l.write(e)
+		if err != nil && !l.failing {
+			log.Printf("Error writing event log %s: %v", l.path, err)
+		}
+		l.failing = err != nil
+		l.mu.Unlock()
+	}
+}
+
+func (l *eventLog) write(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	var rotateErr error
+	if l.size+int64(len(line)) > maxEventLogSize {
+		l.file.Close()
+		rotateErr = os.Rename(l.path, l.path+".1")
+		// Reopened even if the rename failed, so writing goes on past the
+		// rotation size and the rotation is retried with the next event
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// scan calls fn with the logged events, oldest first. Lines that do not
+// decode, such as one cut short by a crash, are skipped.
+func (l *eventLog) scan(fn func(Event)) error {
+	for _, path := range []string{l.path + ".1", l.path} {
+		file, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var e Event
+			if json.Unmarshal(scanner.Bytes(), &e) == nil && e.ID > 0 {
+				fn(e)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// older returns the logged events matching q with IDs below before, newest
+// first. limit <= 0 returns all of them.
+func (l *eventLog) older(q EventQuery, before, limit int) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var matched []Event
+	err := l.scan(func(e Event) {
+		if e.ID >= before || !q.matches(&e) {
+			return
+		}
+		matched = append(matched, e)
+		// Only the newest limit are kept
+		if limit > 0 && len(matched) >= 2*limit {
+			matched = append([]Event(nil), matched[len(matched)-limit:]...)
+		}
+	})
+	if err != nil {
+		log.Printf("Error reading event log %s: %v", l.path, err)
+	}
+	if limit > 0 && len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+
+	result := make([]Event, 0, len(matched))
+	for i := len(matched) - 1; i >= 0; i-- {
+		result = append(result, matched[i])
+	}
+	return result
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+// MaxEvents caps the events kept in memory; older ones remain queryable
+// from the event log file when one is open
+const MaxEvents = 5000
+
+// Event types
+const (
+	EventDeviceJoined    = "device_joined"
+	EventDeviceLeft      = "device_left"
+	EventInterfaceUp     = "interface_up"
+	EventInterfaceDown   = "interface_down"
+	EventCounterReset    = "counter_reset"
+	EventTargetDown      = "target_down"
+	EventTargetRecovered = "target_recovered"
+	EventAlertFired      = "alert_fired"
+	EventAlertResolved   = "alert_resolved"
+	EventSNMPTrap        = "snmp_trap"
+)
+
+// Event is something that happened at a point in time, such as a device
+// joining the network or a trap received from a switch. Unlike alerts,
+// events are never resolved.
+type Event struct {
+	ID        int               `json:"id"`
+	Timestamp time.Time         `json:"timestamp"`
+	Type      string            `json:"type"`
+	Source    string            `json:"source"`
+	Target    string            `json:"target"`
+	Severity  string            `json:"severity"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+// EventQuery selects events. Empty fields match everything; Text matches
+// the message and field values, ignoring case.
+type EventQuery struct {
+	Type    string
+	Source  string
+	Target  string
+	Text    string
+	Since   time.Time
+	Until   time.Time
+	AfterID int // events newer than this ID, for streaming
+}
+
+func (q EventQuery) matches(e *Event) bool {
+	if q.Type != "" && e.Type != q.Type {
+		return false
+	}
+	if q.Source != "" && e.Source != q.Source {
+		return false
+	}
+	if q.Target != "" && e.Target != q.Target {
+		return false
+	}
+	if !q.Since.IsZero() && e.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Timestamp.After(q.Until) {
+		return false
+	}
+	if e.ID <= q.AfterID {
+		return false
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		if strings.Contains(strings.ToLower(e.Message), text) {
+			return true
+		}
+		for _, value := range e.Fields {
+			if strings.Contains(strings.ToLower(value), text) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// AddEvent records an event and returns it with its ID. A zero timestamp
+// is set to now.
+func (s *Store) AddEvent(event Event) Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addEventLocked(event)
+}
+
+func (s *Store) addEventLocked(event Event) Event {
+	s.nextEventID++
+	event.ID = s.nextEventID
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	s.Events = append(s.Events, event)
+	if excess := len(s.Events) - MaxEvents; excess > 0 {
+		s.Events = append([]Event(nil), s.Events[excess:]...)
+	}
+	if s.eventLog != nil {
+		s.eventLog.append(event)
+	}
+	return event
+}
+
+// addInterfaceEventLocked records a link going up or down
+func (s *Store) addInterfaceEventLocked(name string, up bool, message string, fields map[string]string) {
+	event := Event{
+		Type:     EventInterfaceDown,
+		Source:   "interfaces",
+		Target:   name,
+		Severity: SeverityWarning,
+		Message:  message,
+		Fields:   fields,
+	}
+	if up {
+		event.Type, event.Severity = EventInterfaceUp, SeverityInfo
+	}
+	s.addEventLocked(event)
+}
+
+// GetEvents returns the events matching q, newest first. limit <= 0
+// returns all of them. Events no longer held in memory are read from the
+// event log file.
+func (s *Store) GetEvents(q EventQuery, limit int) []Event {
+	s.mu.RLock()
+	result := s.recentEventsLocked(q, limit)
+	oldest := s.nextEventID + 1
+	if len(s.Events) > 0 {
+		oldest = s.Events[0].ID
+	}
+	eventLog := s.eventLog
+	s.mu.RUnlock()
+
+	// The file is read without holding the store lock
+	if eventLog == nil || oldest-1 <= q.AfterID || (limit > 0 && len(result) == limit) {
+		return result
+	}
+	remaining := 0
+	if limit > 0 {
+		remaining = limit - len(result)
+	}
+	return append(result, eventLog.older(q, oldest, remaining)...)
+}
+
+// NewEvents returns the events held in memory that match q, newest first,
+// and the ID of the newest event whether or not it matches. Streams pass
+// that ID as the next AfterID, so they never read the event log file.
+func (s *Store) NewEvents(q EventQuery) ([]Event, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recentEventsLocked(q, 0), s.nextEventID
+}
+
+// LastEventID returns the ID of the newest event, 0 if there are none
+func (s *Store) LastEventID() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nextEventID
+}
+
+func (s *Store) recentEventsLocked(q EventQuery, limit int) []Event {
+	result := []Event{}
+	for i := len(s.Events) - 1; i >= 0; i-- {
+		// Events are in ID order, so nothing older can match
+		if s.Events[i].ID <= q.AfterID {
+			break
+		}
+		if !q.matches(&s.Events[i]) {
+			continue
+		}
+		result = append(result, s.Events[i])
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
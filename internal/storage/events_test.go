@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventLogReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	s := NewStore()
+	if err := s.OpenEventLog(path); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		s.AddEvent(Event{Type: EventSNMPTrap, Source: "traps", Target: "192.0.2.1", Message: "linkDown"})
+	}
+
+	// The events are written in the background
+	var reloaded *Store
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reloaded = NewStore()
+		if err := reloaded.OpenEventLog(path); err != nil {
+			t.Fatal(err)
+		}
+		if len(reloaded.GetEvents(EventQuery{}, 0)) == 10 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	events := reloaded.GetEvents(EventQuery{}, 0)
+	if len(events) != 10 || events[0].ID != 10 {
+		t.Fatalf("reloaded %d events, newest %+v", len(events), events)
+	}
+	if id := reloaded.AddEvent(Event{Type: EventSNMPTrap}).ID; id != 11 {
+		t.Errorf("next ID after reload = %d, want 11", id)
+	}
+}
+
+func TestNewEvents(t *testing.T) {
+	s := NewStore()
+	s.AddEvent(Event{Type: EventSNMPTrap, Target: "192.0.2.1"})
+	s.AddEvent(Event{Type: EventDeviceJoined, Target: "192.168.1.20"})
+	s.AddEvent(Event{Type: EventDeviceJoined, Target: "192.168.1.21"})
+
+	tests := []struct {
+		name       string
+		q          EventQuery
+		wantIDs    []int
+		wantNewest int
+	}{
+		{"all", EventQuery{}, []int{3, 2, 1}, 3},
+		{"by type", EventQuery{Type: EventDeviceJoined}, []int{3, 2}, 3},
+		// The newest ID advances a stream even when nothing matches
+		{"no match", EventQuery{Type: EventCounterReset}, nil, 3},
+		{"after", EventQuery{AfterID: 2}, []int{3}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, newest := s.NewEvents(tt.q)
+			if newest != tt.wantNewest {
+				t.Errorf("newest = %d, want %d", newest, tt.wantNewest)
+			}
+			if len(events) != len(tt.wantIDs) {
+				t.Fatalf("got %d events, want %d", len(events), len(tt.wantIDs))
+			}
+			for i, id := range tt.wantIDs {
+				if events[i].ID != id {
+					t.Errorf("event %d has ID %d, want %d", i, events[i].ID, id)
+				}
+			}
+		})
+	}
+}
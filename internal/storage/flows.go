@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxFlowRecords caps the flow records kept across all sources
+const MaxFlowRecords = 50000
+
+// FlowRecord is the traffic of one unidirectional 5-tuple over a period.
+// Long-lived flows from live sources are reported as several records, one
+// per export interval.
+type FlowRecord struct {
+	Source   string        `json:"source"` // e.g. "pcap:trace.pcap"
+	Proto    string        `json:"proto"`
+	SrcIP    string        `json:"src_ip"`
+	DstIP    string        `json:"dst_ip"`
+	SrcPort  int           `json:"src_port"`
+	DstPort  int           `json:"dst_port"`
+	SrcMAC   string        `json:"src_mac,omitempty"`
+	DstMAC   string        `json:"dst_mac,omitempty"`
+	Bytes    uint64        `json:"bytes"`
+	Packets  uint64        `json:"packets"`
+	TCPFlags string        `json:"tcp_flags,omitempty"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+}
+
+// FlowQuery selects flow records. Zero fields match everything.
+type FlowQuery struct {
+	Source string // source name, or a kind such as "pcap:" ending in a colon
+	Since  time.Time
+	Until  time.Time
+	IP     string // source or destination address
+	Port   int    // source or destination port
+	Proto  string
+}
+
+func (q FlowQuery) matches(r *FlowRecord) bool {
+	if q.Source != "" && r.Source != q.Source &&
+		!(strings.HasSuffix(q.Source, ":") && strings.HasPrefix(r.Source, q.Source)) {
+		return false
+	}
+	if !q.Since.IsZero() && r.End.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && r.Start.After(q.Until) {
+		return false
+	}
+	if q.IP != "" && r.SrcIP != q.IP && r.DstIP != q.IP {
+		return false
+	}
+	if q.Port != 0 && r.SrcPort != q.Port && r.DstPort != q.Port {
+		return false
+	}
+	if q.Proto != "" && r.Proto != q.Proto {
+		return false
+	}
+	return true
+}
+
+// share returns the part of a matching record's bytes and packets that
+// falls within the query time range, assuming the flow was steady from
+// Start to End. Records for an instant, or within the range, count fully.
+func (q FlowQuery) share(r *FlowRecord) (uint64, uint64) {
+	start, end := r.Start, r.End
+	if !q.Since.IsZero() && start.Before(q.Since) {
+		start = q.Since
+	}
+	if !q.Until.IsZero() && end.After(q.Until) {
+		end = q.Until
+	}
+	span := r.End.Sub(r.Start)
+	if span <= 0 || end.Sub(start) >= span {
+		return r.Bytes, r.Packets
+	}
+	fraction := float64(end.Sub(start)) / float64(span)
+	return uint64(float64(r.Bytes) * fraction), uint64(float64(r.Packets) * fraction)
+}
+
+// TalkerStats is the traffic sent and received by one address
+type TalkerStats struct {
+	IP            string `json:"ip"`
+	MAC           string `json:"mac,omitempty"`
+	BytesSent     uint64 `json:"bytes_sent"`
+	BytesReceived uint64 `json:"bytes_received"`
+	Packets       uint64 `json:"packets"`
+	Flows         int    `json:"flows"`
+}
+
+// ProtocolStats is the traffic of one IP protocol
+type ProtocolStats struct {
+	Proto   string  `json:"proto"`
+	Bytes   uint64  `json:"bytes"`
+	Packets uint64  `json:"packets"`
+	Flows   int     `json:"flows"`
+	Percent float64 `json:"percent"` // share of all bytes
+}
+
+// PortStats is the traffic of one service port
+type PortStats struct {
+	Proto   string `json:"proto"`
+	Port    int    `json:"port"`
+	Bytes   uint64 `json:"bytes"`
+	Packets uint64 `json:"packets"`
+	Flows   int    `json:"flows"`
+}
+
+// DeviceThroughput is the traffic of a known device within a time window
+type DeviceThroughput struct {
+	IP        string  `json:"ip"`
+	MAC       string  `json:"mac,omitempty"`
+	Hostname  string  `json:"hostname,omitempty"`
+	BytesUp   uint64  `json:"bytes_up"`
+	BytesDown uint64  `json:"bytes_down"`
+	RateUp    float64 `json:"rate_up"` // bytes per second over the window
+	RateDown  float64 `json:"rate_down"`
+}
+
+// AddFlows appends flow records, dropping the oldest beyond MaxFlowRecords
+func (s *Store) AddFlows(records []FlowRecord) {
+	if len(records) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range records {
+		r.Duration = r.End.Sub(r.Start)
+		s.Flows = append(s.Flows, r)
+	}
+	if excess := len(s.Flows) - MaxFlowRecords; excess > 0 {
+		s.Flows = append([]FlowRecord(nil), s.Flows[excess:]...)
+	}
+	s.LastUpdated = time.Now()
+}
+
+// GetFlows returns the matching flow records, largest first. A limit of 0
+// returns all of them.
+func (s *Store) GetFlows(q FlowQuery, limit int) []FlowRecord {
+	s.mu.RLock()
+	result := []FlowRecord{}
+	for i := range s.Flows {
+		if q.matches(&s.Flows[i]) {
+			result = append(result, s.Flows[i])
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Bytes > result[j].Bytes })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// GetTopTalkers sums the matching flows per address, busiest first
+func (s *Store) GetTopTalkers(q FlowQuery, limit int) []TalkerStats {
+	s.mu.RLock()
+	talkers := make(map[string]*TalkerStats)
+	// MACs come from the device table; a flow's MAC for an off-link
+	// address is the router's
+	talker := func(ip string) *TalkerStats {
+		t, exists := talkers[ip]
+		if !exists {
+			t = &TalkerStats{IP: ip}
+			talkers[ip] = t
+		}
+		if t.MAC == "" {
+			if device, ok := s.Devices[ip]; ok && device.MAC != "" {
+				t.MAC = device.MAC
+			}
+		}
+		return t
+	}
+	for i := range s.Flows {
+		r := &s.Flows[i]
+		if !q.matches(r) {
+			continue
+		}
+		bytes, packets := q.share(r)
+		src := talker(r.SrcIP)
+		src.BytesSent += bytes
+		src.Packets += packets
+		src.Flows++
+		dst := talker(r.DstIP)
+		dst.BytesReceived += bytes
+		dst.Packets += packets
+		dst.Flows++
+	}
+	s.mu.RUnlock()
+
+	result := make([]TalkerStats, 0, len(talkers))
+	for _, t := range talkers {
+		result = append(result, *t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].BytesSent+result[i].BytesReceived, result[j].BytesSent+result[j].BytesReceived
+		if a != b {
+			return a > b
+		}
+		return result[i].IP < result[j].IP
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// GetProtocolBreakdown sums the matching flows per IP protocol
+func (s *Store) GetProtocolBreakdown(q FlowQuery) []ProtocolStats {
+	s.mu.RLock()
+	protocols := make(map[string]*ProtocolStats)
+	var total uint64
+	for i := range s.Flows {
+		r := &s.Flows[i]
+		if !q.matches(r) {
+			continue
+		}
+		p, exists := protocols[r.Proto]
+		if !exists {
+			p = &ProtocolStats{Proto: r.Proto}
+			protocols[r.Proto] = p
+		}
+		bytes, packets := q.share(r)
+		p.Bytes += bytes
+		p.Packets += packets
+		p.Flows++
+		total += bytes
+	}
+	s.mu.RUnlock()
+
+	result := make([]ProtocolStats, 0, len(protocols))
+	for _, p := range protocols {
+		if total > 0 {
+			p.Percent = float64(p.Bytes) / float64(total) * 100
+		}
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Bytes > result[j].Bytes })
+	return result
+}
+
+// GetTopPorts sums the matching flows per service port, taken as the lower
+// of the two ports, busiest first
+func (s *Store) GetTopPorts(q FlowQuery, limit int) []PortStats {
+	type portKey struct {
+		proto string
+		port  int
+	}
+
+	s.mu.RLock()
+	ports := make(map[portKey]*PortStats)
+	for i := range s.Flows {
+		r := &s.Flows[i]
+		if !q.matches(r) || (r.Proto != "tcp" && r.Proto != "udp") {
+			continue
+		}
+		port := r.DstPort
+		if r.SrcPort != 0 && r.SrcPort < port {
+			port = r.SrcPort
+		}
+		key := portKey{r.Proto, port}
+		p, exists := ports[key]
+		if !exists {
+			p = &PortStats{Proto: r.Proto, Port: port}
+			ports[key] = p
+		}
+		bytes, packets := q.share(r)
+		p.Bytes += bytes
+		p.Packets += packets
+		p.Flows++
+	}
+	s.mu.RUnlock()
+
+	result := make([]PortStats, 0, len(ports))
+	for _, p := range ports {
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Bytes > result[j].Bytes })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// GetDeviceThroughput sums the traffic of each known device within the
+// query window, which must have a Since time, and averages it over the
+// window
+func (s *Store) GetDeviceThroughput(q FlowQuery) []DeviceThroughput {
+	until := q.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+	seconds := until.Sub(q.Since).Seconds()
+
+	s.mu.RLock()
+	devices := make(map[string]*DeviceThroughput)
+	device := func(ip string) *DeviceThroughput {
+		d, exists := devices[ip]
+		if !exists {
+			known, ok := s.Devices[ip]
+			if !ok {
+				return nil
+			}
+			d = &DeviceThroughput{IP: ip, MAC: known.MAC, Hostname: known.Hostname}
+			devices[ip] = d
+		}
+		return d
+	}
+	for i := range s.Flows {
+		r := &s.Flows[i]
+		if !q.matches(r) {
+			continue
+		}
+		bytes, _ := q.share(r)
+		if d := device(r.SrcIP); d != nil {
+			d.BytesUp += bytes
+		}
+		if d := device(r.DstIP); d != nil {
+			d.BytesDown += bytes
+		}
+	}
+	s.mu.RUnlock()
+
+	result := make([]DeviceThroughput, 0, len(devices))
+	for _, d := range devices {
+		if seconds > 0 {
+			d.RateUp = float64(d.BytesUp) / seconds
+			d.RateDown = float64(d.BytesDown) / seconds
+		}
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BytesUp+result[i].BytesDown > result[j].BytesUp+result[j].BytesDown
+	})
+	return result
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlowsProratedToWindow(t *testing.T) {
+	s := NewStore()
+	s.UpdateDevice("10.0.0.5", "aa:bb:cc:dd:ee:ff", "laptop")
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.AddFlows([]FlowRecord{
+		// A 10 minute download, 6 MB
+		{Source: "pcap:test", Proto: "tcp", SrcIP: "192.0.2.1", DstIP: "10.0.0.5", SrcPort: 443, DstPort: 50000,
+			Bytes: 6000000, Packets: 6000, Start: start, End: start.Add(10 * time.Minute)},
+		// A single packet inside the window
+		{Source: "pcap:test", Proto: "udp", SrcIP: "10.0.0.5", DstIP: "192.0.2.53", SrcPort: 50001, DstPort: 53,
+			Bytes: 100, Packets: 1, Start: start.Add(9 * time.Minute), End: start.Add(9 * time.Minute)},
+	})
+
+	// The last minute holds a tenth of the download
+	q := FlowQuery{Since: start.Add(9 * time.Minute), Until: start.Add(10 * time.Minute)}
+
+	devices := s.GetDeviceThroughput(q)
+	if len(devices) != 1 || devices[0].BytesDown != 600000 || devices[0].BytesUp != 100 {
+		t.Fatalf("device throughput = %+v, want 600000 down and 100 up", devices)
+	}
+	if rate := devices[0].RateDown; rate != 10000 {
+		t.Errorf("RateDown = %.0f B/s, want 10000", rate)
+	}
+
+	talkers := s.GetTopTalkers(q, 0)
+	if len(talkers) == 0 || talkers[0].IP != "10.0.0.5" || talkers[0].BytesReceived != 600000 || talkers[0].Packets != 601 {
+		t.Errorf("top talker = %+v, want 10.0.0.5 with 600000 bytes received in 601 packets", talkers)
+	}
+
+	protocols := s.GetProtocolBreakdown(q)
+	if len(protocols) != 2 || protocols[0].Proto != "tcp" || protocols[0].Bytes != 600000 {
+		t.Errorf("protocols = %+v, want tcp with 600000 bytes first", protocols)
+	}
+
+	ports := s.GetTopPorts(q, 1)
+	if len(ports) != 1 || ports[0].Port != 443 || ports[0].Bytes != 600000 {
+		t.Errorf("top port = %+v, want 443 with 600000 bytes", ports)
+	}
+
+	// Without a time range every record counts in full
+	if protocols := s.GetProtocolBreakdown(FlowQuery{}); protocols[0].Bytes != 6000000 {
+		t.Errorf("unbounded protocols = %+v, want all 6000000 tcp bytes", protocols)
+	}
+}
@@ -0,0 +1,88 @@
+package storage
+
+import "sort"
+
+// InterfaceGroupStats aggregates the traffic of the interfaces in a group
+type InterfaceGroupStats struct {
+	Name        string   `json:"name"`
+	Interfaces  []string `json:"interfaces"`
+	BytesRx     uint64   `json:"bytes_rx"`
+	BytesTx     uint64   `json:"bytes_tx"`
+	PacketsRx   uint64   `json:"packets_rx"`
+	PacketsTx   uint64   `json:"packets_tx"`
+	SpeedRx     float64  `json:"speed_rx"`
+	SpeedTx     float64  `json:"speed_tx"`
+	ErrorRateRx float64  `json:"error_rate_rx"`
+	ErrorRateTx float64  `json:"error_rate_tx"`
+	DropRateRx  float64  `json:"drop_rate_rx"`
+	DropRateTx  float64  `json:"drop_rate_tx"`
+}
+
+// SetInterfaceGroups records the current members of each interface group
+// and the interfaces counted toward the traffic totals. A nil totals list
+// counts every interface.
+func (s *Store) SetInterfaceGroups(groups map[string][]string, totals []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.InterfaceGroups = groups
+	s.TotalInterfaces = totals
+}
+
+// GetInterfaceGroups returns the aggregated stats of every interface group
+func (s *Store) GetInterfaceGroups() map[string]*InterfaceGroupStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]*InterfaceGroupStats, len(s.InterfaceGroups))
+	for name, members := range s.InterfaceGroups {
+		group := &InterfaceGroupStats{Name: name, Interfaces: members}
+		for _, member := range members {
+			iface, exists := s.Interfaces[member]
+			if !exists {
+				continue
+			}
+			group.BytesRx += iface.BytesRx
+			group.BytesTx += iface.BytesTx
+			group.PacketsRx += iface.PacketsRx
+			group.PacketsTx += iface.PacketsTx
+			group.SpeedRx += iface.SpeedRx
+			group.SpeedTx += iface.SpeedTx
+			group.ErrorRateRx += iface.ErrorRateRx
+			group.ErrorRateTx += iface.ErrorRateTx
+			group.DropRateRx += iface.DropRateRx
+			group.DropRateTx += iface.DropRateTx
+		}
+		result[name] = group
+	}
+	return result
+}
+
+// GetTrafficTotals sums the current speeds of the interfaces selected for
+// the totals, and returns which interfaces those were. Unless selected
+// explicitly, only local interfaces are counted.
+func (s *Store) GetTrafficTotals() (float64, float64, []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := s.TotalInterfaces
+	if names == nil {
+		names = make([]string, 0, len(s.Interfaces))
+		for name, iface := range s.Interfaces {
+			// Ports of switches and routers are not this host's traffic
+			if iface.Remote == nil {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+
+	var totalRx, totalTx float64
+	for _, name := range names {
+		if iface, exists := s.Interfaces[name]; exists {
+			totalRx += iface.SpeedRx
+			totalTx += iface.SpeedTx
+		}
+	}
+	return totalRx, totalTx, names
+}
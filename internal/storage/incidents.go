@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MaxIncidents = 200
+	// IncidentFailures is the number of consecutive failed probes after
+	// which a target is considered down
+	IncidentFailures = 3
+)
+
+// Incident is a period during which ping targets were unreachable, such as
+// an ISP outage. Targets failing while the gateway is down are part of the
+// gateway's incident rather than incidents of their own.
+type Incident struct {
+	ID       int             `json:"id"`
+	Summary  string          `json:"summary"` // e.g. "gateway 192.168.1.1 unreachable"
+	Targets  []string        `json:"targets"`
+	Gateway  bool            `json:"gateway"` // the default gateway was unreachable
+	Active   bool            `json:"active"`
+	Start    time.Time       `json:"start"` // first failed probe
+	End      *time.Time      `json:"end,omitempty"`
+	Duration time.Duration   `json:"duration"` // up to now while active
+	Timeline []IncidentEntry `json:"timeline"`
+
+	gateway string          // the gateway target, for gateway incidents
+	down    map[string]bool // targets still unreachable
+}
+
+// IncidentEntry is a target of an incident going down or coming back
+type IncidentEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Target    string    `json:"target"`
+	State     string    `json:"state"` // "down", "up" or "removed" once no longer probed
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// IncidentQuery selects incidents overlapping a time range
+type IncidentQuery struct {
+	ActiveOnly bool
+	Since      time.Time
+	Until      time.Time
+}
+
+// trackIncidentLocked follows the failure streak of a target after each
+// probe, opening an incident once it is down and closing it once all of
+// its targets are back. Callers must hold s.mu.
+func (s *Store) trackIncidentLocked(ping *PingStats, success bool, now time.Time) {
+	host := ping.Host
+	if success {
+		if incident := s.incidentForLocked(host); incident != nil {
+			incident.Timeline = append(incident.Timeline, IncidentEntry{Timestamp: now, Target: host, State: "up"})
+			delete(incident.down, host)
+			if len(incident.down) == 0 {
+				incident.close(now)
+			}
+		}
+		ping.FailStreak = 0
+		ping.DownSince = nil
+		return
+	}
+
+	ping.FailStreak++
+	if ping.FailStreak == 1 {
+		ping.DownSince = &now
+	}
+	if ping.FailStreak != IncidentFailures {
+		return
+	}
+
+	down := IncidentEntry{Timestamp: *ping.DownSince, Target: host, State: "down", Detail: ping.LastError}
+	gateways := s.gatewaysLocked()
+	if gateways[host] {
+		// Every open incident is part of the gateway outage
+		incident := s.openIncidentLocked(down)
+		incident.Gateway = true
+		incident.gateway = host
+		for i := 0; i < len(s.Incidents); i++ {
+			other := s.Incidents[i]
+			if other == incident || !other.Active {
+				continue
+			}
+			incident.absorb(other)
+			s.Incidents = append(s.Incidents[:i], s.Incidents[i+1:]...)
+			i--
+		}
+		incident.summarize()
+		return
+	}
+	for _, incident := range s.Incidents {
+		if incident.Active && incident.Gateway && incident.down[incident.gateway] {
+			incident.add(down)
+			incident.summarize()
+			return
+		}
+	}
+	s.openIncidentLocked(down).summarize()
+}
+
+// SetPingTargets tells the store which targets are still probed. Targets
+// dropped, such as a gateway replaced during an outage, would never come
+// back up, so they are taken out of the active incidents.
+func (s *Store) SetPingTargets(keys []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	probed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		probed[key] = true
+	}
+	now := time.Now()
+	for _, incident := range s.Incidents {
+		if !incident.Active {
+			continue
+		}
+		for _, host := range incident.Targets {
+			if !incident.down[host] || probed[host] {
+				continue
+			}
+			incident.Timeline = append(incident.Timeline, IncidentEntry{Timestamp: now, Target: host, State: "removed", Detail: "no longer probed"})
+			delete(incident.down, host)
+		}
+		if len(incident.down) == 0 {
+			incident.close(now)
+		}
+	}
+	for host, ping := range s.PingResults {
+		if !probed[host] {
+			ping.FailStreak = 0
+			ping.DownSince = nil
+		}
+	}
+}
+
+// openIncidentLocked starts an incident with its first target down
+func (s *Store) openIncidentLocked(down IncidentEntry) *Incident {
+	s.nextIncidentID++
+	incident := &Incident{
+		ID:     s.nextIncidentID,
+		Active: true,
+		Start:  down.Timestamp,
+		down:   make(map[string]bool),
+	}
+	incident.add(down)
+	s.Incidents = append(s.Incidents, incident)
+	if len(s.Incidents) > MaxIncidents {
+		// Closed incidents go first; an active one still has to be closed
+		i := slices.IndexFunc(s.Incidents, func(incident *Incident) bool { return !incident.Active })
+		if i < 0 {
+			i = 0
+		}
+		s.Incidents = slices.Delete(s.Incidents, i, i+1)
+	}
+	return incident
+}
+
+// incidentForLocked returns the active incident a target is down in
+func (s *Store) incidentForLocked(host string) *Incident {
+	for i := len(s.Incidents) - 1; i >= 0; i-- {
+		if s.Incidents[i].Active && s.Incidents[i].down[host] {
+			return s.Incidents[i]
+		}
+	}
+	return nil
+}
+
+// gatewaysLocked returns the addresses of the default gateways
+func (s *Store) gatewaysLocked() map[string]bool {
+	gateways := make(map[string]bool)
+	for _, route := range s.DefaultRoutes {
+		if route.Gateway != "" {
+			gateways[route.Gateway] = true
+		}
+	}
+	return gateways
+}
+
+func (i *Incident) add(down IncidentEntry) {
+	i.down[down.Target] = true
+	if !slices.Contains(i.Targets, down.Target) {
+		i.Targets = append(i.Targets, down.Target)
+	}
+	i.Timeline = append(i.Timeline, down)
+	if down.Timestamp.Before(i.Start) {
+		i.Start = down.Timestamp
+	}
+}
+
+func (i *Incident) close(now time.Time) {
+	i.Active = false
+	i.End = &now
+	i.Duration = now.Sub(i.Start)
+}
+
+// absorb merges another active incident into this one
+func (i *Incident) absorb(other *Incident) {
+	for host := range other.down {
+		i.down[host] = true
+	}
+	for _, host := range other.Targets {
+		if !slices.Contains(i.Targets, host) {
+			i.Targets = append(i.Targets, host)
+		}
+	}
+	i.Timeline = append(i.Timeline, other.Timeline...)
+	sort.SliceStable(i.Timeline, func(a, b int) bool {
+		return i.Timeline[a].Timestamp.Before(i.Timeline[b].Timestamp)
+	})
+	if other.Start.Before(i.Start) {
+		i.Start = other.Start
+	}
+}
+
+func (i *Incident) summarize() {
+	switch {
+	case i.Gateway && len(i.Targets) > 1:
+		i.Summary = fmt.Sprintf("gateway %s unreachable, with %d other targets", i.Targets[0], len(i.Targets)-1)
+	case i.Gateway:
+		i.Summary = fmt.Sprintf("gateway %s unreachable", i.Targets[0])
+	default:
+		i.Summary = strings.Join(i.Targets, ", ") + " unreachable"
+	}
+}
+
+// GetIncidents returns copies of the incidents matching q, newest first
+func (s *Store) GetIncidents(q IncidentQuery) []Incident {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	result := []Incident{}
+	for i := len(s.Incidents) - 1; i >= 0; i-- {
+		incident := s.Incidents[i]
+		if q.ActiveOnly && !incident.Active {
+			continue
+		}
+		if !q.Until.IsZero() && incident.Start.After(q.Until) {
+			continue
+		}
+		if !q.Since.IsZero() && incident.End != nil && incident.End.Before(q.Since) {
+			continue
+		}
+		c := *incident
+		c.Targets = append([]string(nil), incident.Targets...)
+		c.Timeline = append([]IncidentEntry(nil), incident.Timeline...)
+		c.down = nil
+		if c.Active {
+			c.Duration = now.Sub(c.Start)
+		}
+		result = append(result, c)
+	}
+	return result
+}
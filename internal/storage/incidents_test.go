@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+)
+
+// probe records the same outcome for host enough times to change its state
+func probe(s *Store, host string, success bool) {
+	for i := 0; i < IncidentFailures; i++ {
+		s.StoreProbeResult(host, PingSample{Success: success, Method: "icmp"})
+	}
+}
+
+func TestIncidentGatewayReplacedDuringOutage(t *testing.T) {
+	s := NewStore()
+	s.SetDefaultRoutes([]Route{{Family: "ipv4", Destination: "0.0.0.0/0", Gateway: "192.168.1.1", Interface: "eth0"}})
+	s.SetPingTargets([]string{"192.168.1.1", "8.8.8.8"})
+
+	probe(s, "8.8.8.8", false)
+	probe(s, "192.168.1.1", false)
+	incidents := s.GetIncidents(IncidentQuery{ActiveOnly: true})
+	if len(incidents) != 1 || !incidents[0].Gateway || len(incidents[0].Targets) != 2 {
+		t.Fatalf("gateway outage = %+v, want one gateway incident of both targets", incidents)
+	}
+
+	// The gateway changes and the old one is no longer probed
+	s.SetDefaultRoutes([]Route{{Family: "ipv4", Destination: "0.0.0.0/0", Gateway: "10.0.0.1", Interface: "wlan0"}})
+	s.SetPingTargets([]string{"10.0.0.1", "8.8.8.8"})
+	probe(s, "8.8.8.8", true)
+	if active := s.GetIncidents(IncidentQuery{ActiveOnly: true}); len(active) != 0 {
+		t.Fatalf("incidents still active after the old gateway was dropped: %+v", active)
+	}
+
+	// Later failures are incidents of their own
+	probe(s, "1.1.1.1", false)
+	active := s.GetIncidents(IncidentQuery{ActiveOnly: true})
+	if len(active) != 1 || active[0].Gateway || !slices.Equal(active[0].Targets, []string{"1.1.1.1"}) {
+		t.Fatalf("later failure = %+v, want its own incident", active)
+	}
+}
+
+func TestIncidentTargetsFlapping(t *testing.T) {
+	s := NewStore()
+	s.SetDefaultRoutes([]Route{{Family: "ipv4", Destination: "0.0.0.0/0", Gateway: "192.168.1.1", Interface: "eth0"}})
+
+	probe(s, "192.168.1.1", false)
+	probe(s, "8.8.8.8", false)
+	probe(s, "8.8.8.8", true)
+	probe(s, "8.8.8.8", false)
+
+	incidents := s.GetIncidents(IncidentQuery{})
+	if len(incidents) != 1 {
+		t.Fatalf("got %d incidents, want 1", len(incidents))
+	}
+	if want := []string{"192.168.1.1", "8.8.8.8"}; !slices.Equal(incidents[0].Targets, want) {
+		t.Errorf("targets = %v, want %v", incidents[0].Targets, want)
+	}
+	if len(incidents[0].Timeline) != 4 {
+		t.Errorf("timeline has %d entries, want 4", len(incidents[0].Timeline))
+	}
+
+	probe(s, "192.168.1.1", true)
+	probe(s, "8.8.8.8", true)
+	if incidents := s.GetIncidents(IncidentQuery{ActiveOnly: true}); len(incidents) != 0 {
+		t.Errorf("incidents still active after recovery: %+v", incidents)
+	}
+}
+
+func TestIncidentTrimKeepsActiveIncidents(t *testing.T) {
+	s := NewStore()
+	s.SetPingTargets([]string{"192.0.2.1", "192.0.2.2"})
+	probe(s, "192.0.2.1", false)
+	for i := 0; i < MaxIncidents; i++ {
+		probe(s, "192.0.2.2", false)
+		probe(s, "192.0.2.2", true)
+	}
+	if len(s.Incidents) != MaxIncidents {
+		t.Fatalf("%d incidents stored, want %d", len(s.Incidents), MaxIncidents)
+	}
+
+	// The long outage is still tracked, so its recovery closes it
+	probe(s, "192.0.2.1", true)
+	if active := s.GetIncidents(IncidentQuery{ActiveOnly: true}); len(active) != 0 {
+		t.Fatalf("incidents still active after recovery: %+v", active)
+	}
+	if first := s.Incidents[0]; first.ID != 1 || first.End == nil {
+		t.Errorf("oldest incident = %+v, want the first one, closed", first)
+	}
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxLogEntries caps the syslog messages kept across all hosts
+	MaxLogEntries = 10000
+	// MaxLogHosts caps the senders summarized; the least recent go first
+	MaxLogHosts = 1024
+)
+
+// LogEntry is a syslog message received from a network device
+type LogEntry struct {
+	ID             int       `json:"id"`
+	Received       time.Time `json:"received"`
+	Timestamp      time.Time `json:"timestamp"` // as sent, or the receive time
+	Host           string    `json:"host"`      // sender address
+	Hostname       string    `json:"hostname,omitempty"`
+	Facility       string    `json:"facility"`
+	Severity       int       `json:"severity"` // 0 (emerg) to 7 (debug)
+	Level          string    `json:"level"`    // severity keyword, e.g. "err"
+	App            string    `json:"app,omitempty"`
+	ProcID         string    `json:"proc_id,omitempty"`
+	MsgID          string    `json:"msg_id,omitempty"`
+	StructuredData string    `json:"structured_data,omitempty"`
+	Message        string    `json:"message"`
+	// Device is the known device with the sender's address
+	Device *LogDevice `json:"device,omitempty"`
+}
+
+// LogDevice identifies the device a log entry was correlated with
+type LogDevice struct {
+	IP       string `json:"ip"`
+	MAC      string `json:"mac"`
+	Hostname string `json:"hostname,omitempty"`
+	Vendor   string `json:"vendor,omitempty"`
+}
+
+// LogHost summarizes the messages received from one sender
+type LogHost struct {
+	Host       string         `json:"host"`
+	Hostname   string         `json:"hostname,omitempty"`
+	Device     *LogDevice     `json:"device,omitempty"`
+	Count      int            `json:"count"`
+	Severities map[string]int `json:"severities"` // by level, over all messages received
+	LastSeen   time.Time      `json:"last_seen"`
+}
+
+// LogQuery selects log entries. Zero fields match everything; Text matches
+// the message, app and message ID, ignoring case.
+type LogQuery struct {
+	Host    string   // sender address or hostname
+	Levels  []string // any of these severity keywords
+	App     string
+	Text    string
+	Since   time.Time
+	Until   time.Time
+	AfterID int // entries newer than this ID, for tailing
+}
+
+func (q LogQuery) matches(e *LogEntry) bool {
+	if q.Host != "" && e.Host != q.Host && !strings.EqualFold(e.Hostname, q.Host) {
+		return false
+	}
+	if len(q.Levels) > 0 && !slices.Contains(q.Levels, e.Level) {
+		return false
+	}
+	if q.App != "" && !strings.EqualFold(e.App, q.App) {
+		return false
+	}
+	if !q.Since.IsZero() && e.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Timestamp.After(q.Until) {
+		return false
+	}
+	if e.ID <= q.AfterID {
+		return false
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		return strings.Contains(strings.ToLower(e.Message), text) ||
+			strings.Contains(strings.ToLower(e.App), text) ||
+			strings.Contains(strings.ToLower(e.MsgID), text)
+	}
+	return true
+}
+
+// AddLogEntry stores a syslog message, correlating its sender with the
+// known devices, and returns it with its ID
+func (s *Store) AddLogEntry(entry LogEntry) LogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextLogID++
+	entry.ID = s.nextLogID
+	if entry.Received.IsZero() {
+		entry.Received = time.Now()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = entry.Received
+	}
+	entry.Device = s.logDeviceLocked(entry.Host, entry.Hostname)
+
+	s.Logs = append(s.Logs, entry)
+	if excess := len(s.Logs) - MaxLogEntries; excess > 0 {
+		s.Logs = append([]LogEntry(nil), s.Logs[excess:]...)
+	}
+
+	host, exists := s.LogHosts[entry.Host]
+	if !exists {
+		if len(s.LogHosts) >= MaxLogHosts {
+			s.evictLogHostLocked()
+		}
+		host = &LogHost{Host: entry.Host, Severities: make(map[string]int)}
+		s.LogHosts[entry.Host] = host
+	}
+	if entry.Hostname != "" {
+		host.Hostname = entry.Hostname
+	}
+	host.Device = entry.Device
+	host.Count++
+	host.Severities[entry.Level]++
+	host.LastSeen = entry.Received
+	return entry
+}
+
+// evictLogHostLocked drops the sender heard from least recently
+func (s *Store) evictLogHostLocked() {
+	oldest := ""
+	for addr, host := range s.LogHosts {
+		if oldest == "" || host.LastSeen.Before(s.LogHosts[oldest].LastSeen) {
+			oldest = addr
+		}
+	}
+	delete(s.LogHosts, oldest)
+}
+
+// logDeviceLocked finds the device a sender is, by address and failing
+// that by hostname
+func (s *Store) logDeviceLocked(ip, hostname string) *LogDevice {
+	device, exists := s.Devices[ip]
+	if !exists && hostname != "" {
+		for _, d := range s.Devices {
+			if d.Hostname != "" && (strings.EqualFold(d.Hostname, hostname) ||
+				strings.HasPrefix(strings.ToLower(d.Hostname), strings.ToLower(hostname)+".")) {
+				device = d
+				break
+			}
+		}
+	}
+	if device == nil {
+		return nil
+	}
+	return &LogDevice{IP: device.IP, MAC: device.MAC, Hostname: device.Hostname, Vendor: device.Vendor}
+}
+
+// GetLogs returns the entries matching q, newest first. limit <= 0 returns
+// all of them.
+func (s *Store) GetLogs(q LogQuery, limit int) []LogEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recentLogsLocked(q, limit)
+}
+
+// NewLogs is GetLogs for tails: it also returns the ID of the newest entry
+// whether or not it matches, to pass as the next AfterID so a filtered
+// tail does not scan the same entries again
+func (s *Store) NewLogs(q LogQuery, limit int) ([]LogEntry, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recentLogsLocked(q, limit), s.nextLogID
+}
+
+func (s *Store) recentLogsLocked(q LogQuery, limit int) []LogEntry {
+	result := []LogEntry{}
+	for i := len(s.Logs) - 1; i >= 0; i-- {
+		// Entries are in ID order, so nothing older can match a tail
+		if s.Logs[i].ID <= q.AfterID {
+			break
+		}
+		if !q.matches(&s.Logs[i]) {
+			continue
+		}
+		result = append(result, s.Logs[i])
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
+
+// GetLogHosts returns the senders of syslog messages, most recent first
+func (s *Store) GetLogHosts() []LogHost {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hosts := make([]LogHost, 0, len(s.LogHosts))
+	for _, h := range s.LogHosts {
+		host := *h
+		host.Severities = make(map[string]int, len(h.Severities))
+		for level, count := range h.Severities {
+			host.Severities[level] = count
+		}
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].LastSeen.After(hosts[j].LastSeen)
+	})
+	return hosts
+}
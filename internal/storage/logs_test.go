@@ -0,0 +1,41 @@
+package storage
+
+import "testing"
+
+func TestNewLogs(t *testing.T) {
+	s := NewStore()
+	s.AddLogEntry(LogEntry{Host: "192.0.2.1", Level: "err", Message: "link down"})
+	s.AddLogEntry(LogEntry{Host: "192.0.2.2", Level: "info", Message: "login"})
+	s.AddLogEntry(LogEntry{Host: "192.0.2.1", Level: "info", Message: "link up"})
+
+	tests := []struct {
+		name       string
+		q          LogQuery
+		limit      int
+		wantIDs    []int
+		wantNewest int
+	}{
+		{"all", LogQuery{}, 0, []int{3, 2, 1}, 3},
+		{"by host", LogQuery{Host: "192.0.2.1"}, 0, []int{3, 1}, 3},
+		{"limit", LogQuery{}, 1, []int{3}, 3},
+		// The newest ID advances a tail even when nothing matches
+		{"no match", LogQuery{Levels: []string{"crit"}}, 0, nil, 3},
+		{"after", LogQuery{AfterID: 2}, 0, []int{3}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, newest := s.NewLogs(tt.q, tt.limit)
+			if newest != tt.wantNewest {
+				t.Errorf("newest = %d, want %d", newest, tt.wantNewest)
+			}
+			if len(logs) != len(tt.wantIDs) {
+				t.Fatalf("got %d entries, want %d", len(logs), len(tt.wantIDs))
+			}
+			for i, id := range tt.wantIDs {
+				if logs[i].ID != id {
+					t.Errorf("entry %d has ID %d, want %d", i, logs[i].ID, id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"slices"
+	"time"
+)
+
+// MTRHop accumulates latency and loss for one router at one TTL of a
+// continuously probed path. When the path changes or is load balanced a
+// TTL has one entry per router that answered at it.
+type MTRHop struct {
+	TTL      int           `json:"ttl"`
+	Address  string        `json:"address"`
+	Sent     int           `json:"sent"`
+	Lost     int           `json:"lost"`
+	Loss     float64       `json:"loss"`
+	Last     time.Duration `json:"last"`
+	Avg      time.Duration `json:"avg"`
+	Best     time.Duration `json:"best"`
+	Worst    time.Duration `json:"worst"`
+	LastSeen time.Time     `json:"last_seen"`
+	History  []PingPoint   `json:"history"`
+
+	total time.Duration
+}
+
+// MTRStats holds the per-hop statistics of a target probed MTR-style
+type MTRStats struct {
+	Target      string    `json:"target"`
+	Address     string    `json:"address"`
+	Rounds      int       `json:"rounds"`
+	Hops        []*MTRHop `json:"hops"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// StoreMTRRound folds a single-probe-per-hop trace into the target's
+// per-hop statistics
+func (s *Store) StoreMTRRound(trace TraceRoute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, exists := s.MTR[trace.Target]
+	if !exists {
+		stats = &MTRStats{Target: trace.Target, Hops: []*MTRHop{}}
+		s.MTR[trace.Target] = stats
+	}
+	stats.Address = trace.Address
+	stats.Rounds++
+
+	for _, result := range trace.Hops {
+		hop := stats.hopFor(result.TTL, result.Address)
+		if result.Address != "" {
+			hop.Address = result.Address
+			hop.LastSeen = trace.StartedAt
+		}
+
+		hop.Sent += result.Sent
+		hop.Lost += result.Sent - result.Received
+		hop.Loss = float64(hop.Lost) / float64(hop.Sent) * 100
+
+		success := len(result.RTTs) > 0
+		var latency time.Duration
+		for _, rtt := range result.RTTs {
+			latency = rtt
+			hop.total += rtt
+			if hop.Best == 0 || rtt < hop.Best {
+				hop.Best = rtt
+			}
+			if rtt > hop.Worst {
+				hop.Worst = rtt
+			}
+		}
+		if received := hop.Sent - hop.Lost; received > 0 {
+			hop.Avg = hop.total / time.Duration(received)
+		}
+		if success {
+			hop.Last = latency
+		}
+
+		hop.History = append(hop.History, PingPoint{
+			Timestamp: trace.StartedAt,
+			Latency:   latency,
+			Success:   success,
+			Method:    "MTR",
+		})
+		if len(hop.History) > MaxHistoryPoints {
+			hop.History = hop.History[1:]
+		}
+	}
+
+	// Drop hops past the destination if the path got shorter
+	if trace.Reached {
+		stats.Hops = slices.DeleteFunc(stats.Hops, func(hop *MTRHop) bool {
+			return hop.TTL > len(trace.Hops)
+		})
+	}
+
+	stats.LastUpdated = time.Now()
+	s.LastUpdated = stats.LastUpdated
+}
+
+// hopFor returns the entry of the router at ttl. A lost probe names no
+// router and counts against the one that answered at that TTL most
+// recently.
+func (m *MTRStats) hopFor(ttl int, address string) *MTRHop {
+	var latest *MTRHop
+	insert := len(m.Hops)
+	for i, hop := range m.Hops {
+		if hop.TTL > ttl {
+			insert = i
+			break
+		}
+		if hop.TTL != ttl {
+			continue
+		}
+		if hop.Address == address {
+			return hop
+		}
+		if latest == nil || hop.LastSeen.After(latest.LastSeen) {
+			latest = hop
+		}
+	}
+	switch {
+	case address == "" && latest != nil:
+		return latest
+	case address != "" && latest != nil && latest.Address == "":
+		// A TTL that had only timed out so far gets its first router
+		return latest
+	}
+
+	hop := &MTRHop{TTL: ttl, Address: address, History: []PingPoint{}}
+	m.Hops = slices.Insert(m.Hops, insert, hop)
+	return hop
+}
+
+func (s *Store) GetMTR() map[string]*MTRStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]*MTRStats)
+	for k, v := range s.MTR {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+var mtrRounds = time.Now()
+
+// mtrRound builds a one-probe-per-hop trace through the given routers, a
+// second after the previous one; an empty address is a lost probe
+func mtrRound(addresses ...string) TraceRoute {
+	mtrRounds = mtrRounds.Add(time.Second)
+	trace := TraceRoute{Target: "example.com", Address: "192.0.2.9", StartedAt: mtrRounds, Reached: true}
+	for i, address := range addresses {
+		hop := TraceHop{TTL: i + 1, Address: address, Sent: 1}
+		if address != "" {
+			hop.Received = 1
+			hop.RTTs = []time.Duration{time.Duration(i+1) * time.Millisecond}
+		}
+		trace.Hops = append(trace.Hops, hop)
+	}
+	return trace
+}
+
+func TestMTRHopsKeyedByRouter(t *testing.T) {
+	s := NewStore()
+	s.StoreMTRRound(mtrRound("10.0.0.1", "", "192.0.2.9"))
+	s.StoreMTRRound(mtrRound("10.0.0.1", "198.51.100.1", "192.0.2.9"))
+	// The path changes at the second hop
+	s.StoreMTRRound(mtrRound("10.0.0.1", "203.0.113.1", "192.0.2.9"))
+	s.StoreMTRRound(mtrRound("10.0.0.1", "", "192.0.2.9"))
+
+	type key struct {
+		ttl     int
+		address string
+	}
+	want := []struct {
+		key        key
+		sent, lost int
+	}{
+		{key{1, "10.0.0.1"}, 4, 0},
+		// The first lost probe predates any router at TTL 2
+		{key{2, "198.51.100.1"}, 2, 1},
+		{key{2, "203.0.113.1"}, 2, 1},
+		{key{3, "192.0.2.9"}, 4, 0},
+	}
+	hops := s.GetMTR()["example.com"].Hops
+	if len(hops) != len(want) {
+		t.Fatalf("got %d hops, want %d", len(hops), len(want))
+	}
+	for i, w := range want {
+		hop := hops[i]
+		if (key{hop.TTL, hop.Address}) != w.key || hop.Sent != w.sent || hop.Lost != w.lost {
+			t.Errorf("hop %d = TTL %d %s sent %d lost %d, want %+v", i, hop.TTL, hop.Address, hop.Sent, hop.Lost, w)
+		}
+	}
+
+	// A shorter path drops the hops past the destination
+	s.StoreMTRRound(mtrRound("10.0.0.1", "192.0.2.9"))
+	for _, hop := range s.GetMTR()["example.com"].Hops {
+		if hop.TTL > 2 {
+			t.Errorf("hop at TTL %d kept past the destination", hop.TTL)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// StackMetric is one kernel protocol counter, such as Tcp.RetransSegs, or
+// gauge, such as Tcp.CurrEstab, from /proc/net/snmp or /proc/net/netstat
+type StackMetric struct {
+	Name    string        `json:"name"`
+	Counter bool          `json:"counter"`
+	Value   int64         `json:"value"`
+	Rate    float64       `json:"rate"` // per second; counters only
+	History []MetricPoint `json:"history,omitempty"`
+}
+
+type MetricPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     int64     `json:"value"`
+	Rate      float64   `json:"rate"`
+}
+
+// StackStats holds the kernel protocol metrics and figures derived from them
+type StackStats struct {
+	// RetransmitPercent is the share of TCP segments sent in the last
+	// interval that were retransmissions
+	RetransmitPercent float64                 `json:"retransmit_percent"`
+	Metrics           map[string]*StackMetric `json:"metrics"`
+	LastUpdated       time.Time               `json:"last_updated"`
+}
+
+// UpdateStackMetrics records a reading of the kernel protocol metrics.
+// Names listed in gauges are stored as plain values; all others are
+// cumulative counters and get a per-second rate.
+func (s *Store) UpdateStackMetrics(values map[string]int64, gauges map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.Stack == nil {
+		s.Stack = &StackStats{Metrics: make(map[string]*StackMetric)}
+	}
+	seconds := now.Sub(s.Stack.LastUpdated).Seconds()
+	first := s.Stack.LastUpdated.IsZero()
+
+	for name, value := range values {
+		metric, exists := s.Stack.Metrics[name]
+		if !exists {
+			metric = &StackMetric{Name: name, Counter: !gauges[name], History: []MetricPoint{}}
+			s.Stack.Metrics[name] = metric
+		}
+
+		metric.Rate = 0
+		if metric.Counter && exists && !first && seconds > 0 {
+			// A counter that went backwards was reset; skip the interval
+			if delta, kind := counterDelta(uint64(metric.Value), uint64(value), false); kind != CounterReset {
+				metric.Rate = float64(delta) / seconds
+			}
+		}
+		metric.Value = value
+
+		metric.History = append(metric.History, MetricPoint{Timestamp: now, Value: value, Rate: metric.Rate})
+		if len(metric.History) > MaxHistoryPoints {
+			metric.History = metric.History[1:]
+		}
+	}
+
+	s.Stack.RetransmitPercent = 0
+	if out, retrans := s.Stack.Metrics["Tcp.OutSegs"], s.Stack.Metrics["Tcp.RetransSegs"]; out != nil && retrans != nil && out.Rate > 0 {
+		s.Stack.RetransmitPercent = retrans.Rate / out.Rate * 100
+	}
+	s.Stack.LastUpdated = now
+	s.LastUpdated = now
+}
+
+// GetStackStats returns the kernel protocol metrics, sorted by name. The
+// history is only included for the metrics listed in withHistory.
+func (s *Store) GetStackStats(withHistory map[string]bool) (*StackStats, []StackMetric) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.Stack == nil {
+		return nil, nil
+	}
+
+	metrics := make([]StackMetric, 0, len(s.Stack.Metrics))
+	for name, metric := range s.Stack.Metrics {
+		m := *metric
+		if withHistory[name] {
+			m.History = append([]MetricPoint(nil), metric.History...)
+		} else {
+			m.History = nil
+		}
+		metrics = append(metrics, m)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+
+	summary := &StackStats{
+		RetransmitPercent: s.Stack.RetransmitPercent,
+		LastUpdated:       s.Stack.LastUpdated,
+	}
+	return summary, metrics
+}
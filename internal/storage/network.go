@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Route is an entry of the kernel routing table
+type Route struct {
+	Family      string `json:"family"`      // "ipv4" or "ipv6"
+	Destination string `json:"destination"` // CIDR, 0.0.0.0/0 or ::/0 for default routes
+	Gateway     string `json:"gateway,omitempty"`
+	Interface   string `json:"interface"`
+	Metric      int    `json:"metric"`
+}
+
+// IsDefault reports whether the route is a default route
+func (r Route) IsDefault() bool {
+	return r.Destination == "0.0.0.0/0" || r.Destination == "::/0"
+}
+
+// SetDefaultRoutes records the currently detected default routes
+func (s *Store) SetDefaultRoutes(routes []Route) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.DefaultRoutes = routes
+	s.LastUpdated = time.Now()
+}
+
+func (s *Store) GetDefaultRoutes() []Route {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Route(nil), s.DefaultRoutes...)
+}
+
+const MaxNetworkChanges = 200
+
+// InterfaceInfo describes the configuration and link state of a network
+// interface, complementing the counters in InterfaceStats
+type InterfaceInfo struct {
+	Name      string   `json:"name"`
+	Index     int      `json:"index"`
+	Type      string   `json:"type"` // physical, loopback, bridge, vlan, bond, tun, tap, veth, wireless, virtual
+	MAC       string   `json:"mac,omitempty"`
+	MTU       int      `json:"mtu"`
+	OperState string   `json:"oper_state"`
+	SpeedMbps int      `json:"speed_mbps"` // -1 when unknown
+	Duplex    string   `json:"duplex,omitempty"`
+	Addresses []string `json:"addresses"`
+}
+
+// NetworkSnapshot is the host's interface and routing configuration at a
+// point in time
+type NetworkSnapshot struct {
+	Timestamp  time.Time       `json:"timestamp"`
+	Interfaces []InterfaceInfo `json:"interfaces"`
+	Routes     []Route         `json:"routes"`
+}
+
+// NetworkChange records a difference between two consecutive snapshots
+type NetworkChange struct {
+	Timestamp time.Time `json:"timestamp"`
+	Kind      string    `json:"kind"`
+	Interface string    `json:"interface,omitempty"`
+	Detail    string    `json:"detail"`
+}
+
+// UpdateNetworkSnapshot stores the latest snapshot and records how it
+// differs from the previous one
+func (s *Store) UpdateNetworkSnapshot(snapshot NetworkSnapshot) []NetworkChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []NetworkChange
+	if s.Network != nil {
+		changes = diffNetworkSnapshots(s.Network, &snapshot)
+		for _, change := range changes {
+			if change.Kind == "link_up" || change.Kind == "link_down" {
+				s.addInterfaceEventLocked(change.Interface, change.Kind == "link_up", change.Detail, nil)
+			}
+		}
+		s.NetworkChanges = append(s.NetworkChanges, changes...)
+		if len(s.NetworkChanges) > MaxNetworkChanges {
+			s.NetworkChanges = s.NetworkChanges[len(s.NetworkChanges)-MaxNetworkChanges:]
+		}
+	}
+
+	s.Network = &snapshot
+	s.LastUpdated = snapshot.Timestamp
+	return changes
+}
+
+// GetNetworkSnapshot returns the latest snapshot (nil before the first
+// collection) and the change history, newest last
+func (s *Store) GetNetworkSnapshot() (*NetworkSnapshot, []NetworkChange) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Network, append([]NetworkChange{}, s.NetworkChanges...)
+}
+
+func diffNetworkSnapshots(prev, cur *NetworkSnapshot) []NetworkChange {
+	var changes []NetworkChange
+	add := func(kind, iface, detail string) {
+		changes = append(changes, NetworkChange{Timestamp: cur.Timestamp, Kind: kind, Interface: iface, Detail: detail})
+	}
+
+	before := make(map[string]InterfaceInfo)
+	for _, iface := range prev.Interfaces {
+		before[iface.Name] = iface
+	}
+	after := make(map[string]bool)
+
+	for _, iface := range cur.Interfaces {
+		after[iface.Name] = true
+		old, existed := before[iface.Name]
+		if !existed {
+			add("interface_added", iface.Name, fmt.Sprintf("%s interface %s appeared (%s)", iface.Type, iface.Name, iface.OperState))
+			continue
+		}
+
+		if old.OperState != iface.OperState {
+			kind := "link_state_changed"
+			switch {
+			case iface.OperState == "up":
+				kind = "link_up"
+			case old.OperState == "up":
+				kind = "link_down"
+			}
+			add(kind, iface.Name, fmt.Sprintf("%s changed from %s to %s", iface.Name, old.OperState, iface.OperState))
+		}
+		if old.MTU != iface.MTU {
+			add("mtu_changed", iface.Name, fmt.Sprintf("%s MTU changed from %d to %d", iface.Name, old.MTU, iface.MTU))
+		}
+		if old.MAC != iface.MAC {
+			add("mac_changed", iface.Name, fmt.Sprintf("%s MAC changed from %s to %s", iface.Name, old.MAC, iface.MAC))
+		}
+		if old.SpeedMbps != iface.SpeedMbps && iface.SpeedMbps > 0 {
+			add("speed_changed", iface.Name, fmt.Sprintf("%s link speed changed from %d to %d Mb/s", iface.Name, old.SpeedMbps, iface.SpeedMbps))
+		}
+
+		for _, addr := range setDifference(iface.Addresses, old.Addresses) {
+			add("address_added", iface.Name, fmt.Sprintf("%s added to %s", addr, iface.Name))
+		}
+		for _, addr := range setDifference(old.Addresses, iface.Addresses) {
+			add("address_removed", iface.Name, fmt.Sprintf("%s removed from %s", addr, iface.Name))
+		}
+	}
+
+	for _, iface := range prev.Interfaces {
+		if !after[iface.Name] {
+			add("interface_removed", iface.Name, fmt.Sprintf("interface %s disappeared", iface.Name))
+		}
+	}
+
+	prevRoutes := routeKeys(prev.Routes)
+	curRoutes := routeKeys(cur.Routes)
+	for _, key := range setDifference(curRoutes, prevRoutes) {
+		add("route_added", "", key)
+	}
+	for _, key := range setDifference(prevRoutes, curRoutes) {
+		add("route_removed", "", key)
+	}
+
+	return changes
+}
+
+func routeKeys(routes []Route) []string {
+	keys := make([]string, 0, len(routes))
+	for _, route := range routes {
+		key := route.Destination
+		if route.Gateway != "" {
+			key += " via " + route.Gateway
+		}
+		key += fmt.Sprintf(" dev %s metric %d", route.Interface, route.Metric)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// setDifference returns the entries of a that are not in b
+func setDifference(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, v := range b {
+		seen[v] = true
+	}
+	var diff []string
+	for _, v := range a {
+		if !seen[v] {
+			diff = append(diff, v)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,86 @@
+package storage
+
+import "time"
+
+// PMTUConfirmRuns is the number of consecutive runs finding a lower PMTU
+// after which it becomes the baseline
+const PMTUConfirmRuns = 3
+
+// PMTUResult is the outcome of one path MTU discovery run
+type PMTUResult struct {
+	Timestamp  time.Time `json:"timestamp"`
+	PMTU       int       `json:"pmtu"`
+	MaxProbed  int       `json:"max_probed"`
+	FragNeeded bool      `json:"frag_needed"`
+	BlackHole  bool      `json:"black_hole"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// PMTUStats holds the path MTU history of a single target. Baseline is
+// the usual PMTU: it rises with any larger result and falls once a lower
+// one is confirmed by PMTUConfirmRuns runs in a row. Expected is the
+// known-good PMTU the path should carry, the largest size probed; unlike
+// the baseline it does not follow drops, so a lasting drop stays visible.
+type PMTUStats struct {
+	Target        string       `json:"target"`
+	Current       int          `json:"current"`
+	Baseline      int          `json:"baseline"`
+	Expected      int          `json:"expected"`
+	LowerRuns     int          `json:"lower_runs"`      // consecutive runs below the baseline
+	BlackHoleRuns int          `json:"black_hole_runs"` // consecutive runs finding a black hole
+	History       []PMTUResult `json:"history"`
+}
+
+// StorePMTU records a discovery run and returns the stats after it,
+// without the history. Failed runs leave them unchanged.
+func (s *Store) StorePMTU(target string, result PMTUResult) PMTUStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, exists := s.PMTU[target]
+	if !exists {
+		stats = &PMTUStats{Target: target, History: []PMTUResult{}}
+		s.PMTU[target] = stats
+	}
+	stats.History = append(stats.History, result)
+	if len(stats.History) > MaxHistoryPoints {
+		stats.History = stats.History[1:]
+	}
+
+	if result.Error == "" {
+		stats.Current = result.PMTU
+		stats.Expected = max(result.MaxProbed, result.PMTU)
+		switch {
+		case result.PMTU >= stats.Baseline:
+			stats.Baseline = result.PMTU
+			stats.LowerRuns = 0
+		case stats.LowerRuns+1 >= PMTUConfirmRuns:
+			// The path changed for good
+			stats.Baseline = result.PMTU
+			stats.LowerRuns = 0
+		default:
+			stats.LowerRuns++
+		}
+		if result.BlackHole {
+			stats.BlackHoleRuns++
+		} else {
+			stats.BlackHoleRuns = 0
+		}
+	}
+
+	s.LastUpdated = time.Now()
+	summary := *stats
+	summary.History = nil
+	return summary
+}
+
+func (s *Store) GetPMTU() map[string]*PMTUStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]*PMTUStats)
+	for k, v := range s.PMTU {
+		result[k] = v
+	}
+	return result
+}
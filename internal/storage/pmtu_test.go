@@ -0,0 +1,33 @@
+package storage
+
+import "testing"
+
+func TestStorePMTUBaseline(t *testing.T) {
+	s := NewStore()
+	steps := []struct {
+		result        PMTUResult
+		wantBaseline  int
+		wantBlackHole int
+	}{
+		{PMTUResult{PMTU: 1500}, 1500, 0},
+		{PMTUResult{PMTU: 1400, BlackHole: true}, 1500, 1},
+		{PMTUResult{Error: "timeout"}, 1500, 1},
+		{PMTUResult{PMTU: 1400, BlackHole: true}, 1500, 2},
+		// The third lower run in a row confirms the drop
+		{PMTUResult{PMTU: 1400}, 1400, 0},
+		{PMTUResult{PMTU: 1492}, 1492, 0},
+		// A single lower run leaves the baseline alone
+		{PMTUResult{PMTU: 1280}, 1492, 0},
+		{PMTUResult{PMTU: 1492}, 1492, 0},
+	}
+	for i, step := range steps {
+		stats := s.StorePMTU("192.0.2.1", step.result)
+		if stats.Baseline != step.wantBaseline || stats.BlackHoleRuns != step.wantBlackHole {
+			t.Errorf("step %d: baseline %d, black hole runs %d, want %d, %d",
+				i, stats.Baseline, stats.BlackHoleRuns, step.wantBaseline, step.wantBlackHole)
+		}
+	}
+	if n := len(s.GetPMTU()["192.0.2.1"].History); n != len(steps) {
+		t.Errorf("history has %d results, want %d", n, len(steps))
+	}
+}
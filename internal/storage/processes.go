@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// ProcessConnection is one socket owned by a process. Byte counters and
+// rates are only filled in for TCP sockets when the kernel reports them.
+type ProcessConnection struct {
+	Proto         string  `json:"proto"`
+	Local         string  `json:"local"`
+	Remote        string  `json:"remote"`
+	State         string  `json:"state"`
+	BytesSent     uint64  `json:"bytes_sent"`
+	BytesReceived uint64  `json:"bytes_received"`
+	SendRate      float64 `json:"send_rate"` // bytes per second
+	RecvRate      float64 `json:"recv_rate"`
+}
+
+// ProcessStats attributes a process's open sockets and their traffic
+type ProcessStats struct {
+	PID           int                 `json:"pid"`
+	Name          string              `json:"name"`
+	Cmdline       string              `json:"cmdline"`
+	BytesSent     uint64              `json:"bytes_sent"`
+	BytesReceived uint64              `json:"bytes_received"`
+	SendRate      float64             `json:"send_rate"`
+	RecvRate      float64             `json:"recv_rate"`
+	Connections   []ProcessConnection `json:"connections"`
+}
+
+// ProcessSnapshot is the latest per-process view. ByteCounters is false
+// when per-socket byte counters are unavailable and only connection lists
+// are known.
+type ProcessSnapshot struct {
+	Timestamp    time.Time       `json:"timestamp"`
+	ByteCounters bool            `json:"byte_counters"`
+	Processes    []*ProcessStats `json:"processes"`
+}
+
+// SetProcesses replaces the per-process snapshot, ordering processes by
+// bandwidth and then by number of connections
+func (s *Store) SetProcesses(snapshot ProcessSnapshot) {
+	sort.SliceStable(snapshot.Processes, func(i, j int) bool {
+		a, b := snapshot.Processes[i], snapshot.Processes[j]
+		if rateA, rateB := a.SendRate+a.RecvRate, b.SendRate+b.RecvRate; rateA != rateB {
+			return rateA > rateB
+		}
+		if len(a.Connections) != len(b.Connections) {
+			return len(a.Connections) > len(b.Connections)
+		}
+		return a.PID < b.PID
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Processes = &snapshot
+	s.LastUpdated = snapshot.Timestamp
+}
+
+// GetProcesses returns the latest per-process snapshot, or nil before the
+// first collection
+func (s *Store) GetProcesses() *ProcessSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Processes
+}
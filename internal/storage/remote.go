@@ -0,0 +1,43 @@
+package storage
+
+import "fmt"
+
+// RemoteInterface identifies a port of another device, such as a switch
+// exporting sFlow counters or polled over SNMP
+type RemoteInterface struct {
+	Agent       string `json:"agent"`
+	IfIndex     uint32 `json:"if_index"`
+	IfName      string `json:"if_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Speed       uint64 `json:"speed,omitempty"` // bits per second
+	OperUp      bool   `json:"oper_up"`
+}
+
+// RemoteInterfaceName is the interface name used for a remote port
+func RemoteInterfaceName(agent string, ifIndex uint32) string {
+	return fmt.Sprintf("%s:%d", agent, ifIndex)
+}
+
+// UpdateRemoteInterface records a counter reading for a port of another
+// device. The port is stored as "<agent>:<ifIndex>" alongside the local
+// interfaces.
+func (s *Store) UpdateRemoteInterface(port RemoteInterface, c InterfaceCounters) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := RemoteInterfaceName(port.Agent, port.IfIndex)
+	iface := s.updateInterfaceLocked(name, c)
+	if iface.Remote != nil && iface.Remote.OperUp != port.OperUp {
+		state := "down"
+		if port.OperUp {
+			state = "up"
+		}
+		label := port.IfName
+		if label == "" {
+			label = fmt.Sprintf("ifIndex %d", port.IfIndex)
+		}
+		s.addInterfaceEventLocked(name, port.OperUp, fmt.Sprintf("Port %s of %s went %s", label, port.Agent, state),
+			map[string]string{"agent": port.Agent, "if_name": port.IfName})
+	}
+	iface.Remote = &port
+}
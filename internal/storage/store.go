@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"fmt"
+	"math"
+	"strings"
 	"sync"
 	"time"
 )
@@ -8,34 +11,130 @@ import (
 const (
 	MaxHistoryPoints = 100
 	MaxDevices       = 256
+	MaxCounterResets = 20
 )
 
+// DeviceTimeout is how long a device stays active after it was last seen
+const DeviceTimeout = 5 * time.Minute
+
 type Store struct {
-	mu          sync.RWMutex
-	Interfaces  map[string]*InterfaceStats
-	Devices     map[string]*Device
-	PingResults map[string]*PingStats
-	LastUpdated time.Time
+	mu                 sync.RWMutex
+	Interfaces         map[string]*InterfaceStats
+	InterfaceGroups    map[string][]string
+	TotalInterfaces    []string
+	Devices            map[string]*Device
+	PingResults        map[string]*PingStats
+	Traces             map[string]*TraceStats
+	MTR                map[string]*MTRStats
+	PMTU               map[string]*PMTUStats
+	DefaultRoutes      []Route
+	Network            *NetworkSnapshot
+	NetworkChanges     []NetworkChange
+	Processes          *ProcessSnapshot
+	Connections        []Connection
+	ConnectionHistory  []ConnectionStatePoint
+	ConnectionsUpdated time.Time
+	Stack              *StackStats
+	Flows              []FlowRecord
+	Alerts             []*Alert
+	Events             []Event
+	Incidents          []*Incident
+	Logs               []LogEntry
+	LogHosts           map[string]*LogHost
+	LastUpdated        time.Time
+
+	nextAlertID    int
+	nextEventID    int
+	nextLogID      int
+	nextIncidentID int
+	eventLog       *eventLog
 }
 
 type InterfaceStats struct {
-	Name      string       `json:"name"`
-	BytesRx   uint64       `json:"bytes_rx"`
-	BytesTx   uint64       `json:"bytes_tx"`
-	PacketsRx uint64       `json:"packets_rx"`
-	PacketsTx uint64       `json:"packets_tx"`
-	SpeedRx   float64      `json:"speed_rx"`   // bytes per second
-	SpeedTx   float64      `json:"speed_tx"`   // bytes per second
-	History   []DataPoint  `json:"history"`
-	LastCheck time.Time    `json:"last_check"`
+	Name        string              `json:"name"`
+	BytesRx     uint64              `json:"bytes_rx"`
+	BytesTx     uint64              `json:"bytes_tx"`
+	PacketsRx   uint64              `json:"packets_rx"`
+	PacketsTx   uint64              `json:"packets_tx"`
+	ErrorsRx    uint64              `json:"errors_rx"`
+	ErrorsTx    uint64              `json:"errors_tx"`
+	DropsRx     uint64              `json:"drops_rx"`
+	DropsTx     uint64              `json:"drops_tx"`
+	FifoRx      uint64              `json:"fifo_rx"`
+	FifoTx      uint64              `json:"fifo_tx"`
+	SpeedRx     float64             `json:"speed_rx"`      // bytes per second
+	SpeedTx     float64             `json:"speed_tx"`      // bytes per second
+	ErrorRateRx float64             `json:"error_rate_rx"` // errors per second
+	ErrorRateTx float64             `json:"error_rate_tx"`
+	DropRateRx  float64             `json:"drop_rate_rx"` // drops per second
+	DropRateTx  float64             `json:"drop_rate_tx"`
+	FifoRateRx  float64             `json:"fifo_rate_rx"` // FIFO errors per second
+	FifoRateTx  float64             `json:"fifo_rate_tx"`
+	ResetCount  int                 `json:"reset_count"`
+	Resets      []CounterResetEvent `json:"resets"`
+	Remote      *RemoteInterface    `json:"remote,omitempty"` // set for ports of other devices
+	History     []DataPoint         `json:"history"`
+	LastCheck   time.Time           `json:"last_check"`
 }
 
 type DataPoint struct {
+	Timestamp   time.Time `json:"timestamp"`
+	BytesRx     uint64    `json:"bytes_rx"`
+	BytesTx     uint64    `json:"bytes_tx"`
+	SpeedRx     float64   `json:"speed_rx"`
+	SpeedTx     float64   `json:"speed_tx"`
+	PacketsRx   uint64    `json:"packets_rx"`
+	PacketsTx   uint64    `json:"packets_tx"`
+	ErrorsRx    uint64    `json:"errors_rx"`
+	ErrorsTx    uint64    `json:"errors_tx"`
+	DropsRx     uint64    `json:"drops_rx"`
+	DropsTx     uint64    `json:"drops_tx"`
+	FifoRx      uint64    `json:"fifo_rx"`
+	FifoTx      uint64    `json:"fifo_tx"`
+	ErrorRateRx float64   `json:"error_rate_rx"`
+	ErrorRateTx float64   `json:"error_rate_tx"`
+	DropRateRx  float64   `json:"drop_rate_rx"`
+	DropRateTx  float64   `json:"drop_rate_tx"`
+	FifoRateRx  float64   `json:"fifo_rate_rx"`
+	FifoRateTx  float64   `json:"fifo_rate_tx"`
+	// Discontinuity marks a point whose counters were reset since the
+	// previous point; its rates for the reset counters are zero
+	Discontinuity bool `json:"discontinuity,omitempty"`
+}
+
+// Counter reset kinds
+const (
+	CounterWrapped = "wrap"  // a 32-bit counter rolled over
+	CounterReset   = "reset" // the counters restarted, e.g. interface re-created
+)
+
+// CounterResetEvent records counters that went backwards between two
+// readings of an interface
+type CounterResetEvent struct {
 	Timestamp time.Time `json:"timestamp"`
-	BytesRx   uint64    `json:"bytes_rx"`
-	BytesTx   uint64    `json:"bytes_tx"`
-	SpeedRx   float64   `json:"speed_rx"`
-	SpeedTx   float64   `json:"speed_tx"`
+	Kind      string    `json:"kind"`
+	Counters  []string  `json:"counters"`
+}
+
+// InterfaceCounters is a raw reading of an interface's cumulative counters
+type InterfaceCounters struct {
+	BytesRx   uint64
+	BytesTx   uint64
+	PacketsRx uint64
+	PacketsTx uint64
+	ErrorsRx  uint64
+	ErrorsTx  uint64
+	DropsRx   uint64
+	DropsTx   uint64
+	FifoRx    uint64
+	FifoTx    uint64
+
+	// The 32 flags mark counters that are only 32 bits wide at the source,
+	// such as SNMP ifInOctets, and so wrap. Other counters going backwards
+	// were reset.
+	Bytes32   bool
+	Packets32 bool
+	Errors32  bool // errors and drops
 }
 
 type Device struct {
@@ -45,15 +144,36 @@ type Device struct {
 	LastSeen time.Time `json:"last_seen"`
 	IsActive bool      `json:"is_active"`
 	Vendor   string    `json:"vendor,omitempty"`
+	// Traffic attributed from conntrack; zero when it is unavailable
+	BytesUp   uint64  `json:"bytes_up"`
+	BytesDown uint64  `json:"bytes_down"`
+	RateUp    float64 `json:"rate_up"`   // bytes per second
+	RateDown  float64 `json:"rate_down"` // bytes per second
+	Flows     int     `json:"flows"`
+}
+
+// DeviceBytes is the traffic of one device during a collection interval
+type DeviceBytes struct {
+	Up    uint64
+	Down  uint64
+	Flows int
 }
 
 type PingStats struct {
 	Host         string        `json:"host"`
+	Method       string        `json:"method,omitempty"`
 	LastLatency  time.Duration `json:"last_latency"`
 	AvgLatency   time.Duration `json:"avg_latency"`
 	PacketLoss   float64       `json:"packet_loss"`
 	TotalPings   int           `json:"total_pings"`
 	FailedPings  int           `json:"failed_pings"`
+	LastError    string        `json:"last_error,omitempty"`
+	FailStreak   int           `json:"fail_streak"` // consecutive failed probes
+	DownSince    *time.Time    `json:"down_since,omitempty"`
+	Timings      *ProbeTimings `json:"timings,omitempty"`
+	HTTP         *HTTPDetail   `json:"http,omitempty"`
+	TLS          *TLSDetail    `json:"tls,omitempty"`
+	DNS          *DNSDetail    `json:"dns,omitempty"`
 	History      []PingPoint   `json:"history"`
 	LastUpdated  time.Time     `json:"last_updated"`
 }
@@ -62,6 +182,65 @@ type PingPoint struct {
 	Timestamp time.Time     `json:"timestamp"`
 	Latency   time.Duration `json:"latency"`
 	Success   bool          `json:"success"`
+	Method    string        `json:"method,omitempty"`
+	Timings   *ProbeTimings `json:"timings,omitempty"`
+}
+
+// ProbeTimings breaks a probe's latency down into protocol phases.
+// Phases that did not happen (e.g. TLS for plain HTTP) are zero.
+type ProbeTimings struct {
+	DNS       time.Duration `json:"dns"`
+	Connect   time.Duration `json:"connect"`
+	TLS       time.Duration `json:"tls"`
+	FirstByte time.Duration `json:"first_byte"`
+	Total     time.Duration `json:"total"`
+}
+
+// HTTPDetail holds the response details of an HTTP probe.
+type HTTPDetail struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	BodyBytes  int    `json:"body_bytes"`
+}
+
+// TLSDetail describes the certificate chain and session negotiated by a
+// TLS probe.
+type TLSDetail struct {
+	ServerName   string    `json:"server_name"`
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SANs         []string  `json:"sans"`
+	Chain        []string  `json:"chain"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	DaysToExpiry int       `json:"days_to_expiry"`
+	Version      string    `json:"version"`
+	Cipher       string    `json:"cipher"`
+	ChainValid   bool      `json:"chain_valid"`
+	ChainError   string    `json:"chain_error,omitempty"`
+}
+
+// DNSDetail holds the reply of a DNS probe.
+type DNSDetail struct {
+	Server    string   `json:"server"`
+	Query     string   `json:"query"`
+	Type      string   `json:"type"`
+	Transport string   `json:"transport"`
+	Rcode     string   `json:"rcode"`
+	Answers   []string `json:"answers"`
+}
+
+// PingSample is a single probe outcome together with any protocol
+// specific detail the probe produced.
+type PingSample struct {
+	Latency time.Duration
+	Success bool
+	Method  string
+	Error   string
+	Timings *ProbeTimings
+	HTTP    *HTTPDetail
+	TLS     *TLSDetail
+	DNS     *DNSDetail
 }
 
 func NewStore() *Store {
@@ -69,60 +248,256 @@ func NewStore() *Store {
 		Interfaces:  make(map[string]*InterfaceStats),
 		Devices:     make(map[string]*Device),
 		PingResults: make(map[string]*PingStats),
+		Traces:      make(map[string]*TraceStats),
+		MTR:         make(map[string]*MTRStats),
+		PMTU:        make(map[string]*PMTUStats),
+		LogHosts:    make(map[string]*LogHost),
 		LastUpdated: time.Now(),
 	}
 }
 
 func (s *Store) UpdateInterface(name string, bytesRx, bytesTx, packetsRx, packetsTx uint64) {
+	s.UpdateInterfaceCounters(name, InterfaceCounters{
+		BytesRx:   bytesRx,
+		BytesTx:   bytesTx,
+		PacketsRx: packetsRx,
+		PacketsTx: packetsTx,
+	})
+}
+
+// UpdateInterfaceCounters records a counter reading for an interface,
+// deriving per-second rates from the previous reading
+func (s *Store) UpdateInterfaceCounters(name string, c InterfaceCounters) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.updateInterfaceLocked(name, c)
+}
+
+// updateInterfaceLocked records a counter reading and returns the
+// interface. Callers must hold s.mu.
+func (s *Store) updateInterfaceLocked(name string, c InterfaceCounters) *InterfaceStats {
 	now := time.Now()
-	
+	s.LastUpdated = now
+
 	if iface, exists := s.Interfaces[name]; exists {
-		// Calculate speeds
+		// Calculate rates, checking each counter for wraps and resets
 		timeDiff := now.Sub(iface.LastCheck).Seconds()
+		linkRate := s.linkRateLocked(name, iface)
+		var wrapped, reset []string
+		rate := func(counter string, prev, cur uint64, wraps bool, limit float64) float64 {
+			delta, kind := counterDelta(prev, cur, wraps)
+			// A reset of a counter that may wrap can still look like a
+			// wrap; the link speed tells them apart where known
+			if kind == CounterWrapped && linkRate > 0 && strings.HasPrefix(counter, "bytes_") {
+				limit = min(limit, 1.5*linkRate)
+			}
+			if kind != CounterReset && float64(delta)/timeDiff > limit {
+				// Went up by more than the interface could carry; the
+				// counter restarted and has already passed its old value
+				kind = CounterReset
+			}
+			switch kind {
+			case CounterWrapped:
+				wrapped = append(wrapped, counter)
+			case CounterReset:
+				reset = append(reset, counter)
+				return 0
+			}
+			return float64(delta) / timeDiff
+		}
 		if timeDiff > 0 {
-			iface.SpeedRx = float64(bytesRx-iface.BytesRx) / timeDiff
-			iface.SpeedTx = float64(bytesTx-iface.BytesTx) / timeDiff
+			iface.SpeedRx = rate("bytes_rx", iface.BytesRx, c.BytesRx, c.Bytes32, maxByteRate)
+			iface.SpeedTx = rate("bytes_tx", iface.BytesTx, c.BytesTx, c.Bytes32, maxByteRate)
+			rate("packets_rx", iface.PacketsRx, c.PacketsRx, c.Packets32, maxPacketRate)
+			rate("packets_tx", iface.PacketsTx, c.PacketsTx, c.Packets32, maxPacketRate)
+			iface.ErrorRateRx = rate("errors_rx", iface.ErrorsRx, c.ErrorsRx, c.Errors32, maxPacketRate)
+			iface.ErrorRateTx = rate("errors_tx", iface.ErrorsTx, c.ErrorsTx, c.Errors32, maxPacketRate)
+			iface.DropRateRx = rate("drops_rx", iface.DropsRx, c.DropsRx, c.Errors32, maxPacketRate)
+			iface.DropRateTx = rate("drops_tx", iface.DropsTx, c.DropsTx, c.Errors32, maxPacketRate)
+			iface.FifoRateRx = rate("fifo_rx", iface.FifoRx, c.FifoRx, false, maxPacketRate)
+			iface.FifoRateTx = rate("fifo_tx", iface.FifoTx, c.FifoTx, false, maxPacketRate)
+		}
+		if len(wrapped) > 0 {
+			iface.recordReset(now, CounterWrapped, wrapped)
+		}
+		if len(reset) > 0 {
+			iface.recordReset(now, CounterReset, reset)
+			s.addEventLocked(Event{
+				Timestamp: now,
+				Type:      EventCounterReset,
+				Source:    "interfaces",
+				Target:    name,
+				Severity:  SeverityWarning,
+				Message:   fmt.Sprintf("Counters of %s reset: %s", name, strings.Join(reset, ", ")),
+				Fields:    map[string]string{"counters": strings.Join(reset, ",")},
+			})
 		}
 
 		// Add to history
 		point := DataPoint{
-			Timestamp: now,
-			BytesRx:   bytesRx,
-			BytesTx:   bytesTx,
-			SpeedRx:   iface.SpeedRx,
-			SpeedTx:   iface.SpeedTx,
+			Timestamp:   now,
+			BytesRx:     c.BytesRx,
+			BytesTx:     c.BytesTx,
+			SpeedRx:     iface.SpeedRx,
+			SpeedTx:     iface.SpeedTx,
+			PacketsRx:   c.PacketsRx,
+			PacketsTx:   c.PacketsTx,
+			ErrorsRx:    c.ErrorsRx,
+			ErrorsTx:    c.ErrorsTx,
+			DropsRx:     c.DropsRx,
+			DropsTx:     c.DropsTx,
+			FifoRx:      c.FifoRx,
+			FifoTx:      c.FifoTx,
+			ErrorRateRx: iface.ErrorRateRx,
+			ErrorRateTx: iface.ErrorRateTx,
+			DropRateRx:  iface.DropRateRx,
+			DropRateTx:  iface.DropRateTx,
+			FifoRateRx:  iface.FifoRateRx,
+			FifoRateTx:  iface.FifoRateTx,
+
+			Discontinuity: len(reset) > 0,
 		}
-		
+
 		iface.History = append(iface.History, point)
 		if len(iface.History) > MaxHistoryPoints {
 			iface.History = iface.History[1:]
 		}
 
 		// Update current values
-		iface.BytesRx = bytesRx
-		iface.BytesTx = bytesTx
-		iface.PacketsRx = packetsRx
-		iface.PacketsTx = packetsTx
+		iface.setCounters(c)
 		iface.LastCheck = now
+
+		s.checkInterfaceAlertsLocked(iface)
+		return iface
+	}
+
+	// New interface
+	iface := &InterfaceStats{
+		Name:      name,
+		Resets:    []CounterResetEvent{},
+		History:   []DataPoint{},
+		LastCheck: now,
+	}
+	iface.setCounters(c)
+	s.Interfaces[name] = iface
+	return iface
+}
+
+func (iface *InterfaceStats) setCounters(c InterfaceCounters) {
+	iface.BytesRx = c.BytesRx
+	iface.BytesTx = c.BytesTx
+	iface.PacketsRx = c.PacketsRx
+	iface.PacketsTx = c.PacketsTx
+	iface.ErrorsRx = c.ErrorsRx
+	iface.ErrorsTx = c.ErrorsTx
+	iface.DropsRx = c.DropsRx
+	iface.DropsTx = c.DropsTx
+	iface.FifoRx = c.FifoRx
+	iface.FifoTx = c.FifoTx
+}
+
+// interfaceAlertWindow is how many history points the error and drop
+// alerts look back over, so that sporadic errors keep the alert raised
+const interfaceAlertWindow = 30
+
+// dropAlertRatio is the share of dropped packets that raises a drop alert;
+// a few drops are normal on busy or filtered links
+const dropAlertRatio = 0.01
+
+// checkInterfaceAlertsLocked raises an alert while an interface reports
+// errors or FIFO overruns, or drops more than dropAlertRatio of its packets,
+// within the alert window
+func (s *Store) checkInterfaceAlertsLocked(iface *InterfaceStats) {
+	start := len(iface.History) - interfaceAlertWindow
+	if start < 0 {
+		start = 0
+	}
+
+	// Sum per-interval deltas so a reset inside the window does not count
+	// as a huge jump
+	var errors, fifo, drops, packets uint64
+	for i := start + 1; i < len(iface.History); i++ {
+		prev, cur := iface.History[i-1], iface.History[i]
+		if cur.Discontinuity {
+			continue
+		}
+		errors += windowDelta(prev.ErrorsRx, cur.ErrorsRx) + windowDelta(prev.ErrorsTx, cur.ErrorsTx)
+		fifo += windowDelta(prev.FifoRx, cur.FifoRx) + windowDelta(prev.FifoTx, cur.FifoTx)
+		drops += windowDelta(prev.DropsRx, cur.DropsRx) + windowDelta(prev.DropsTx, cur.DropsTx)
+		packets += windowDelta(prev.PacketsRx, cur.PacketsRx) + windowDelta(prev.PacketsTx, cur.PacketsTx)
+	}
+
+	errorKey := "iface-errors:" + iface.Name
+	if errors > 0 || fifo > 0 {
+		s.raiseAlertLocked(errorKey, "traffic", iface.Name, SeverityWarning,
+			fmt.Sprintf("Interface %s reported %d errors and %d FIFO overruns recently", iface.Name, errors, fifo))
+	} else {
+		s.resolveAlertLocked(errorKey)
+	}
+
+	dropKey := "iface-drops:" + iface.Name
+	if drops > 0 && float64(drops) > dropAlertRatio*float64(packets+drops) {
+		s.raiseAlertLocked(dropKey, "traffic", iface.Name, SeverityWarning,
+			fmt.Sprintf("Interface %s dropped %d of %d packets recently", iface.Name, drops, packets+drops))
 	} else {
-		// New interface
-		s.Interfaces[name] = &InterfaceStats{
-			Name:      name,
-			BytesRx:   bytesRx,
-			BytesTx:   bytesTx,
-			PacketsRx: packetsRx,
-			PacketsTx: packetsTx,
-			SpeedRx:   0,
-			SpeedTx:   0,
-			History:   []DataPoint{},
-			LastCheck: now,
+		s.resolveAlertLocked(dropKey)
+	}
+}
+
+// windowDelta returns the delta between two history points. Points after
+// a reset are skipped, so a counter going backwards here wrapped.
+func windowDelta(prev, cur uint64) uint64 {
+	delta, _ := counterDelta(prev, cur, true)
+	return delta
+}
+
+// Rates above these are treated as counter resets rather than traffic.
+// They sit well above what a 400 Gbit/s link can carry.
+const (
+	maxByteRate   = 100e9
+	maxPacketRate = 1e9
+)
+
+// linkRateLocked returns the link speed of an interface in bytes per
+// second, 0 when unknown
+func (s *Store) linkRateLocked(name string, iface *InterfaceStats) float64 {
+	if iface.Remote != nil {
+		return float64(iface.Remote.Speed) / 8
+	}
+	if s.Network != nil {
+		for _, info := range s.Network.Interfaces {
+			if info.Name == name && info.SpeedMbps > 0 {
+				return float64(info.SpeedMbps) * 1e6 / 8
+			}
 		}
 	}
-	
-	s.LastUpdated = now
+	return 0
+}
+
+// counterDelta returns how far a cumulative counter advanced. A counter
+// that went backwards either wrapped at 32 bits, in which case the delta
+// across the wrap is returned, or was reset and has no usable delta. Only
+// counters known to be 32 bits wide, as marked by wraps, can wrap; 64-bit
+// ones never do in practice.
+func counterDelta(prev, cur uint64, wraps bool) (uint64, string) {
+	if cur >= prev {
+		return cur - prev, ""
+	}
+	// A wrap leaves prev near the top of the 32-bit range; anything else
+	// going backwards is a reset
+	if wraps && prev <= math.MaxUint32 && prev > math.MaxUint32/2 && cur <= math.MaxUint32/2 {
+		return math.MaxUint32 - prev + cur + 1, CounterWrapped
+	}
+	return 0, CounterReset
+}
+
+func (iface *InterfaceStats) recordReset(now time.Time, kind string, counters []string) {
+	iface.ResetCount++
+	iface.Resets = append(iface.Resets, CounterResetEvent{Timestamp: now, Kind: kind, Counters: counters})
+	if len(iface.Resets) > MaxCounterResets {
+		iface.Resets = iface.Resets[1:]
+	}
 }
 
 func (s *Store) UpdateDevice(ip, mac, hostname string) {
@@ -131,9 +506,9 @@ func (s *Store) UpdateDevice(ip, mac, hostname string) {
 
 	now := time.Now()
 	
-	if device, exists := s.Devices[ip]; exists {
+	device, exists := s.Devices[ip]
+	if exists {
 		device.LastSeen = now
-		device.IsActive = true
 		if hostname != "" && device.Hostname == "" {
 			device.Hostname = hostname
 		}
@@ -141,14 +516,84 @@ func (s *Store) UpdateDevice(ip, mac, hostname string) {
 			device.MAC = mac
 		}
 	} else {
-		s.Devices[ip] = &Device{
+		device = &Device{
 			IP:       ip,
 			MAC:      mac,
 			Hostname: hostname,
 			LastSeen: now,
-			IsActive: true,
+		}
+		s.Devices[ip] = device
+	}
+	if !device.IsActive {
+		device.IsActive = true
+		s.addEventLocked(Event{
+			Timestamp: now,
+			Type:      EventDeviceJoined,
+			Source:    "devices",
+			Target:    ip,
+			Severity:  SeverityInfo,
+			Message:   fmt.Sprintf("%s joined the network", deviceLabel(device)),
+			Fields:    map[string]string{"mac": device.MAC, "hostname": device.Hostname},
+		})
+	}
+}
+
+// ExpireDevices marks the devices not seen for DeviceTimeout inactive
+func (s *Store) ExpireDevices() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().Add(-DeviceTimeout)
+	for ip, device := range s.Devices {
+		if !device.IsActive || !device.LastSeen.Before(cutoff) {
+			continue
+		}
+		device.IsActive = false
+		s.addEventLocked(Event{
+			Type:     EventDeviceLeft,
+			Source:   "devices",
+			Target:   ip,
+			Severity: SeverityInfo,
+			Message:  fmt.Sprintf("%s left the network", deviceLabel(device)),
+			Fields: map[string]string{
+				"mac":       device.MAC,
+				"hostname":  device.Hostname,
+				"last_seen": device.LastSeen.Format(time.RFC3339),
+			},
+		})
+	}
+}
+
+// deviceLabel names a device by address and, when known, host name
+func deviceLabel(device *Device) string {
+	if device.Hostname != "" {
+		return fmt.Sprintf("%s (%s)", device.IP, device.Hostname)
+	}
+	return device.IP
+}
+
+// UpdateDeviceBandwidth adds the traffic of the last interval to each
+// device's totals and sets its rates. Devices without traffic in usage
+// drop to zero.
+func (s *Store) UpdateDeviceBandwidth(usage map[string]*DeviceBytes, seconds float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ip, device := range s.Devices {
+		bytes, ok := usage[ip]
+		if !ok {
+			device.RateUp, device.RateDown, device.Flows = 0, 0, 0
+			continue
+		}
+		device.BytesUp += bytes.Up
+		device.BytesDown += bytes.Down
+		device.Flows = bytes.Flows
+		if seconds > 0 {
+			device.RateUp = float64(bytes.Up) / seconds
+			device.RateDown = float64(bytes.Down) / seconds
 		}
 	}
+	s.LastUpdated = time.Now()
 }
 
 func (s *Store) UpdatePing(host string, latency time.Duration, success bool) {
@@ -211,80 +656,124 @@ func (s *Store) UpdatePing(host string, latency time.Duration, success bool) {
 		}
 	}
 }
-// ...existing code...
-
-// Add this method to your Store struct
+// StorePingData records a plain reachability result for host.
 func (s *Store) StorePingData(host string, rtt time.Duration, success bool, method string) {
-    s.mu.Lock()
-    defer s.mu.Unlock()
-
-    now := time.Now()
-    
-    if ping, exists := s.PingResults[host]; exists {
-        ping.TotalPings++
-        if !success {
-            ping.FailedPings++
-        } else {
-            ping.LastLatency = rtt
-        }
-        
-        // Calculate packet loss
-        ping.PacketLoss = float64(ping.FailedPings) / float64(ping.TotalPings) * 100
-        
-        // Calculate average latency (only successful pings)
-        if success && len(ping.History) > 0 {
-            total := rtt
-            count := 1
-            for _, point := range ping.History {
-                if point.Success {
-                    total += point.Latency
-                    count++
-                }
-            }
-            ping.AvgLatency = total / time.Duration(count)
-        }
-        
-        // Add to history
-        point := PingPoint{
-            Timestamp: now,
-            Latency:   rtt,
-            Success:   success,
-        }
-        
-        ping.History = append(ping.History, point)
-        if len(ping.History) > MaxHistoryPoints {
-            ping.History = ping.History[1:]
-        }
-        
-        ping.LastUpdated = now
-    } else {
-        // New ping target
-        avgLatency := time.Duration(0)
-        packetLoss := 0.0
-        failedPings := 0
-        
-        if success {
-            avgLatency = rtt
-        } else {
-            failedPings = 1
-            packetLoss = 100.0
-        }
-        
-        s.PingResults[host] = &PingStats{
-            Host:        host,
-            LastLatency: rtt,
-            AvgLatency:  avgLatency,
-            PacketLoss:  packetLoss,
-            TotalPings:  1,
-            FailedPings: failedPings,
-            History:     []PingPoint{{Timestamp: now, Latency: rtt, Success: success}},
-            LastUpdated: now,
-        }
-    }
-    
-    s.LastUpdated = now
+	s.StoreProbeResult(host, PingSample{Latency: rtt, Success: success, Method: method})
 }
 
+// StoreProbeResult records a probe outcome for host and raises or resolves
+// the matching reachability alert.
+func (s *Store) StoreProbeResult(host string, sample PingSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	rtt := sample.Latency
+	success := sample.Success
+
+	ping, exists := s.PingResults[host]
+	// A new target counts as up until its first probe fails
+	wasUp, known := true, false
+	if exists && len(ping.History) > 0 {
+		wasUp, known = ping.History[len(ping.History)-1].Success, true
+	}
+	if exists {
+		ping.TotalPings++
+		if !success {
+			ping.FailedPings++
+		} else {
+			ping.LastLatency = rtt
+		}
+
+		// Calculate packet loss
+		ping.PacketLoss = float64(ping.FailedPings) / float64(ping.TotalPings) * 100
+
+		// Calculate average latency (only successful pings)
+		if success && len(ping.History) > 0 {
+			total := rtt
+			count := 1
+			for _, point := range ping.History {
+				if point.Success {
+					total += point.Latency
+					count++
+				}
+			}
+			ping.AvgLatency = total / time.Duration(count)
+		}
+	} else {
+		// New ping target
+		ping = &PingStats{
+			Host:        host,
+			LastLatency: rtt,
+			TotalPings:  1,
+			History:     []PingPoint{},
+		}
+		if success {
+			ping.AvgLatency = rtt
+		} else {
+			ping.FailedPings = 1
+			ping.PacketLoss = 100.0
+		}
+		s.PingResults[host] = ping
+	}
+
+	ping.Method = sample.Method
+	ping.LastError = sample.Error
+	ping.Timings = sample.Timings
+	ping.HTTP = sample.HTTP
+	ping.TLS = sample.TLS
+	ping.DNS = sample.DNS
+
+	// Add to history
+	ping.History = append(ping.History, PingPoint{
+		Timestamp: now,
+		Latency:   rtt,
+		Success:   success,
+		Method:    sample.Method,
+		Timings:   sample.Timings,
+	})
+	if len(ping.History) > MaxHistoryPoints {
+		ping.History = ping.History[1:]
+	}
+
+	ping.LastUpdated = now
+	s.LastUpdated = now
+	s.trackIncidentLocked(ping, success, now)
+
+	// The state change is recorded before the alert it causes
+	alertKey := "ping:" + host
+	if success {
+		if known && !wasUp {
+			s.addEventLocked(Event{
+				Timestamp: now,
+				Type:      EventTargetRecovered,
+				Source:    "ping",
+				Target:    host,
+				Severity:  SeverityInfo,
+				Message:   fmt.Sprintf("%s is reachable again", host),
+				Fields:    map[string]string{"method": sample.Method},
+			})
+		}
+		s.resolveAlertLocked(alertKey)
+	} else {
+		message := fmt.Sprintf("%s probe to %s failed", sample.Method, host)
+		if sample.Error != "" {
+			message += ": " + sample.Error
+		}
+		if wasUp {
+			s.addEventLocked(Event{
+				Timestamp: now,
+				Type:      EventTargetDown,
+				Source:    "ping",
+				Target:    host,
+				Severity:  SeverityCritical,
+				Message:   message,
+				Fields:    map[string]string{"method": sample.Method, "error": sample.Error},
+			})
+		}
+		s.raiseAlertLocked(alertKey, "ping", host, SeverityCritical, message)
+	}
+}
 
 func (s *Store) GetInterfaces() map[string]*InterfaceStats {
 	s.mu.RLock()
@@ -301,8 +790,8 @@ func (s *Store) GetDevices() map[string]*Device {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
-	// Mark devices inactive if not seen for 5 minutes
-	cutoff := time.Now().Add(-5 * time.Minute)
+	// Mark devices inactive if not seen for DeviceTimeout
+	cutoff := time.Now().Add(-DeviceTimeout)
 	result := make(map[string]*Device)
 	
 	for k, v := range s.Devices {
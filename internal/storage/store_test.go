@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounterDelta(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev, cur uint64
+		wraps     bool
+		want      uint64
+		wantKind  string
+	}{
+		{"advanced", 100, 250, false, 150, ""},
+		{"unchanged", 100, 100, true, 0, ""},
+		{"32-bit wrap", math.MaxUint32 - 9, 5, true, 15, CounterWrapped},
+		{"64-bit reset near the 32-bit boundary", math.MaxUint32 - 9, 5, false, 0, CounterReset},
+		{"reset", 1 << 40, 10, true, 0, CounterReset},
+		{"reset of a low counter", 1000, 10, true, 0, CounterReset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, kind := counterDelta(tt.prev, tt.cur, tt.wraps)
+			if got != tt.want || kind != tt.wantKind {
+				t.Errorf("counterDelta(%d, %d, %t) = %d, %q, want %d, %q", tt.prev, tt.cur, tt.wraps, got, kind, tt.want, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestInterfaceWrapBoundedByLinkSpeed(t *testing.T) {
+	const gigabit = 1e9
+	tests := []struct {
+		name      string
+		prev, cur uint64
+		wantKind  string
+	}{
+		// 95 MB in 2 s fits a gigabit link
+		{"wrap", math.MaxUint32 - 90e6, 5e6, CounterWrapped},
+		// 1.3 GB in 2 s does not; the counter was reset
+		{"reset near the boundary", 3e9, 5e6, CounterReset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore()
+			port := RemoteInterface{Agent: "192.0.2.1", IfIndex: 1, Speed: gigabit, OperUp: true}
+			s.UpdateRemoteInterface(port, InterfaceCounters{BytesRx: tt.prev, Bytes32: true})
+			name := RemoteInterfaceName(port.Agent, port.IfIndex)
+			s.Interfaces[name].LastCheck = time.Now().Add(-2 * time.Second)
+			s.UpdateRemoteInterface(port, InterfaceCounters{BytesRx: tt.cur, Bytes32: true})
+
+			iface := s.Interfaces[name]
+			if len(iface.Resets) != 1 || iface.Resets[0].Kind != tt.wantKind {
+				t.Fatalf("resets = %+v, want one %s", iface.Resets, tt.wantKind)
+			}
+			if iface.SpeedRx > gigabit/8 {
+				t.Errorf("SpeedRx = %.0f B/s, above the link speed", iface.SpeedRx)
+			}
+		})
+	}
+}
+
+func TestLocalCounterResetWithoutLinkSpeed(t *testing.T) {
+	// A /proc counter of a wifi or virtual interface is 64 bits wide and
+	// its speed is unknown, so a drop from near 2^32 is a reset, not a wrap
+	s := NewStore()
+	s.UpdateInterfaceCounters("wlan0", InterfaceCounters{BytesRx: 3e9})
+	s.Interfaces["wlan0"].LastCheck = time.Now().Add(-2 * time.Second)
+	s.UpdateInterfaceCounters("wlan0", InterfaceCounters{BytesRx: 5e6})
+
+	iface := s.Interfaces["wlan0"]
+	if len(iface.Resets) != 1 || iface.Resets[0].Kind != CounterReset {
+		t.Fatalf("resets = %+v, want one %s", iface.Resets, CounterReset)
+	}
+	if iface.SpeedRx != 0 {
+		t.Errorf("SpeedRx = %.0f B/s, want 0 across the reset", iface.SpeedRx)
+	}
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	MaxTraceHistory = 20
+	MaxPathChanges  = 50
+)
+
+// TraceHop is one TTL step of a traceroute. Address is empty when no
+// probe at this TTL was answered.
+type TraceHop struct {
+	TTL      int             `json:"ttl"`
+	Address  string          `json:"address"`
+	RTTs     []time.Duration `json:"rtts"`
+	Sent     int             `json:"sent"`
+	Received int             `json:"received"`
+	Loss     float64         `json:"loss"`
+	AvgRTT   time.Duration   `json:"avg_rtt"`
+}
+
+// TraceRoute is the result of a single traceroute run
+type TraceRoute struct {
+	Target    string        `json:"target"`
+	Address   string        `json:"address"`
+	Mode      string        `json:"mode"`
+	Hops      []TraceHop    `json:"hops"`
+	Reached   bool          `json:"reached"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Path returns the responding hop addresses in TTL order, with "*" for
+// hops that never answered
+func (t *TraceRoute) Path() []string {
+	path := make([]string, len(t.Hops))
+	for i, hop := range t.Hops {
+		path[i] = hop.Address
+		if path[i] == "" {
+			path[i] = "*"
+		}
+	}
+	return path
+}
+
+// PathChange records that the path to a target differs from the previous run
+type PathChange struct {
+	Timestamp time.Time `json:"timestamp"`
+	Target    string    `json:"target"`
+	Previous  []string  `json:"previous"`
+	Current   []string  `json:"current"`
+}
+
+// TraceStats holds the traceroute history of a single target
+type TraceStats struct {
+	Target  string       `json:"target"`
+	Latest  *TraceRoute  `json:"latest"`
+	History []TraceRoute `json:"history"`
+	Changes []PathChange `json:"changes"`
+}
+
+// StoreTrace records a traceroute run and reports whether the path
+// differs from the previous run to the same target
+func (s *Store) StoreTrace(trace TraceRoute) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, exists := s.Traces[trace.Target]
+	if !exists {
+		stats = &TraceStats{Target: trace.Target}
+		s.Traces[trace.Target] = stats
+	}
+
+	changed := false
+	if stats.Latest != nil && pathChanged(stats.Latest.Path(), trace.Path()) {
+		changed = true
+		stats.Changes = append(stats.Changes, PathChange{
+			Timestamp: trace.StartedAt,
+			Target:    trace.Target,
+			Previous:  stats.Latest.Path(),
+			Current:   trace.Path(),
+		})
+		if len(stats.Changes) > MaxPathChanges {
+			stats.Changes = stats.Changes[1:]
+		}
+	}
+
+	stats.History = append(stats.History, trace)
+	if len(stats.History) > MaxTraceHistory {
+		stats.History = stats.History[1:]
+	}
+	stats.Latest = &stats.History[len(stats.History)-1]
+	s.LastUpdated = time.Now()
+
+	return changed
+}
+
+// pathChanged compares two paths hop by hop. Hops that did not answer in
+// either run are not evidence of a change, since routers often rate limit
+// their ICMP errors.
+func pathChanged(previous, current []string) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+	for i := range previous {
+		if previous[i] == "*" || current[i] == "*" {
+			continue
+		}
+		if !strings.EqualFold(previous[i], current[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) GetTraces() map[string]*TraceStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]*TraceStats)
+	for k, v := range s.Traces {
+		result[k] = v
+	}
+	return result
+}
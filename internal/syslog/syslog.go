@@ -0,0 +1,359 @@
+// Package syslog parses syslog messages in the BSD (RFC 3164) and
+// structured (RFC 5424) formats, and splits TCP streams into messages
+// (RFC 6587).
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severities, from most to least severe
+const (
+	Emergency = iota
+	Alert
+	Critical
+	Error
+	Warning
+	Notice
+	Informational
+	Debug
+)
+
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// SeverityName returns the keyword of a severity, e.g. "err"
+func SeverityName(severity int) string {
+	if severity < 0 || severity >= len(severityNames) {
+		return strconv.Itoa(severity)
+	}
+	return severityNames[severity]
+}
+
+// ParseSeverity accepts a severity keyword, a few common aliases, or a
+// number from 0 to 7
+func ParseSeverity(s string) (int, error) {
+	s = strings.ToLower(s)
+	switch s {
+	case "emergency", "panic":
+		return Emergency, nil
+	case "critical":
+		return Critical, nil
+	case "error":
+		return Error, nil
+	case "warn":
+		return Warning, nil
+	case "informational":
+		return Informational, nil
+	}
+	for i, name := range severityNames {
+		if s == name {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < len(severityNames) {
+		return n, nil
+	}
+	return 0, fmt.Errorf("unknown syslog severity %q", s)
+}
+
+// FacilityName returns the keyword of a facility, e.g. "local7"
+func FacilityName(facility int) string {
+	if facility < 0 || facility >= len(facilityNames) {
+		return strconv.Itoa(facility)
+	}
+	return facilityNames[facility]
+}
+
+// Message is a parsed syslog message. Fields absent from the message are
+// left empty; Timestamp is zero when the message carried none.
+type Message struct {
+	Facility       int
+	Severity       int
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData string // RFC 5424 only, as sent
+	Text           string
+}
+
+// Parse reads one message. The format is detected from the version after
+// the priority; anything that is not RFC 5424 is parsed leniently as
+// RFC 3164, whose timestamps carry no year or zone and are taken to be in
+// loc in the year of now.
+func Parse(data []byte, now time.Time, loc *time.Location) (*Message, error) {
+	data = bytes.TrimRight(data, "\r\n\x00")
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty message")
+	}
+
+	// A missing priority means user.notice (RFC 3164 section 4.3.3)
+	m := &Message{Facility: 1, Severity: Notice}
+	rest := string(data)
+	if rest[0] == '<' {
+		end := strings.IndexByte(rest, '>')
+		if end < 2 || end > 4 {
+			return nil, fmt.Errorf("malformed priority")
+		}
+		// Atoi alone would take signs, as in "<-1>"
+		pri, err := strconv.Atoi(rest[1:end])
+		if !isDigits(rest[1:end]) || err != nil || pri > 191 {
+			return nil, fmt.Errorf("malformed priority %q", rest[1:end])
+		}
+		m.Facility, m.Severity = pri/8, pri%8
+		rest = rest[end+1:]
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		if err := m.parse5424(rest[2:]); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	m.parse3164(rest, now, loc)
+	return m, nil
+}
+
+// parse5424 reads the header, structured data and message that follow
+// the version (RFC 5424 section 6)
+func (m *Message) parse5424(rest string) error {
+	var header [5]string
+	for i := range header {
+		field, remainder, found := strings.Cut(rest, " ")
+		if !found && i < len(header)-1 {
+			return fmt.Errorf("truncated RFC 5424 header")
+		}
+		if field != "-" {
+			header[i] = field
+		}
+		rest = remainder
+	}
+	if header[0] != "" {
+		t, err := time.Parse(time.RFC3339Nano, header[0])
+		if err != nil {
+			return fmt.Errorf("malformed timestamp %q", header[0])
+		}
+		m.Timestamp = t
+	}
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = header[1], header[2], header[3], header[4]
+
+	sd, rest, err := splitStructuredData(rest)
+	if err != nil {
+		return err
+	}
+	if sd != "-" {
+		m.StructuredData = sd
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	rest = strings.TrimPrefix(rest, "\ufeff")
+	m.Text = sanitize(rest)
+	return nil
+}
+
+// splitStructuredData splits the structured data elements, or "-", from
+// the message that follows them
+func splitStructuredData(s string) (string, string, error) {
+	if s == "" || strings.HasPrefix(s, "-") {
+		return "-", strings.TrimPrefix(s, "-"), nil
+	}
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		quoted := false
+		for i++; ; i++ {
+			if i >= len(s) {
+				return "", "", fmt.Errorf("unterminated structured data")
+			}
+			c := s[i]
+			if quoted && c == '\\' {
+				i++
+				continue
+			}
+			if c == '"' {
+				quoted = !quoted
+			} else if c == ']' && !quoted {
+				i++
+				break
+			}
+		}
+	}
+	if i == 0 {
+		return "", "", fmt.Errorf("malformed structured data")
+	}
+	return s[:i], s[i:], nil
+}
+
+// bsdTimestamp matches the RFC 3164 timestamp "Mmm dd hh:mm:ss" and the
+// variants sent by network devices, with a year and fractional seconds
+var bsdTimestamp = regexp.MustCompile(`^[*.]?([A-Z][a-z]{2}) +(\d{1,2}) (\d{4} )?(\d\d:\d\d:\d\d)(\.\d{1,9})?:? ?`)
+
+// parse3164 reads "TIMESTAMP HOSTNAME TAG[PID]: MSG". Devices commonly
+// deviate, so every part is optional: Cisco IOS for example sends
+// "<189>123: *Mar  1 00:01:02.345: %LINK-3-UPDOWN: ..." with a sequence
+// number, no hostname and a colon after the timestamp; the leading "*"
+// marks an unsynchronized clock.
+func (m *Message) parse3164(rest string, now time.Time, loc *time.Location) {
+	// Sequence number
+	if i := strings.Index(rest, ": "); i > 0 && isDigits(rest[:i]) {
+		rest = rest[i+2:]
+	}
+
+	if match := bsdTimestamp.FindStringSubmatch(rest); match != nil {
+		year := strings.TrimSpace(match[3])
+		if year == "" {
+			year = strconv.Itoa(now.In(loc).Year())
+		}
+		// Fractional seconds parse without being in the layout
+		t, err := time.ParseInLocation("Jan 2 2006 15:04:05", match[1]+" "+match[2]+" "+year+" "+match[4]+match[5], loc)
+		if err == nil {
+			// A message from late December received in January
+			if match[3] == "" && t.After(now.Add(24*time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			m.Timestamp = t
+			rest = rest[len(match[0]):]
+
+			// Hostname, unless the next word is already the tag
+			if host, remainder, found := strings.Cut(rest, " "); found && host != "" && !strings.ContainsAny(host, ":[%") {
+				m.Hostname = host
+				rest = remainder
+			}
+		}
+	}
+
+	// Tag, up to "[" or ":"; RFC 3164 allows 32 characters but longer
+	// program names are common
+	for i := 0; i < len(rest) && i <= 48; i++ {
+		c := rest[i]
+		if c == ':' || c == '[' {
+			if i == 0 {
+				break
+			}
+			tag := rest[:i]
+			remainder := rest[i:]
+			if c == '[' {
+				end := strings.IndexByte(remainder, ']')
+				if end < 0 {
+					break
+				}
+				m.ProcID = remainder[1:end]
+				remainder = remainder[end+1:]
+			}
+			if !strings.HasPrefix(remainder, ":") {
+				m.ProcID = ""
+				break
+			}
+			m.AppName = tag
+			rest = strings.TrimPrefix(remainder[1:], " ")
+			break
+		}
+		if c == ' ' || c == '%' {
+			break
+		}
+	}
+	m.Text = sanitize(rest)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// sanitize replaces invalid UTF-8 and control characters other than tabs
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' && r != '\t' {
+			return ' '
+		}
+		return r
+	}, strings.ToValidUTF8(strings.TrimRight(s, " "), "\uFFFD"))
+}
+
+// MaxMessageSize bounds the messages read from streams
+const MaxMessageSize = 64 * 1024
+
+// Reader splits a TCP syslog stream into messages. Each message is either
+// prefixed with its length (octet counting) or ends with a newline
+// (non-transparent framing); senders may use either.
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 4096)}
+}
+
+// Next returns the next message
+func (r *Reader) Next() ([]byte, error) {
+	for {
+		first, err := r.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if first[0] >= '1' && first[0] <= '9' {
+			return r.counted()
+		}
+		line, err := r.line()
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			return line, nil
+		}
+	}
+}
+
+// counted reads an octet-counted message: "LEN SP MSG"
+func (r *Reader) counted() ([]byte, error) {
+	prefix, err := r.r.ReadSlice(' ')
+	if err != nil {
+		return nil, fmt.Errorf("malformed octet count")
+	}
+	length, err := strconv.Atoi(string(prefix[:len(prefix)-1]))
+	if err != nil || length <= 0 || length > MaxMessageSize {
+		return nil, fmt.Errorf("malformed octet count %q", prefix[:len(prefix)-1])
+	}
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r.r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// line reads up to a newline, or a NUL as sent by some older senders
+func (r *Reader) line() ([]byte, error) {
+	var line []byte
+	for {
+		c, err := r.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return line, nil
+			}
+			return nil, err
+		}
+		if c == '\n' || c == 0 {
+			return line, nil
+		}
+		if len(line) >= MaxMessageSize {
+			return nil, fmt.Errorf("message exceeds %d bytes", MaxMessageSize)
+		}
+		line = append(line, c)
+	}
+}
@@ -0,0 +1,84 @@
+package syslog
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		data     string
+		facility int
+		severity int
+		hostname string
+		app      string
+		text     string
+		wantErr  bool
+	}{
+		{
+			name:     "RFC 5424",
+			data:     "<165>1 2026-03-10T11:59:00Z router1 sshd 42 ID47 - Accepted password",
+			facility: 20, severity: Notice, hostname: "router1", app: "sshd", text: "Accepted password",
+		},
+		{
+			name:     "RFC 3164",
+			data:     "<34>Mar 10 11:58:00 switch1 kernel: port 3 down",
+			facility: 4, severity: 2, hostname: "switch1", app: "kernel", text: "port 3 down",
+		},
+		{
+			name:     "no priority",
+			data:     "plain message",
+			facility: 1, severity: Notice, text: "plain message",
+		},
+		{name: "negative priority", data: "<-1>hello", wantErr: true},
+		{name: "signed priority", data: "<+5>hello", wantErr: true},
+		{name: "priority too large", data: "<192>hello", wantErr: true},
+		{name: "unterminated priority", data: "<13 hello", wantErr: true},
+		{name: "empty", data: "\r\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse([]byte(tt.data), now, time.UTC)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %+v, want error", tt.data, m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.data, err)
+			}
+			if m.Facility != tt.facility || m.Severity != tt.severity {
+				t.Errorf("facility, severity = %d, %d, want %d, %d", m.Facility, m.Severity, tt.facility, tt.severity)
+			}
+			if m.Hostname != tt.hostname || m.AppName != tt.app || m.Text != tt.text {
+				t.Errorf("hostname %q app %q text %q, want %q %q %q", m.Hostname, m.AppName, m.Text, tt.hostname, tt.app, tt.text)
+			}
+		})
+	}
+}
+
+func TestReader(t *testing.T) {
+	stream := "11 <13>counted\n<13>line one\n\n<13>line two\x0012 <13>counted2"
+	want := []string{"<13>counted", "<13>line one", "<13>line two", "<13>counted2"}
+
+	r := NewReader(strings.NewReader(stream))
+	for _, w := range want {
+		msg, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next: %v, want %q", err, w)
+		}
+		if string(msg) != w {
+			t.Errorf("Next = %q, want %q", msg, w)
+		}
+	}
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next at end = %v, want EOF", err)
+	}
+}
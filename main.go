@@ -1,8 +1,12 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
 	"time"
 
 	"network-monitor/internal/api"
@@ -13,32 +17,79 @@ import (
 )
 
 func main() {
-	store := storage.NewStore()
+	configPath := flag.String("config", "", "path to a JSON config file")
+	flag.Parse()
 
-	trafficCollector := collector.NewTrafficCollector(store)
-	deviceCollector := collector.NewDeviceCollector(store)
-	pingCollector := collector.NewPingCollector(store)
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
 
-	go trafficCollector.Start(2 * time.Second)
-	go deviceCollector.Start(10 * time.Second)
-	go pingCollector.Start(5 * time.Second)
+	store := storage.NewStore()
+	if cfg.Events.File != "" {
+		if err := store.OpenEventLog(cfg.Events.File); err != nil {
+			log.Fatalf("Error opening event log: %v", err)
+		}
+	}
+
+	switch flag.Arg(0) {
+	case "":
+		startCollectors(store, cfg)
+	case "pcap":
+		// Offline analysis: serve the flows of a capture file instead of
+		// collecting live data
+		if flag.NArg() != 2 {
+			log.Fatal("Usage: network-monitor [-config file] pcap <capture.pcap|capture.pcapng>")
+		}
+		loadCapture(store, flag.Arg(1))
+	default:
+		log.Fatalf("Unknown command %q", flag.Arg(0))
+	}
 
 	apiHandler := api.NewHandler(store)
+	apiHandler.SetOffline(flag.Arg(0) == "pcap")
 
 	r := mux.NewRouter()
 
 	// API routes with /api prefix
 	apiRouter := r.PathPrefix("/api").Subrouter()
 	apiRouter.HandleFunc("/traffic", apiHandler.GetTraffic).Methods("GET")
+	apiRouter.HandleFunc("/traffic/groups", apiHandler.GetInterfaceGroups).Methods("GET")
 	apiRouter.HandleFunc("/traffic/{interface}", apiHandler.GetInterfaceTraffic).Methods("GET")
 	apiRouter.HandleFunc("/devices", apiHandler.GetDevices).Methods("GET")
 	apiRouter.HandleFunc("/devices/active", apiHandler.GetActiveDevices).Methods("GET")
 	apiRouter.HandleFunc("/ping/{host}", apiHandler.GetPing).Methods("GET")
 	apiRouter.HandleFunc("/ping", apiHandler.GetAllPings).Methods("GET")
+	apiRouter.HandleFunc("/trace", apiHandler.GetTraces).Methods("GET")
+	apiRouter.HandleFunc("/trace/{host}", apiHandler.RunTrace).Methods("POST")
+	apiRouter.HandleFunc("/mtr", apiHandler.GetAllMTR).Methods("GET")
+	apiRouter.HandleFunc("/mtr/{host}", apiHandler.GetMTR).Methods("GET")
+	apiRouter.HandleFunc("/pmtu", apiHandler.GetPMTU).Methods("GET")
+	apiRouter.HandleFunc("/system/network", apiHandler.GetSystemNetwork).Methods("GET")
+	apiRouter.HandleFunc("/system/netstat", apiHandler.GetNetstat).Methods("GET")
+	apiRouter.HandleFunc("/processes", apiHandler.GetProcesses).Methods("GET")
+	apiRouter.HandleFunc("/processes/{pid}", apiHandler.GetProcess).Methods("GET")
+	apiRouter.HandleFunc("/connections", apiHandler.GetConnections).Methods("GET")
+	apiRouter.HandleFunc("/connections/history", apiHandler.GetConnectionHistory).Methods("GET")
+	apiRouter.HandleFunc("/flows", apiHandler.GetFlows).Methods("GET")
+	apiRouter.HandleFunc("/flows/top-talkers", apiHandler.GetTopTalkers).Methods("GET")
+	apiRouter.HandleFunc("/flows/devices", apiHandler.GetDeviceThroughput).Methods("GET")
+	apiRouter.HandleFunc("/flows/protocols", apiHandler.GetProtocolBreakdown).Methods("GET")
+	apiRouter.HandleFunc("/pcap", apiHandler.UploadPcap).Methods("POST")
+	apiRouter.HandleFunc("/alerts", apiHandler.GetAlerts).Methods("GET")
+	apiRouter.HandleFunc("/events", apiHandler.GetEvents).Methods("GET")
+	apiRouter.HandleFunc("/incidents", apiHandler.GetIncidents).Methods("GET")
+	apiRouter.HandleFunc("/logs", apiHandler.GetLogs).Methods("GET")
+	apiRouter.HandleFunc("/logs/hosts", apiHandler.GetLogHosts).Methods("GET")
 	apiRouter.HandleFunc("/export/csv", apiHandler.ExportCSV).Methods("GET")
 
 	// WebSocket route
 	r.HandleFunc("/ws", apiHandler.HandleWebSocket)
+	r.HandleFunc("/ws/logs", apiHandler.TailLogs)
+	r.HandleFunc("/ws/events", apiHandler.StreamEvents)
+
+	// Prometheus scrape endpoint
+	r.HandleFunc("/metrics", apiHandler.Metrics).Methods("GET")
 
 	// Serve individual static files with correct names
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -59,3 +110,106 @@ func main() {
 
 		log.Fatal(http.ListenAndServe(":8080", r))
 	}
+
+// startCollectors launches the live collectors enabled by the config
+func startCollectors(store *storage.Store, cfg *Config) {
+	trafficCollector := collector.NewTrafficCollector(store)
+	if err := trafficCollector.SetFilter(cfg.Interfaces); err != nil {
+		log.Fatalf("Error in interface filter: %v", err)
+	}
+	deviceCollector := collector.NewDeviceCollector(store)
+	networkInfoCollector := collector.NewNetworkInfoCollector(store)
+	pingCollector := collector.NewPingCollector(store)
+	if len(cfg.Targets) > 0 {
+		pingCollector.SetTargets(cfg.Targets)
+	}
+	for _, check := range cfg.HTTPChecks {
+		pingCollector.AddHTTPCheck(check)
+	}
+	for _, check := range cfg.TLSChecks {
+		pingCollector.AddTLSCheck(check)
+	}
+	for _, check := range cfg.DNSChecks {
+		pingCollector.AddDNSCheck(check)
+	}
+
+	go trafficCollector.Start(2 * time.Second)
+	go deviceCollector.Start(10 * time.Second)
+	go pingCollector.Start(5 * time.Second)
+	go networkInfoCollector.Start(10 * time.Second)
+
+	// Socket ownership comes from /proc
+	if runtime.GOOS == "linux" {
+		processCollector := collector.NewProcessCollector(store)
+		go processCollector.Start(5 * time.Second)
+		connectionCollector := collector.NewConnectionCollector(store)
+		go connectionCollector.Start(10 * time.Second)
+		netstatCollector := collector.NewNetstatCollector(store)
+		go netstatCollector.Start(5 * time.Second)
+		conntrackCollector := collector.NewConntrackCollector(store)
+		go conntrackCollector.Start(5 * time.Second)
+	}
+
+	if len(cfg.Traceroute.Targets) > 0 {
+		traceCollector := collector.NewTraceCollector(store, cfg.Traceroute.Targets, cfg.Traceroute.TraceOptions)
+		go traceCollector.Start(time.Duration(cfg.Traceroute.IntervalSeconds) * time.Second)
+	}
+	if len(cfg.MTR.Targets) > 0 {
+		mtrCollector := collector.NewMTRCollector(store, cfg.MTR.Targets, cfg.MTR.TraceOptions)
+		go mtrCollector.Start(time.Duration(cfg.MTR.IntervalSeconds) * time.Second)
+	}
+	if len(cfg.PMTU.Targets) > 0 {
+		pmtuCollector := collector.NewPMTUCollector(store, cfg.PMTU.Targets, cfg.PMTU.MaxMTU)
+		go pmtuCollector.Start(time.Duration(cfg.PMTU.IntervalSeconds) * time.Second)
+	}
+	if cfg.Capture.Interface != "" {
+		captureCollector, err := collector.NewCaptureCollector(store, cfg.Capture.Interface, cfg.Capture.Filter)
+		if err != nil {
+			log.Fatalf("Error in capture filter: %v", err)
+		}
+		go captureCollector.Start(time.Duration(cfg.Capture.FlushSeconds) * time.Second)
+	}
+	if cfg.NetFlow.Listen != "" {
+		netflowCollector := collector.NewNetFlowCollector(store, cfg.NetFlow.Listen, cfg.NetFlow.Exporters)
+		go netflowCollector.Start()
+	}
+	if cfg.SFlow.Listen != "" {
+		sflowCollector := collector.NewSFlowCollector(store, cfg.SFlow.Listen, cfg.SFlow.Agents)
+		go sflowCollector.Start(time.Duration(cfg.SFlow.FlushSeconds) * time.Second)
+	}
+	if len(cfg.SNMP.Devices) > 0 {
+		snmpCollector := collector.NewSNMPCollector(store, cfg.SNMP.Devices)
+		go snmpCollector.Start(time.Duration(cfg.SNMP.IntervalSeconds) * time.Second)
+	}
+	if cfg.Traps.Listen != "" {
+		trapReceiver, err := collector.NewTrapReceiver(store, cfg.Traps.Listen, cfg.Traps.TrapOptions)
+		if err != nil {
+			log.Fatalf("Error in trap config: %v", err)
+		}
+		go trapReceiver.Start()
+	}
+	if cfg.Syslog.Enabled() {
+		syslogReceiver, err := collector.NewSyslogReceiver(store, cfg.Syslog)
+		if err != nil {
+			log.Fatalf("Error in syslog config: %v", err)
+		}
+		go syslogReceiver.Start()
+	}
+}
+
+// loadCapture ingests a capture file into the store
+func loadCapture(store *storage.Store, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Error opening capture: %v", err)
+	}
+	defer file.Close()
+
+	summary, err := collector.IngestPcap(store, file, filepath.Base(path), true)
+	if err != nil {
+		log.Fatalf("Error reading capture %s: %v", path, err)
+	}
+	log.Printf("Loaded %s: %d packets, %d flows, %d devices (%s to %s)",
+		path, summary.Packets, summary.Flows, summary.Devices,
+		summary.Start.Format(time.RFC3339), summary.End.Format(time.RFC3339))
+}